@@ -0,0 +1,61 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rinkachi/golang-demos/golang-clean-architecture/domain"
+)
+
+// ErrOrderExists is returned by ImportOrder when the order ID is already taken
+var ErrOrderExists = errors.New("order already exists")
+
+// ImportOrderInput describes an order coming from an export file.
+// Unlike CreateOrderInput it keeps the original ID, status and creation time.
+type ImportOrderInput struct {
+	ID         uuid.UUID
+	CustomerID uuid.UUID
+	Status     string
+	CreatedAt  time.Time
+	Items      []CreateOrderItemInput
+}
+
+// ImportOrder validates input through the domain and saves it for the tenant in ctx.
+// With dryRun set, the order is only validated. Existing IDs yield ErrOrderExists,
+// which makes re-running an import idempotent.
+func (s *OrderService) ImportOrder(ctx context.Context, input ImportOrderInput, dryRun bool) (*OrderOutput, error) {
+	tenant, err := domain.TenantFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]domain.OrderItem, 0, len(input.Items))
+	for _, i := range input.Items {
+		items = append(items, domain.OrderItem{
+			ProductID: i.ProductID,
+			Quantity:  i.Quantity,
+			UnitPrice: i.UnitPrice,
+		})
+	}
+
+	order, err := domain.RestoreOrder(input.ID, tenant, input.CustomerID, items, domain.OrderStatus(input.Status), input.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := s.repo.FindByID(ctx, order.ID); err == nil {
+		return nil, ErrOrderExists
+	} else if !errors.Is(err, domain.ErrOrderNotFound) {
+		return nil, err
+	}
+
+	if !dryRun {
+		if err := s.repo.Save(ctx, order); err != nil {
+			return nil, err
+		}
+	}
+
+	return s.toOutput(order), nil
+}
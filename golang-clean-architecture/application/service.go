@@ -21,6 +21,7 @@ type CreateOrderItemInput struct {
 
 type OrderOutput struct {
 	ID         uuid.UUID
+	TenantID   string
 	CustomerID uuid.UUID
 	Status     string
 	Total      float64
@@ -40,6 +41,11 @@ func NewOrderService(repo domain.OrderRepository, eventBus domain.EventBus) *Ord
 }
 
 func (s *OrderService) CreateOrder(ctx context.Context, input CreateOrderInput) (*OrderOutput, error) {
+	tenant, err := domain.TenantFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
 	var items []domain.OrderItem
 	for _, i := range input.Items {
 		items = append(items, domain.OrderItem{
@@ -49,7 +55,7 @@ func (s *OrderService) CreateOrder(ctx context.Context, input CreateOrderInput)
 		})
 	}
 
-	order, err := domain.NewOrder(input.CustomerID, items)
+	order, err := domain.NewOrder(tenant, input.CustomerID, items)
 	if err != nil {
 		return nil, err
 	}
@@ -84,7 +90,7 @@ func (s *OrderService) PayOrder(ctx context.Context, orderID uuid.UUID) error {
 	
 	// In a real transactional outbox pattern, this would be part of the transaction.
 	// For this demo, we publish directly.
-	return s.eventBus.Publish(event)
+	return s.eventBus.Publish(ctx, event)
 }
 
 func (s *OrderService) GetOrder(ctx context.Context, id uuid.UUID) (*OrderOutput, error) {
@@ -98,6 +104,7 @@ func (s *OrderService) GetOrder(ctx context.Context, id uuid.UUID) (*OrderOutput
 func (s *OrderService) toOutput(order *domain.Order) *OrderOutput {
 	return &OrderOutput{
 		ID:         order.ID,
+		TenantID:   order.TenantID.String(),
 		CustomerID: order.CustomerID,
 		Status:     string(order.Status),
 		Total:      order.Total(),
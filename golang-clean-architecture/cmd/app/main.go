@@ -82,6 +82,9 @@ func main() {
 	// 5. Infrastructure (Transport - HTTP/Gin)
 	orderHandler := httphandler.NewOrderHandler(orderService)
 	ginRouter := gin.Default()
+	// Tenant is taken from a signed JWT when TENANT_JWT_SECRET is set,
+	// otherwise from the X-Tenant-ID header (trusted gateway setup).
+	ginRouter.Use(httphandler.TenantMiddleware([]byte(os.Getenv("TENANT_JWT_SECRET"))))
 	orderHandler.RegisterRoutes(ginRouter)
 
 	// 6. Server
@@ -0,0 +1,289 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rinkachi/golang-demos/golang-clean-architecture/domain"
+)
+
+const (
+	formatNDJSON = "ndjson"
+	formatCSV    = "csv"
+)
+
+// orderRecord is the on-disk representation of an order.
+// The tenant is not part of the record; it is chosen on the command line.
+type orderRecord struct {
+	ID         uuid.UUID    `json:"id"`
+	CustomerID uuid.UUID    `json:"customer_id"`
+	Status     string       `json:"status"`
+	CreatedAt  time.Time    `json:"created_at"`
+	Items      []itemRecord `json:"items"`
+}
+
+type itemRecord struct {
+	ProductID uuid.UUID `json:"product_id"`
+	Quantity  int       `json:"quantity"`
+	UnitPrice float64   `json:"unit_price"`
+}
+
+func recordFromOrder(o *domain.Order) orderRecord {
+	items := make([]itemRecord, len(o.Items))
+	for i, item := range o.Items {
+		items[i] = itemRecord{ProductID: item.ProductID, Quantity: item.Quantity, UnitPrice: item.UnitPrice}
+	}
+	return orderRecord{
+		ID:         o.ID,
+		CustomerID: o.CustomerID,
+		Status:     string(o.Status),
+		CreatedAt:  o.CreatedAt,
+		Items:      items,
+	}
+}
+
+// rowError is a recoverable error tied to a single record of the input
+type rowError struct {
+	Line int
+	Err  error
+}
+
+func (e *rowError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+func (e *rowError) Unwrap() error {
+	return e.Err
+}
+
+type recordWriter interface {
+	Write(rec orderRecord) error
+	Flush() error
+}
+
+// recordReader yields records one by one. It returns io.EOF at the end,
+// a *rowError for a malformed record (reading may continue) and any other
+// error when the input cannot be read any further.
+type recordReader interface {
+	Next() (rec orderRecord, line int, err error)
+}
+
+func newRecordWriter(format string, w io.Writer) (recordWriter, error) {
+	switch format {
+	case formatNDJSON:
+		bw := bufio.NewWriter(w)
+		return &ndjsonWriter{w: bw, enc: json.NewEncoder(bw)}, nil
+	case formatCSV:
+		cw := csv.NewWriter(w)
+		return &csvWriter{w: cw}, nil
+	default:
+		return nil, fmt.Errorf("unknown format %q", format)
+	}
+}
+
+func newRecordReader(format string, r io.Reader) (recordReader, error) {
+	switch format {
+	case formatNDJSON:
+		sc := bufio.NewScanner(r)
+		sc.Buffer(make([]byte, 64*1024), 16*1024*1024)
+		return &ndjsonReader{sc: sc}, nil
+	case formatCSV:
+		cr := csv.NewReader(r)
+		// Row width is checked per order, so that a short row fails its
+		// order instead of the whole import
+		cr.FieldsPerRecord = -1
+		return &csvReader{r: cr}, nil
+	default:
+		return nil, fmt.Errorf("unknown format %q", format)
+	}
+}
+
+// --- NDJSON: one order per line ---
+
+type ndjsonWriter struct {
+	w   *bufio.Writer
+	enc *json.Encoder
+}
+
+func (w *ndjsonWriter) Write(rec orderRecord) error {
+	return w.enc.Encode(rec)
+}
+
+func (w *ndjsonWriter) Flush() error {
+	return w.w.Flush()
+}
+
+type ndjsonReader struct {
+	sc   *bufio.Scanner
+	line int
+}
+
+func (r *ndjsonReader) Next() (orderRecord, int, error) {
+	for r.sc.Scan() {
+		r.line++
+		if len(r.sc.Bytes()) == 0 {
+			continue
+		}
+		var rec orderRecord
+		if err := json.Unmarshal(r.sc.Bytes(), &rec); err != nil {
+			return orderRecord{}, r.line, &rowError{Line: r.line, Err: err}
+		}
+		return rec, r.line, nil
+	}
+	if err := r.sc.Err(); err != nil {
+		return orderRecord{}, r.line, err
+	}
+	return orderRecord{}, r.line, io.EOF
+}
+
+// --- CSV: one row per order item, rows of an order are consecutive ---
+
+var csvHeader = []string{"order_id", "customer_id", "status", "created_at", "product_id", "quantity", "unit_price"}
+
+type csvWriter struct {
+	w           *csv.Writer
+	wroteHeader bool
+}
+
+func (w *csvWriter) Write(rec orderRecord) error {
+	if !w.wroteHeader {
+		if err := w.w.Write(csvHeader); err != nil {
+			return err
+		}
+		w.wroteHeader = true
+	}
+	for _, item := range rec.Items {
+		row := []string{
+			rec.ID.String(),
+			rec.CustomerID.String(),
+			rec.Status,
+			rec.CreatedAt.Format(time.RFC3339Nano),
+			item.ProductID.String(),
+			strconv.Itoa(item.Quantity),
+			strconv.FormatFloat(item.UnitPrice, 'f', -1, 64),
+		}
+		if err := w.w.Write(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *csvWriter) Flush() error {
+	w.w.Flush()
+	return w.w.Error()
+}
+
+type csvReader struct {
+	r          *csv.Reader
+	line       int
+	readHeader bool
+	pending    []string // first row of the next order, already read
+	pendingAt  int
+}
+
+func (r *csvReader) Next() (orderRecord, int, error) {
+	if !r.readHeader {
+		r.readHeader = true
+		header, err := r.readRow()
+		if err != nil {
+			return orderRecord{}, r.line, err
+		}
+		if len(header) != len(csvHeader) {
+			return orderRecord{}, r.line, fmt.Errorf("unexpected csv header %v", header)
+		}
+		for i, name := range csvHeader {
+			if header[i] != name {
+				return orderRecord{}, r.line, fmt.Errorf("unexpected csv header %v", header)
+			}
+		}
+	}
+
+	first, start := r.pending, r.pendingAt
+	r.pending = nil
+	if first == nil {
+		row, err := r.readRow()
+		if err != nil {
+			return orderRecord{}, r.line, err
+		}
+		first, start = row, r.line
+	}
+
+	// Collect the following rows of the same order
+	rows := [][]string{first}
+	for {
+		row, err := r.readRow()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return orderRecord{}, start, err
+		}
+		if row[0] != first[0] {
+			r.pending, r.pendingAt = row, r.line
+			break
+		}
+		rows = append(rows, row)
+	}
+
+	rec, err := parseCSVOrder(rows)
+	if err != nil {
+		return orderRecord{}, start, &rowError{Line: start, Err: err}
+	}
+	return rec, start, nil
+}
+
+func (r *csvReader) readRow() ([]string, error) {
+	row, err := r.r.Read()
+	if err == nil {
+		r.line, _ = r.r.FieldPos(0)
+	}
+	return row, err
+}
+
+func parseCSVOrder(rows [][]string) (orderRecord, error) {
+	first := rows[0]
+	var rec orderRecord
+	var err error
+
+	for _, row := range rows {
+		if len(row) != len(csvHeader) {
+			return rec, fmt.Errorf("want %d fields, got %d", len(csvHeader), len(row))
+		}
+	}
+
+	if rec.ID, err = uuid.Parse(first[0]); err != nil {
+		return rec, fmt.Errorf("order_id: %w", err)
+	}
+	if rec.CustomerID, err = uuid.Parse(first[1]); err != nil {
+		return rec, fmt.Errorf("customer_id: %w", err)
+	}
+	rec.Status = first[2]
+	if first[3] != "" {
+		if rec.CreatedAt, err = time.Parse(time.RFC3339Nano, first[3]); err != nil {
+			return rec, fmt.Errorf("created_at: %w", err)
+		}
+	}
+
+	for _, row := range rows {
+		var item itemRecord
+		if item.ProductID, err = uuid.Parse(row[4]); err != nil {
+			return rec, fmt.Errorf("product_id: %w", err)
+		}
+		if item.Quantity, err = strconv.Atoi(row[5]); err != nil {
+			return rec, fmt.Errorf("quantity: %w", err)
+		}
+		if item.UnitPrice, err = strconv.ParseFloat(row[6], 64); err != nil {
+			return rec, fmt.Errorf("unit_price: %w", err)
+		}
+		rec.Items = append(rec.Items, item)
+	}
+	return rec, nil
+}
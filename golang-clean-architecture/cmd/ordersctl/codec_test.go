@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func sampleRecords() []orderRecord {
+	created := time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC)
+	return []orderRecord{
+		{
+			ID:         uuid.New(),
+			CustomerID: uuid.New(),
+			Status:     "PAID",
+			CreatedAt:  created,
+			Items: []itemRecord{
+				{ProductID: uuid.New(), Quantity: 2, UnitPrice: 9.99},
+				{ProductID: uuid.New(), Quantity: 1, UnitPrice: 100},
+			},
+		},
+		{
+			ID:         uuid.New(),
+			CustomerID: uuid.New(),
+			Status:     "PENDING",
+			CreatedAt:  created.Add(time.Hour),
+			Items:      []itemRecord{{ProductID: uuid.New(), Quantity: 3, UnitPrice: 0.5}},
+		},
+	}
+}
+
+func readAll(t *testing.T, r recordReader) (recs []orderRecord, rowErrs []*rowError) {
+	t.Helper()
+	for {
+		rec, _, err := r.Next()
+		if errors.Is(err, io.EOF) {
+			return recs, rowErrs
+		}
+		var rowErr *rowError
+		if errors.As(err, &rowErr) {
+			rowErrs = append(rowErrs, rowErr)
+			continue
+		}
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		recs = append(recs, rec)
+	}
+}
+
+func TestCodec_RoundTrip(t *testing.T) {
+	for _, format := range []string{formatNDJSON, formatCSV} {
+		t.Run(format, func(t *testing.T) {
+			want := sampleRecords()
+			var buf bytes.Buffer
+			w, err := newRecordWriter(format, &buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, rec := range want {
+				if err := w.Write(rec); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := w.Flush(); err != nil {
+				t.Fatal(err)
+			}
+
+			r, _ := newRecordReader(format, &buf)
+			got, rowErrs := readAll(t, r)
+			if len(rowErrs) != 0 {
+				t.Fatalf("unexpected row errors: %v", rowErrs)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("round trip changed the records:\ngot  %+v\nwant %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestCSVReader_MalformedRows(t *testing.T) {
+	recs := sampleRecords()
+	var buf bytes.Buffer
+	w, _ := newRecordWriter(formatCSV, &buf)
+	for _, rec := range recs {
+		w.Write(rec)
+	}
+	w.Flush()
+
+	// Drop a field from the second row, which belongs to the first order
+	lines := strings.Split(buf.String(), "\n")
+	lines[2] = lines[2][:strings.LastIndex(lines[2], ",")]
+	r, _ := newRecordReader(formatCSV, strings.NewReader(strings.Join(lines, "\n")))
+
+	got, rowErrs := readAll(t, r)
+	if len(rowErrs) != 1 || rowErrs[0].Line != 2 {
+		t.Fatalf("expected one row error for the order at line 2, got %v", rowErrs)
+	}
+	if len(got) != 1 || got[0].ID != recs[1].ID {
+		t.Errorf("expected the next order to be read, got %+v", got)
+	}
+
+	r, _ = newRecordReader(formatNDJSON, strings.NewReader("{not json}\n\n"+`{"status":"PAID"}`+"\n"))
+	got, rowErrs = readAll(t, r)
+	if len(rowErrs) != 1 || rowErrs[0].Line != 1 || len(got) != 1 {
+		t.Errorf("expected line 1 to fail and line 3 to be read, got %v and %+v", rowErrs, got)
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/rinkachi/golang-demos/golang-clean-architecture/application"
+)
+
+type importStats struct {
+	Imported int
+	Skipped  int
+	Failed   int
+}
+
+// importer feeds records into OrderService.ImportOrder in batches.
+// After each completed batch the number of processed records is written to
+// the checkpoint file, so an interrupted run can be resumed.
+type importer struct {
+	service    *application.OrderService
+	dryRun     bool
+	batchSize  int
+	checkpoint string
+	report     io.Writer
+}
+
+// Run imports every record after the first skip ones
+func (im *importer) Run(ctx context.Context, r recordReader, skip int) (importStats, error) {
+	var stats importStats
+	processed := 0
+	inBatch := 0
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return stats, err
+		}
+
+		rec, line, err := r.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		var rowErr *rowError
+		if err != nil && !errors.As(err, &rowErr) {
+			return stats, err
+		}
+
+		processed++
+		if processed <= skip {
+			continue
+		}
+
+		switch {
+		case rowErr != nil:
+			stats.Failed++
+			fmt.Fprintf(im.report, "record %d (line %d): %v\n", processed, line, rowErr.Err)
+		default:
+			im.importRecord(ctx, rec, processed, line, &stats)
+		}
+
+		inBatch++
+		if inBatch == im.batchSize {
+			if err := im.saveCheckpoint(processed); err != nil {
+				return stats, err
+			}
+			inBatch = 0
+		}
+	}
+
+	return stats, im.saveCheckpoint(processed)
+}
+
+func (im *importer) importRecord(ctx context.Context, rec orderRecord, n, line int, stats *importStats) {
+	input := application.ImportOrderInput{
+		ID:         rec.ID,
+		CustomerID: rec.CustomerID,
+		Status:     rec.Status,
+		CreatedAt:  rec.CreatedAt,
+	}
+	for _, item := range rec.Items {
+		input.Items = append(input.Items, application.CreateOrderItemInput{
+			ProductID: item.ProductID,
+			Quantity:  item.Quantity,
+			UnitPrice: item.UnitPrice,
+		})
+	}
+
+	_, err := im.service.ImportOrder(ctx, input, im.dryRun)
+	switch {
+	case errors.Is(err, application.ErrOrderExists):
+		stats.Skipped++
+		fmt.Fprintf(im.report, "record %d (line %d, order %s): skipped, already exists\n", n, line, rec.ID)
+	case err != nil:
+		stats.Failed++
+		fmt.Fprintf(im.report, "record %d (line %d, order %s): %v\n", n, line, rec.ID, err)
+	default:
+		stats.Imported++
+	}
+}
+
+func (im *importer) saveCheckpoint(processed int) error {
+	if im.checkpoint == "" || im.dryRun {
+		return nil
+	}
+	tmp := im.checkpoint + ".tmp"
+	if err := os.WriteFile(tmp, []byte(strconv.Itoa(processed)+"\n"), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, im.checkpoint)
+}
+
+// loadCheckpoint returns the number of records already processed,
+// or 0 when there is no checkpoint yet.
+func loadCheckpoint(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("corrupt checkpoint %s", path)
+	}
+	return n, nil
+}
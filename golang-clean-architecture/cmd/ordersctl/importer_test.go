@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rinkachi/golang-demos/golang-clean-architecture/application"
+	"github.com/rinkachi/golang-demos/golang-clean-architecture/domain"
+	"github.com/rinkachi/golang-demos/golang-clean-architecture/infrastructure/persistence"
+)
+
+// sliceReader yields recs, then fails with err (io.EOF by default)
+type sliceReader struct {
+	recs []orderRecord
+	err  error
+	i    int
+}
+
+func (r *sliceReader) Next() (orderRecord, int, error) {
+	if r.i == len(r.recs) {
+		if r.err != nil {
+			return orderRecord{}, r.i, r.err
+		}
+		return orderRecord{}, r.i, io.EOF
+	}
+	r.i++
+	return r.recs[r.i-1], r.i, nil
+}
+
+func TestImporter_CheckpointAndResume(t *testing.T) {
+	ctx := domain.WithTenant(context.Background(), "acme")
+	repo := persistence.NewInMemoryOrderRepository()
+	var report bytes.Buffer
+	im := &importer{
+		service:    application.NewOrderService(repo, nil),
+		batchSize:  2,
+		checkpoint: filepath.Join(t.TempDir(), "import.ckpt"),
+		report:     &report,
+	}
+
+	recs := append(sampleRecords(), sampleRecords()[0])
+	recs[1].Items[0].Quantity = 0 // fails domain validation
+
+	// The input breaks after the first batch
+	broken := errors.New("connection reset")
+	stats, err := im.Run(ctx, &sliceReader{recs: recs[:2], err: broken}, 0)
+	if !errors.Is(err, broken) {
+		t.Fatalf("expected the read error, got %v", err)
+	}
+	if stats.Imported != 1 || stats.Failed != 1 {
+		t.Errorf("expected 1 imported and 1 failed, got %+v", stats)
+	}
+	if !strings.Contains(report.String(), "record 2") {
+		t.Errorf("expected the failed record in the report, got %q", report.String())
+	}
+
+	skip, err := loadCheckpoint(im.checkpoint)
+	if err != nil || skip != 2 {
+		t.Fatalf("expected checkpoint 2, got %d (%v)", skip, err)
+	}
+	stats, err = im.Run(ctx, &sliceReader{recs: recs}, skip)
+	if err != nil || stats.Imported != 1 || stats.Failed != 0 || stats.Skipped != 0 {
+		t.Fatalf("expected only the third record on resume, got %+v (%v)", stats, err)
+	}
+	if n, _ := loadCheckpoint(im.checkpoint); n != 3 {
+		t.Errorf("expected checkpoint 3, got %d", n)
+	}
+
+	// A full re-run skips what was imported
+	stats, _ = im.Run(ctx, &sliceReader{recs: recs}, 0)
+	if stats.Skipped != 2 || stats.Failed != 1 || stats.Imported != 0 {
+		t.Errorf("expected 2 skipped and 1 failed, got %+v", stats)
+	}
+	orders, _ := repo.FindAll(ctx)
+	if len(orders) != 2 {
+		t.Errorf("expected 2 stored orders, got %d", len(orders))
+	}
+}
+
+func TestImporter_DryRunWritesNothing(t *testing.T) {
+	ctx := domain.WithTenant(context.Background(), "acme")
+	repo := persistence.NewInMemoryOrderRepository()
+	im := &importer{
+		service:    application.NewOrderService(repo, nil),
+		dryRun:     true,
+		batchSize:  10,
+		checkpoint: filepath.Join(t.TempDir(), "import.ckpt"),
+		report:     io.Discard,
+	}
+	stats, err := im.Run(ctx, &sliceReader{recs: sampleRecords()}, 0)
+	if err != nil || stats.Imported != 2 {
+		t.Fatalf("expected 2 validated records, got %+v (%v)", stats, err)
+	}
+	if orders, _ := repo.FindAll(ctx); len(orders) != 0 {
+		t.Errorf("dry run stored %d orders", len(orders))
+	}
+	if n, _ := loadCheckpoint(im.checkpoint); n != 0 {
+		t.Errorf("dry run wrote checkpoint %d", n)
+	}
+}
+
+func TestLoadCheckpoint(t *testing.T) {
+	dir := t.TempDir()
+	if n, err := loadCheckpoint(filepath.Join(dir, "missing")); n != 0 || err != nil {
+		t.Errorf("expected 0 for a missing checkpoint, got %d (%v)", n, err)
+	}
+	im := &importer{checkpoint: filepath.Join(dir, "bad")}
+	im.saveCheckpoint(-1)
+	if _, err := loadCheckpoint(im.checkpoint); err == nil {
+		t.Error("expected a corrupt checkpoint to fail")
+	}
+}
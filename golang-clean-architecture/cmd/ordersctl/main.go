@@ -0,0 +1,214 @@
+// Command ordersctl exports orders to NDJSON/CSV and imports them back.
+//
+// Usage:
+//
+//	ordersctl export -tenant acme -format ndjson -out orders.ndjson
+//	ordersctl import -tenant acme -format ndjson -in orders.ndjson [-dry-run] [-checkpoint f -resume]
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"sort"
+	"syscall"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"github.com/rinkachi/golang-demos/golang-clean-architecture/application"
+	"github.com/rinkachi/golang-demos/golang-clean-architecture/domain"
+	"github.com/rinkachi/golang-demos/golang-clean-architecture/infrastructure/persistence"
+)
+
+const defaultDSN = "host=localhost user=user password=password dbname=clean_arch port=5432 sslmode=disable"
+
+// discardEventBus is used by imports, which restore state and must not
+// re-trigger side effects such as shipping.
+type discardEventBus struct{}
+
+func (discardEventBus) Publish(context.Context, domain.Event) error { return nil }
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("[ORDERSCTL] ")
+
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	var err error
+	switch os.Args[1] {
+	case "export":
+		err = runExport(ctx, os.Args[2:])
+	case "import":
+		err = runImport(ctx, os.Args[2:])
+	default:
+		usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: ordersctl <export|import> [flags]")
+}
+
+type commonFlags struct {
+	dsn    string
+	tenant string
+	format string
+}
+
+func (c *commonFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&c.dsn, "dsn", defaultDSN, "PostgreSQL DSN")
+	fs.StringVar(&c.tenant, "tenant", "", "tenant ID (required)")
+	fs.StringVar(&c.format, "format", formatNDJSON, "file format: ndjson or csv")
+}
+
+// open connects to the database and returns a context scoped to the tenant
+func (c *commonFlags) open(ctx context.Context) (context.Context, *persistence.GormOrderRepository, error) {
+	tenant, err := domain.ParseTenantID(c.tenant)
+	if err != nil {
+		return nil, nil, fmt.Errorf("-tenant: %w", err)
+	}
+	db, err := gorm.Open(postgres.Open(c.dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		return nil, nil, fmt.Errorf("connect to DB: %w", err)
+	}
+	return domain.WithTenant(ctx, tenant), persistence.NewGormOrderRepository(db), nil
+}
+
+func runExport(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	var common commonFlags
+	common.register(fs)
+	out := fs.String("out", "-", "output file, - for stdout")
+	fs.Parse(args)
+
+	ctx, repo, err := common.open(ctx)
+	if err != nil {
+		return err
+	}
+
+	orders, err := repo.FindAll(ctx)
+	if err != nil {
+		return err
+	}
+	// Stable order keeps exports diffable
+	sort.Slice(orders, func(i, j int) bool {
+		if !orders[i].CreatedAt.Equal(orders[j].CreatedAt) {
+			return orders[i].CreatedAt.Before(orders[j].CreatedAt)
+		}
+		return orders[i].ID.String() < orders[j].ID.String()
+	})
+
+	var w io.Writer = os.Stdout
+	if *out != "-" {
+		f, err := os.Create(*out)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+
+	rw, err := newRecordWriter(common.format, w)
+	if err != nil {
+		return err
+	}
+	for _, o := range orders {
+		if err := rw.Write(recordFromOrder(o)); err != nil {
+			return err
+		}
+	}
+	if err := rw.Flush(); err != nil {
+		return err
+	}
+
+	log.Printf("exported %d orders", len(orders))
+	return nil
+}
+
+func runImport(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	var common commonFlags
+	common.register(fs)
+	in := fs.String("in", "-", "input file, - for stdin")
+	dryRun := fs.Bool("dry-run", false, "validate only, do not write")
+	batchSize := fs.Int("batch-size", 100, "records per batch between checkpoints")
+	checkpoint := fs.String("checkpoint", "", "file recording progress after each batch")
+	resume := fs.Bool("resume", false, "skip records already recorded in -checkpoint")
+	fs.Parse(args)
+
+	if *batchSize <= 0 {
+		return fmt.Errorf("-batch-size must be positive")
+	}
+	if *resume && *checkpoint == "" {
+		return fmt.Errorf("-resume requires -checkpoint")
+	}
+
+	skip := 0
+	if *resume {
+		n, err := loadCheckpoint(*checkpoint)
+		if err != nil {
+			return err
+		}
+		skip = n
+	}
+
+	var r io.Reader = os.Stdin
+	if *in != "-" {
+		f, err := os.Open(*in)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+	rr, err := newRecordReader(common.format, r)
+	if err != nil {
+		return err
+	}
+
+	ctx, repo, err := common.open(ctx)
+	if err != nil {
+		return err
+	}
+
+	im := &importer{
+		service:    application.NewOrderService(repo, discardEventBus{}),
+		dryRun:     *dryRun,
+		batchSize:  *batchSize,
+		checkpoint: *checkpoint,
+		report:     os.Stderr,
+	}
+	if skip > 0 {
+		log.Printf("resuming after %d records", skip)
+	}
+
+	stats, err := im.Run(ctx, rr, skip)
+	mode := ""
+	if *dryRun {
+		mode = " (dry run)"
+	}
+	log.Printf("imported=%d skipped=%d failed=%d%s", stats.Imported, stats.Skipped, stats.Failed, mode)
+	if err != nil {
+		return err
+	}
+	if stats.Failed > 0 {
+		return fmt.Errorf("%d records failed", stats.Failed)
+	}
+	return nil
+}
@@ -1,6 +1,7 @@
 package domain
 
 import (
+	"context"
 	"time"
 
 	"github.com/google/uuid"
@@ -21,7 +22,8 @@ func (e OrderPaid) EventName() string {
 	return "OrderPaid"
 }
 
-// EventBus defines how application events are published
+// EventBus defines how application events are published.
+// The context carries request-scoped data such as the tenant.
 type EventBus interface {
-	Publish(event Event) error
+	Publish(ctx context.Context, event Event) error
 }
@@ -2,6 +2,7 @@ package domain
 
 import (
 	"errors"
+	"fmt"
 	"time"
 
 	"github.com/google/uuid"
@@ -13,6 +14,7 @@ var (
 	ErrProductNotFound  = errors.New("product not found")
 	ErrInvalidQuantity  = errors.New("quantity must be greater than zero")
 	ErrInsufficientStock = errors.New("insufficient stock")
+	ErrInvalidOrder     = errors.New("invalid order")
 )
 
 type OrderStatus string
@@ -27,6 +29,7 @@ const (
 // Order is the aggregate root
 type Order struct {
 	ID        uuid.UUID
+	TenantID  TenantID
 	CustomerID uuid.UUID
 	Items     []OrderItem
 	Status    OrderStatus
@@ -34,14 +37,18 @@ type Order struct {
 	UpdatedAt time.Time
 }
 
-// NewOrder creates a new order in pending state
-func NewOrder(customerID uuid.UUID, items []OrderItem) (*Order, error) {
-	if len(items) == 0 {
-		return nil, errors.New("order must have at least one item")
+// NewOrder creates a new order in pending state owned by the given tenant
+func NewOrder(tenantID TenantID, customerID uuid.UUID, items []OrderItem) (*Order, error) {
+	if tenantID == "" {
+		return nil, ErrTenantMissing
+	}
+	if err := validateItems(items); err != nil {
+		return nil, err
 	}
 
 	return &Order{
 		ID:        uuid.New(),
+		TenantID:  tenantID,
 		CustomerID: customerID,
 		Items:     items,
 		Status:    OrderStatusPending,
@@ -50,6 +57,60 @@ func NewOrder(customerID uuid.UUID, items []OrderItem) (*Order, error) {
 	}, nil
 }
 
+// RestoreOrder rebuilds an existing order (e.g. from an import file),
+// applying the same invariants as NewOrder plus a known status.
+func RestoreOrder(id uuid.UUID, tenantID TenantID, customerID uuid.UUID, items []OrderItem, status OrderStatus, createdAt time.Time) (*Order, error) {
+	if tenantID == "" {
+		return nil, ErrTenantMissing
+	}
+	if id == uuid.Nil {
+		return nil, fmt.Errorf("%w: id is required", ErrInvalidOrder)
+	}
+	if err := validateItems(items); err != nil {
+		return nil, err
+	}
+	if !status.Valid() {
+		return nil, fmt.Errorf("%w: unknown status %q", ErrInvalidOrder, status)
+	}
+	if createdAt.IsZero() {
+		createdAt = time.Now()
+	}
+
+	return &Order{
+		ID:         id,
+		TenantID:   tenantID,
+		CustomerID: customerID,
+		Items:      items,
+		Status:     status,
+		CreatedAt:  createdAt,
+		UpdatedAt:  time.Now(),
+	}, nil
+}
+
+func validateItems(items []OrderItem) error {
+	if len(items) == 0 {
+		return fmt.Errorf("%w: at least one item is required", ErrInvalidOrder)
+	}
+	for _, item := range items {
+		if item.Quantity <= 0 {
+			return ErrInvalidQuantity
+		}
+		if item.UnitPrice < 0 {
+			return fmt.Errorf("%w: unit price must not be negative", ErrInvalidOrder)
+		}
+	}
+	return nil
+}
+
+// Valid reports whether s is one of the known order statuses
+func (s OrderStatus) Valid() bool {
+	switch s {
+	case OrderStatusPending, OrderStatusPaid, OrderStatusShipped, OrderStatusCancelled:
+		return true
+	}
+	return false
+}
+
 func (o *Order) Total() float64 {
 	var total float64
 	for _, item := range o.Items {
@@ -8,6 +8,8 @@ import (
 
 // OrderRepository defines the interface for persisting orders.
 // It follows the dependency inversion principle.
+// Implementations must scope every operation to the tenant found in ctx
+// (see TenantFromContext) and fail with ErrTenantMissing when there is none.
 type OrderRepository interface {
 	Save(ctx context.Context, order *Order) error
 	FindByID(ctx context.Context, id uuid.UUID) (*Order, error)
@@ -0,0 +1,56 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"strings"
+)
+
+// Sentinel errors for tenant resolution
+var (
+	ErrTenantMissing = errors.New("tenant not present in context")
+	ErrInvalidTenant = errors.New("invalid tenant id")
+)
+
+const maxTenantIDLength = 64
+
+// TenantID identifies the brand/customer a piece of data belongs to.
+type TenantID string
+
+// ParseTenantID validates a raw tenant identifier coming from the outside world
+// (headers, token claims, message metadata).
+func ParseTenantID(raw string) (TenantID, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" || len(raw) > maxTenantIDLength {
+		return "", ErrInvalidTenant
+	}
+	for _, r := range raw {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_':
+		default:
+			return "", ErrInvalidTenant
+		}
+	}
+	return TenantID(raw), nil
+}
+
+func (t TenantID) String() string {
+	return string(t)
+}
+
+type tenantKey struct{}
+
+// WithTenant returns a copy of ctx carrying the given tenant.
+func WithTenant(ctx context.Context, tenant TenantID) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenant)
+}
+
+// TenantFromContext extracts the tenant stored by WithTenant.
+// It returns ErrTenantMissing when no tenant is set, so callers fail closed.
+func TenantFromContext(ctx context.Context) (TenantID, error) {
+	tenant, ok := ctx.Value(tenantKey{}).(TenantID)
+	if !ok || tenant == "" {
+		return "", ErrTenantMissing
+	}
+	return tenant, nil
+}
@@ -1,11 +1,13 @@
 package http
 
 import (
+	"errors"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/rinkachi/golang-demos/golang-clean-architecture/application"
+	"github.com/rinkachi/golang-demos/golang-clean-architecture/domain"
 )
 
 type OrderHandler struct {
@@ -36,7 +38,7 @@ func (h *OrderHandler) CreateOrder(c *gin.Context) {
 
 	output, err := h.service.CreateOrder(c.Request.Context(), input)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
@@ -53,7 +55,7 @@ func (h *OrderHandler) GetOrder(c *gin.Context) {
 
 	output, err := h.service.GetOrder(c.Request.Context(), id)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
@@ -69,9 +71,23 @@ func (h *OrderHandler) PayOrder(c *gin.Context) {
 	}
 
 	if err := h.service.PayOrder(c.Request.Context(), id); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
 	c.Status(http.StatusOK)
 }
+
+// errorStatus maps service errors to HTTP status codes
+func errorStatus(err error) int {
+	switch {
+	case errors.Is(err, domain.ErrTenantMissing):
+		return http.StatusUnauthorized
+	case errors.Is(err, domain.ErrOrderNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, domain.ErrInvalidOrder), errors.Is(err, domain.ErrInvalidQuantity):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
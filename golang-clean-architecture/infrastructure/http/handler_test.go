@@ -0,0 +1,55 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/rinkachi/golang-demos/golang-clean-architecture/application"
+	"github.com/rinkachi/golang-demos/golang-clean-architecture/infrastructure/persistence"
+)
+
+func TestOrderHandler_ErrorStatus(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	service := application.NewOrderService(persistence.NewInMemoryOrderRepository(), nil)
+
+	// Without the tenant middleware the service finds no tenant
+	bare := gin.New()
+	NewOrderHandler(service).RegisterRoutes(bare)
+	scoped := gin.New()
+	scoped.Use(TenantMiddleware(nil))
+	NewOrderHandler(service).RegisterRoutes(scoped)
+
+	order := func(quantity string) string {
+		return `{"Items":[{"ProductID":"` + uuid.NewString() + `","Quantity":` + quantity + `,"UnitPrice":5}]}`
+	}
+	tests := []struct {
+		name   string
+		router *gin.Engine
+		method string
+		path   string
+		body   string
+		status int
+	}{
+		{"no tenant", bare, http.MethodPost, "/api/v1/orders", order("1"), http.StatusUnauthorized},
+		{"created", scoped, http.MethodPost, "/api/v1/orders", order("1"), http.StatusCreated},
+		{"no items", scoped, http.MethodPost, "/api/v1/orders", `{"Items":[]}`, http.StatusBadRequest},
+		{"bad quantity", scoped, http.MethodPost, "/api/v1/orders", order("0"), http.StatusBadRequest},
+		{"unknown order", scoped, http.MethodGet, "/api/v1/orders/" + uuid.NewString(), "", http.StatusNotFound},
+		{"pay unknown order", scoped, http.MethodPost, "/api/v1/orders/" + uuid.NewString() + "/pay", "", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			req.Header.Set(TenantHeader, "acme")
+			rec := httptest.NewRecorder()
+			tt.router.ServeHTTP(rec, req)
+			if rec.Code != tt.status {
+				t.Errorf("expected %d, got %d: %s", tt.status, rec.Code, rec.Body)
+			}
+		})
+	}
+}
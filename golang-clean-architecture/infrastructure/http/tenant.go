@@ -0,0 +1,98 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/rinkachi/golang-demos/golang-clean-architecture/domain"
+)
+
+// TenantHeader is the header used to select a tenant when no JWT is sent
+const TenantHeader = "X-Tenant-ID"
+
+var errInvalidToken = errors.New("invalid bearer token")
+
+// TenantMiddleware resolves the tenant of every request and stores it in the
+// request context (see domain.WithTenant).
+//
+// A bearer JWT (HS256, signed with jwtSecret, with an "exp" claim) takes
+// precedence and its "tenant_id" claim is used. Without a token the X-Tenant-ID header is used,
+// unless jwtSecret is set, in which case the token is mandatory. A header that
+// disagrees with the token is rejected.
+func TenantMiddleware(jwtSecret []byte) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tenant, status, err := resolveTenant(c.Request, jwtSecret)
+		if err != nil {
+			c.AbortWithStatusJSON(status, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.Request = c.Request.WithContext(domain.WithTenant(c.Request.Context(), tenant))
+		c.Next()
+	}
+}
+
+func resolveTenant(r *http.Request, jwtSecret []byte) (domain.TenantID, int, error) {
+	header := r.Header.Get(TenantHeader)
+
+	token, hasToken := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !hasToken {
+		if len(jwtSecret) > 0 {
+			return "", http.StatusUnauthorized, errors.New("missing bearer token")
+		}
+		tenant, err := domain.ParseTenantID(header)
+		if err != nil {
+			return "", http.StatusBadRequest, err
+		}
+		return tenant, 0, nil
+	}
+
+	if len(jwtSecret) == 0 {
+		return "", http.StatusUnauthorized, errors.New("bearer tokens are not accepted")
+	}
+
+	claim, err := tenantClaim(strings.TrimSpace(token), jwtSecret, time.Now())
+	if err != nil {
+		return "", http.StatusUnauthorized, err
+	}
+	tenant, err := domain.ParseTenantID(claim)
+	if err != nil {
+		return "", http.StatusUnauthorized, err
+	}
+	if header != "" && header != tenant.String() {
+		return "", http.StatusForbidden, errors.New("tenant header does not match token")
+	}
+	return tenant, 0, nil
+}
+
+// tenantClaims are the JWT claims read by TenantMiddleware
+type tenantClaims struct {
+	TenantID string `json:"tenant_id"`
+	jwt.RegisteredClaims
+}
+
+// tenantClaim verifies an HS256 JWT that carries an expiry and returns its
+// tenant_id claim
+func tenantClaim(token string, secret []byte, now time.Time) (string, error) {
+	var claims tenantClaims
+	_, err := jwt.ParseWithClaims(token, &claims, func(*jwt.Token) (any, error) {
+		return secret, nil
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithExpirationRequired(),
+		jwt.WithTimeFunc(func() time.Time { return now }),
+	)
+	switch {
+	case errors.Is(err, jwt.ErrTokenExpired):
+		return "", errors.New("token expired")
+	case errors.Is(err, jwt.ErrTokenRequiredClaimMissing):
+		return "", errors.New("token has no expiry")
+	case err != nil:
+		return "", errInvalidToken
+	}
+	return claims.TenantID, nil
+}
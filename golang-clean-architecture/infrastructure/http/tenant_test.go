@@ -0,0 +1,69 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func signToken(t *testing.T, method jwt.SigningMethod, secret []byte, claims jwt.MapClaims) string {
+	t.Helper()
+	token, err := jwt.NewWithClaims(method, claims).SignedString(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func TestResolveTenant(t *testing.T) {
+	secret := []byte("s3cret")
+	exp := time.Now().Add(time.Hour).Unix()
+	acme := signToken(t, jwt.SigningMethodHS256, secret, jwt.MapClaims{"tenant_id": "acme", "exp": exp})
+
+	tests := []struct {
+		name   string
+		secret []byte
+		token  string
+		header string
+		tenant string
+		status int
+	}{
+		{name: "header", header: "acme", tenant: "acme"},
+		{name: "missing tenant", status: http.StatusBadRequest},
+		{name: "invalid header", header: "acme corp", status: http.StatusBadRequest},
+		{name: "token", secret: secret, token: acme, tenant: "acme"},
+		{name: "token with matching header", secret: secret, token: acme, header: "acme", tenant: "acme"},
+		{name: "token and header mismatch", secret: secret, token: acme, header: "globex", status: http.StatusForbidden},
+		{name: "token required", secret: secret, header: "acme", status: http.StatusUnauthorized},
+		{name: "bad signature", secret: secret, token: signToken(t, jwt.SigningMethodHS256, []byte("other"), jwt.MapClaims{"tenant_id": "acme", "exp": exp}), status: http.StatusUnauthorized},
+		{name: "expired", secret: secret, token: signToken(t, jwt.SigningMethodHS256, secret, jwt.MapClaims{"tenant_id": "acme", "exp": time.Now().Add(-time.Minute).Unix()}), status: http.StatusUnauthorized},
+		{name: "no expiry", secret: secret, token: signToken(t, jwt.SigningMethodHS256, secret, jwt.MapClaims{"tenant_id": "acme"}), status: http.StatusUnauthorized},
+		{name: "other algorithm", secret: secret, token: signToken(t, jwt.SigningMethodHS512, secret, jwt.MapClaims{"tenant_id": "acme", "exp": exp}), status: http.StatusUnauthorized},
+		{name: "token without tenant", secret: secret, token: signToken(t, jwt.SigningMethodHS256, secret, jwt.MapClaims{"exp": exp}), status: http.StatusUnauthorized},
+		{name: "token not accepted", token: acme, status: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.token != "" {
+				r.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			if tt.header != "" {
+				r.Header.Set(TenantHeader, tt.header)
+			}
+			tenant, status, err := resolveTenant(r, tt.secret)
+			if tt.status != 0 {
+				if err == nil || status != tt.status {
+					t.Fatalf("expected status %d, got %d (%v)", tt.status, status, err)
+				}
+				return
+			}
+			if err != nil || tenant.String() != tt.tenant {
+				t.Fatalf("expected tenant %q, got %q (%v)", tt.tenant, tenant, err)
+			}
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package messaging
 
 import (
+	"context"
 	"encoding/json"
 	
 	"github.com/ThreeDotsLabs/watermill"
@@ -8,6 +9,9 @@ import (
 	"github.com/rinkachi/golang-demos/golang-clean-architecture/domain"
 )
 
+// TenantMetadataKey is the message metadata key carrying the tenant ID
+const TenantMetadataKey = "tenant_id"
+
 type WatermillEventBus struct {
 	publisher message.Publisher
 }
@@ -18,14 +22,32 @@ func NewWatermillEventBus(publisher message.Publisher) *WatermillEventBus {
 	}
 }
 
-func (b *WatermillEventBus) Publish(event domain.Event) error {
+func (b *WatermillEventBus) Publish(ctx context.Context, event domain.Event) error {
+	tenant, err := domain.TenantFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
 	payload, err := json.Marshal(event)
 	if err != nil {
 		return err
 	}
 
 	msg := message.NewMessage(watermill.NewUUID(), payload)
+	msg.Metadata.Set(TenantMetadataKey, tenant.String())
+	msg.SetContext(ctx)
 	
 	// Use the event name as the topic
 	return b.publisher.Publish(event.EventName(), msg)
 }
+
+// tenantFromMessage reads the tenant stamped by Publish and stores it in the
+// message context, so handlers can pass msg.Context() to tenant-scoped code.
+func tenantFromMessage(msg *message.Message) (domain.TenantID, error) {
+	tenant, err := domain.ParseTenantID(msg.Metadata.Get(TenantMetadataKey))
+	if err != nil {
+		return "", err
+	}
+	msg.SetContext(domain.WithTenant(msg.Context(), tenant))
+	return tenant, nil
+}
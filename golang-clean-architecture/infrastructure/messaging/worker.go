@@ -19,12 +19,19 @@ func NewShippingWorker(logger *log.Logger) *ShippingWorker {
 }
 
 func (w *ShippingWorker) HandleOrderPaid(msg *message.Message) error {
+	tenant, err := tenantFromMessage(msg)
+	if err != nil {
+		// Retrying will not fix a message without a tenant, so drop it
+		w.logger.Printf("[SHIPPING] Dropping message %s: %v", msg.UUID, err)
+		return nil
+	}
+
 	var event domain.OrderPaid
 	if err := json.Unmarshal(msg.Payload, &event); err != nil {
 		return err // In real app, maybe send to DLQ
 	}
 
-	w.logger.Printf("[SHIPPING] Processing shipment for Order %s. Amount paid: %.2f", event.OrderID, event.TotalAmount)
+	w.logger.Printf("[SHIPPING] [tenant=%s] Processing shipment for Order %s. Amount paid: %.2f", tenant, event.OrderID, event.TotalAmount)
 
 	// Simulate shipping processing
 	// ...
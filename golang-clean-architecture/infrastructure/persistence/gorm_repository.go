@@ -3,9 +3,11 @@ package persistence
 import (
 	"context"
 	"encoding/json"
+	"time"
 	
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	
 	"github.com/rinkachi/golang-demos/golang-clean-architecture/domain"
 )
@@ -13,12 +15,12 @@ import (
 // GormOrder is the DB model for Order
 type GormOrder struct {
 	ID         uuid.UUID `gorm:"type:uuid;primary_key"`
+	TenantID   string    `gorm:"index;not null;default:''"`
 	CustomerID uuid.UUID `gorm:"type:uuid"`
 	Status     string
 	Total      float64
 	ItemsJSON  []byte     `gorm:"type:jsonb"` // Simple storage for items for this demo
-	CreatedAt  string // Store as string or time.Time. GORM handles time.Time automatically usually, but let's stick to simple mapping.
-	// Actually GORM handles time.Time well.
+	CreatedAt  time.Time
 }
 
 // ToDomain maps DB model to Domain entity
@@ -32,13 +34,16 @@ func (g *GormOrder) ToDomain() (*domain.Order, error) {
 	// Note: In real app, we might map fields more carefully
 	return &domain.Order{
 		ID:         g.ID,
+		TenantID:   domain.TenantID(g.TenantID),
 		CustomerID: g.CustomerID,
 		Items:      items,
 		Status:     domain.OrderStatus(g.Status),
-		// CreatedAt: ...
+		CreatedAt:  g.CreatedAt,
 	}, nil
 }
 
+// GormOrderRepository persists orders in Postgres. Every query is filtered by
+// the tenant carried by the context.
 type GormOrderRepository struct {
 	db *gorm.DB
 }
@@ -49,7 +54,24 @@ func NewGormOrderRepository(db *gorm.DB) *GormOrderRepository {
 	return &GormOrderRepository{db: db}
 }
 
+// scoped returns a session restricted to the tenant found in ctx
+func (r *GormOrderRepository) scoped(ctx context.Context) (*gorm.DB, domain.TenantID, error) {
+	tenant, err := domain.TenantFromContext(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+	return r.db.WithContext(ctx).Where("tenant_id = ?", tenant.String()), tenant, nil
+}
+
 func (r *GormOrderRepository) Save(ctx context.Context, order *domain.Order) error {
+	db, tenant, err := r.scoped(ctx)
+	if err != nil {
+		return err
+	}
+	if order.TenantID != tenant {
+		return domain.ErrOrderNotFound
+	}
+
 	itemsJSON, err := json.Marshal(order.Items)
 	if err != nil {
 		return err
@@ -57,18 +79,47 @@ func (r *GormOrderRepository) Save(ctx context.Context, order *domain.Order) err
 
 	model := GormOrder{
 		ID:         order.ID,
+		TenantID:   tenant.String(),
 		CustomerID: order.CustomerID,
 		Status:     string(order.Status),
 		Total:      order.Total(),
 		ItemsJSON:  itemsJSON,
+		CreatedAt:  order.CreatedAt,
+	}
+
+	// Update only rows owned by the tenant. gorm's Save would upsert by primary
+	// key, which could overwrite another tenant's order.
+	result := db.Model(&GormOrder{}).
+		Where("id = ?", model.ID).
+		Select("customer_id", "status", "total", "items_json").
+		Updates(&model)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected > 0 {
+		return nil
 	}
 
-	return r.db.WithContext(ctx).Save(&model).Error
+	// An ID taken by another tenant looks like any other missing order, so
+	// the conflict does not reveal that it exists
+	result = r.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(&model)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return domain.ErrOrderNotFound
+	}
+	return nil
 }
 
 func (r *GormOrderRepository) FindByID(ctx context.Context, id uuid.UUID) (*domain.Order, error) {
+	db, _, err := r.scoped(ctx)
+	if err != nil {
+		return nil, err
+	}
+
 	var model GormOrder
-	if err := r.db.WithContext(ctx).First(&model, "id = ?", id).Error; err != nil {
+	if err := db.First(&model, "id = ?", id).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			return nil, domain.ErrOrderNotFound
 		}
@@ -79,8 +130,13 @@ func (r *GormOrderRepository) FindByID(ctx context.Context, id uuid.UUID) (*doma
 }
 
 func (r *GormOrderRepository) FindAll(ctx context.Context) ([]*domain.Order, error) {
+	db, _, err := r.scoped(ctx)
+	if err != nil {
+		return nil, err
+	}
+
 	var models []GormOrder
-	if err := r.db.WithContext(ctx).Find(&models).Error; err != nil {
+	if err := db.Find(&models).Error; err != nil {
 		return nil, err
 	}
 
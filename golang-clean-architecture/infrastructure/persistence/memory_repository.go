@@ -9,6 +9,8 @@ import (
 	"github.com/rinkachi/golang-demos/golang-clean-architecture/domain"
 )
 
+// InMemoryOrderRepository keeps orders in a map. Every call is scoped to the
+// tenant carried by the context; orders of other tenants are invisible.
 type InMemoryOrderRepository struct {
 	mu     sync.RWMutex
 	orders map[uuid.UUID]*domain.Order
@@ -21,17 +23,35 @@ func NewInMemoryOrderRepository() *InMemoryOrderRepository {
 }
 
 func (r *InMemoryOrderRepository) Save(ctx context.Context, order *domain.Order) error {
+	tenant, err := domain.TenantFromContext(ctx)
+	if err != nil {
+		return err
+	}
+	if order.TenantID != tenant {
+		return domain.ErrOrderNotFound
+	}
+
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
 	// Simulate IO delay
 	time.Sleep(10 * time.Millisecond)
 
+	// Never let one tenant overwrite another tenant's order with the same ID
+	if existing, ok := r.orders[order.ID]; ok && existing.TenantID != tenant {
+		return domain.ErrOrderNotFound
+	}
+
 	r.orders[order.ID] = order
 	return nil
 }
 
 func (r *InMemoryOrderRepository) FindByID(ctx context.Context, id uuid.UUID) (*domain.Order, error) {
+	tenant, err := domain.TenantFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
@@ -39,18 +59,26 @@ func (r *InMemoryOrderRepository) FindByID(ctx context.Context, id uuid.UUID) (*
 	time.Sleep(5 * time.Millisecond)
 
 	order, ok := r.orders[id]
-	if !ok {
+	if !ok || order.TenantID != tenant {
 		return nil, domain.ErrOrderNotFound
 	}
 	return order, nil
 }
 
 func (r *InMemoryOrderRepository) FindAll(ctx context.Context) ([]*domain.Order, error) {
+	tenant, err := domain.TenantFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
 	orders := make([]*domain.Order, 0, len(r.orders))
 	for _, order := range r.orders {
+		if order.TenantID != tenant {
+			continue
+		}
 		orders = append(orders, order)
 	}
 	return orders, nil
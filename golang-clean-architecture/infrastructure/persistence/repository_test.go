@@ -0,0 +1,88 @@
+package persistence
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"github.com/rinkachi/golang-demos/golang-clean-architecture/domain"
+)
+
+func newTestOrder(t *testing.T, tenant domain.TenantID) *domain.Order {
+	t.Helper()
+	order, err := domain.NewOrder(tenant, uuid.New(), []domain.OrderItem{
+		{ProductID: uuid.New(), Quantity: 2, UnitPrice: 5},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return order
+}
+
+func TestRepositories_TenantIsolation(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "orders.db")), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	repos := map[string]domain.OrderRepository{
+		"memory": NewInMemoryOrderRepository(),
+		"gorm":   NewGormOrderRepository(db),
+	}
+
+	for name, repo := range repos {
+		t.Run(name, func(t *testing.T) {
+			acme := domain.WithTenant(context.Background(), "acme")
+			globex := domain.WithTenant(context.Background(), "globex")
+
+			order := newTestOrder(t, "acme")
+			order.CreatedAt = time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+			if err := repo.Save(acme, order); err != nil {
+				t.Fatal(err)
+			}
+			got, err := repo.FindByID(acme, order.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !got.CreatedAt.Equal(order.CreatedAt) || got.TenantID != "acme" {
+				t.Errorf("expected the order back as saved, got %+v", got)
+			}
+
+			// Other tenants cannot see the order
+			if _, err := repo.FindByID(globex, order.ID); !errors.Is(err, domain.ErrOrderNotFound) {
+				t.Errorf("expected ErrOrderNotFound across tenants, got %v", err)
+			}
+			if all, _ := repo.FindAll(globex); len(all) != 0 {
+				t.Errorf("expected no orders for another tenant, got %d", len(all))
+			}
+
+			// Nor overwrite it, and the conflict looks like a missing order
+			stolen := *order
+			stolen.TenantID = "globex"
+			stolen.Status = domain.OrderStatusCancelled
+			if err := repo.Save(globex, &stolen); !errors.Is(err, domain.ErrOrderNotFound) {
+				t.Errorf("expected ErrOrderNotFound when saving another tenant's ID, got %v", err)
+			}
+			if err := repo.Save(acme, &stolen); !errors.Is(err, domain.ErrOrderNotFound) {
+				t.Errorf("expected ErrOrderNotFound for an order of another tenant, got %v", err)
+			}
+			if got, _ := repo.FindByID(acme, order.ID); got.Status != domain.OrderStatusPending {
+				t.Errorf("order was overwritten: %+v", got)
+			}
+
+			// Without a tenant nothing is reachable
+			if _, err := repo.FindByID(context.Background(), order.ID); !errors.Is(err, domain.ErrTenantMissing) {
+				t.Errorf("expected ErrTenantMissing, got %v", err)
+			}
+			if err := repo.Save(context.Background(), order); !errors.Is(err, domain.ErrTenantMissing) {
+				t.Errorf("expected ErrTenantMissing, got %v", err)
+			}
+		})
+	}
+}
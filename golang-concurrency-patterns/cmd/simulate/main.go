@@ -0,0 +1,67 @@
+// Command simulate runs a concurrency scenario described in YAML against the
+// patterns package and prints a throughput/latency report.
+//
+// Usage:
+//
+//	simulate -scenario scenarios/image-pipeline.yaml [-exporter none|stdout|otlp] [-speed 10]
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/rinkachi/golang-demos/golang-concurrency-patterns/internal/telemetry"
+)
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("[SIMULATE] ")
+
+	scenarioPath := flag.String("scenario", "", "scenario YAML file (required)")
+	exporter := flag.String("exporter", "none", "trace exporter: otlp, stdout or none")
+	endpoint := flag.String("otlp-endpoint", "localhost:4317", "OTLP gRPC endpoint")
+	speed := flag.Float64("speed", 1, "run the scenario this many times faster than real time")
+	flag.Parse()
+
+	if *scenarioPath == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if *speed <= 0 {
+		log.Fatal("-speed must be positive")
+	}
+
+	sc, err := loadScenario(*scenarioPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	// Spans go to stderr so they do not mix with the report
+	shutdown, err := telemetry.SetupTracing(ctx, telemetry.Config{
+		ServiceName: "concurrency-simulator",
+		Exporter:    *exporter,
+		Endpoint:    *endpoint,
+		Writer:      os.Stderr,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer shutdown(context.Background())
+
+	rep, err := newRunner(sc, *speed).run(ctx)
+	if rep != nil {
+		rep.Print(os.Stdout)
+	}
+	if err != nil {
+		log.Print(err)
+		shutdown(context.Background())
+		os.Exit(1)
+	}
+}
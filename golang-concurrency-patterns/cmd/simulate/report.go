@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+	"time"
+)
+
+// Report summarizes a scenario run in scenario time.
+type Report struct {
+	Scenario  string
+	Items     int
+	Speed     float64
+	Elapsed   time.Duration
+	Succeeded int
+	Stages    []StageReport
+	EndToEnd  LatencySummary
+}
+
+// StageReport is the outcome of one stage. Latency is the service time of
+// an item; Wait is the time it queued on the stage's rate limit before that.
+type StageReport struct {
+	Name      string
+	Workers   int
+	Processed int
+	Failed    int
+	Latency   LatencySummary
+	Wait      LatencySummary
+}
+
+// LatencySummary holds latency percentiles.
+type LatencySummary struct {
+	P50, P95, P99, Max time.Duration
+}
+
+func summarize(samples []time.Duration) LatencySummary {
+	if len(samples) == 0 {
+		return LatencySummary{}
+	}
+	sorted := append([]time.Duration(nil), samples...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	at := func(q float64) time.Duration {
+		return sorted[int(q*float64(len(sorted)-1))]
+	}
+	return LatencySummary{P50: at(0.50), P95: at(0.95), P99: at(0.99), Max: sorted[len(sorted)-1]}
+}
+
+func newReport(sc *Scenario, speed float64, elapsed time.Duration, stats []*stageStats, endToEnd []time.Duration) *Report {
+	rep := &Report{
+		Scenario:  sc.Name,
+		Items:     sc.Items,
+		Speed:     speed,
+		Elapsed:   elapsed,
+		Succeeded: len(endToEnd),
+		EndToEnd:  summarize(endToEnd),
+	}
+	for i, st := range sc.Stages {
+		s := stats[i]
+		s.mu.Lock()
+		rep.Stages = append(rep.Stages, StageReport{
+			Name:      st.Name,
+			Workers:   st.Workers,
+			Processed: len(s.latencies),
+			Failed:    s.failed,
+			Latency:   summarize(s.latencies),
+			Wait:      summarize(s.waits),
+		})
+		s.mu.Unlock()
+	}
+	return rep
+}
+
+func throughput(n int, d time.Duration) float64 {
+	if d <= 0 {
+		return 0
+	}
+	return float64(n) / d.Seconds()
+}
+
+// Print writes the report as a table.
+func (r *Report) Print(w io.Writer) {
+	fmt.Fprintf(w, "Scenario %q: %d items in %s\n", r.Scenario, r.Items, r.Elapsed.Round(time.Millisecond))
+	fmt.Fprintf(w, "Succeeded: %d, failed: %d, throughput: %.1f items/s\n\n",
+		r.Succeeded, r.Items-r.Succeeded, throughput(r.Succeeded, r.Elapsed))
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "STAGE\tWORKERS\tPROCESSED\tFAILED\tITEMS/S\tWAIT P95\tP50\tP95\tP99\tMAX\t")
+	for _, s := range r.Stages {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%.1f\t%s\t%s\t%s\t%s\t%s\t\n",
+			s.Name, s.Workers, s.Processed, s.Failed, throughput(s.Processed, r.Elapsed), round(s.Wait.P95),
+			round(s.Latency.P50), round(s.Latency.P95), round(s.Latency.P99), round(s.Latency.Max))
+	}
+	fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%.1f\t%s\t%s\t%s\t%s\t%s\t\n",
+		"end-to-end", "-", r.Succeeded, r.Items-r.Succeeded, throughput(r.Succeeded, r.Elapsed), "-",
+		round(r.EndToEnd.P50), round(r.EndToEnd.P95), round(r.EndToEnd.P99), round(r.EndToEnd.Max))
+	tw.Flush()
+
+	// Scheduling and timer overhead is multiplied back up with the latencies
+	if r.Speed > 1 {
+		fmt.Fprintf(w, "\nTimings are scaled up %gx from a sped-up run and include scheduler overhead scaled the same way;\nshort latencies read high. Lower -speed for accurate percentiles.\n", r.Speed)
+	}
+}
+
+func round(d time.Duration) time.Duration {
+	return d.Round(100 * time.Microsecond)
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bytes"
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSummarize(t *testing.T) {
+	if got := summarize(nil); got != (LatencySummary{}) {
+		t.Errorf("expected zero summary for no samples, got %+v", got)
+	}
+
+	// 1ms..100ms in random order
+	samples := make([]time.Duration, 100)
+	for i := range samples {
+		samples[i] = time.Duration(i+1) * time.Millisecond
+	}
+	rand.New(rand.NewSource(1)).Shuffle(len(samples), func(i, j int) {
+		samples[i], samples[j] = samples[j], samples[i]
+	})
+	first := samples[0]
+
+	want := LatencySummary{P50: 50 * time.Millisecond, P95: 95 * time.Millisecond, P99: 99 * time.Millisecond, Max: 100 * time.Millisecond}
+	if got := summarize(samples); got != want {
+		t.Errorf("expected %+v, got %+v", want, got)
+	}
+	if samples[0] != first {
+		t.Error("expected summarize to leave the samples unsorted")
+	}
+
+	one := []time.Duration{7 * time.Millisecond}
+	if got := summarize(one); got.P50 != one[0] || got.P99 != one[0] || got.Max != one[0] {
+		t.Errorf("expected a single sample to be every percentile, got %+v", got)
+	}
+}
+
+func TestReport_Print(t *testing.T) {
+	r := &Report{
+		Scenario:  "demo",
+		Items:     10,
+		Speed:     20,
+		Elapsed:   2 * time.Second,
+		Succeeded: 8,
+		Stages:    []StageReport{{Name: "fetch", Workers: 2, Processed: 10, Failed: 2}},
+	}
+	var buf bytes.Buffer
+	r.Print(&buf)
+	out := buf.String()
+	for _, want := range []string{
+		`Scenario "demo": 10 items in 2s`,
+		"Succeeded: 8, failed: 2, throughput: 4.0 items/s",
+		"fetch",
+		"end-to-end",
+		"WAIT P95",
+		"scaled up 20x",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %q in report:\n%s", want, out)
+		}
+	}
+	buf.Reset()
+	r.Speed = 1
+	r.Print(&buf)
+	if strings.Contains(buf.String(), "scaled up") {
+		t.Error("expected no distortion note at real-time speed")
+	}
+	if got := throughput(5, 0); got != 0 {
+		t.Errorf("expected zero throughput for zero elapsed time, got %v", got)
+	}
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/rinkachi/golang-demos/golang-concurrency-patterns/patterns"
+)
+
+var errSimulated = errors.New("simulated failure")
+
+// item flows through the stages.
+type item struct {
+	id      int
+	started time.Time
+}
+
+// stageStats collects what a stage did. Latencies are service times; the
+// time spent queued on the stage's rate limit is kept apart in waits.
+type stageStats struct {
+	mu        sync.Mutex
+	latencies []time.Duration
+	waits     []time.Duration
+	failed    int
+}
+
+func (s *stageStats) record(wait, d time.Duration, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.waits = append(s.waits, wait)
+	s.latencies = append(s.latencies, d)
+	if err != nil {
+		s.failed++
+	}
+}
+
+// runner executes a scenario, speed times faster than real time.
+type runner struct {
+	sc    *Scenario
+	speed float64
+
+	mu  sync.Mutex
+	rnd *rand.Rand
+}
+
+func newRunner(sc *Scenario, speed float64) *runner {
+	seed := sc.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	return &runner{sc: sc, speed: speed, rnd: rand.New(rand.NewSource(seed))}
+}
+
+// draw samples a latency and whether the item fails.
+func (r *runner) draw(st Stage) (time.Duration, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return st.Latency.sample(r.rnd), r.rnd.Float64() < st.FailureRate
+}
+
+// run pushes every item through the stages: each stage is a StreamPool, and
+// items that fail a stage are dropped from the rest of the pipeline.
+func (r *runner) run(ctx context.Context) (*Report, error) {
+	if r.sc.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.scale(time.Duration(r.sc.Timeout)))
+		defer cancel()
+	}
+
+	tracer := otel.Tracer("simulate")
+	ctx, span := tracer.Start(ctx, "scenario", trace.WithAttributes(
+		attribute.String("scenario.name", r.sc.Name),
+		attribute.Int("scenario.items", r.sc.Items),
+	))
+	defer span.End()
+
+	ids := make([]int, r.sc.Items)
+	for i := range ids {
+		ids[i] = i
+	}
+	start := time.Now()
+	in := patterns.Map(ctx, patterns.Generator(ctx, ids...), func(id int) item {
+		return item{id: id, started: time.Now()}
+	})
+
+	stats := make([]*stageStats, len(r.sc.Stages))
+	for i, st := range r.sc.Stages {
+		stats[i] = &stageStats{}
+		in = r.stage(ctx, in, st, stats[i])
+	}
+
+	var endToEnd []time.Duration
+	for it := range in {
+		endToEnd = append(endToEnd, r.unscale(time.Since(it.started)))
+	}
+	elapsed := r.unscale(time.Since(start))
+
+	rep := newReport(r.sc, r.speed, elapsed, stats, endToEnd)
+	if err := ctx.Err(); err != nil {
+		span.RecordError(err)
+		return rep, fmt.Errorf("scenario stopped early: %w", err)
+	}
+	return rep, nil
+}
+
+func (r *runner) stage(ctx context.Context, in <-chan item, st Stage, stats *stageStats) <-chan item {
+	var limiter *patterns.RateLimiter
+	if st.RateLimit != nil {
+		limiter = patterns.NewRateLimiter(st.RateLimit.Rate*r.speed, st.RateLimit.Burst)
+	}
+
+	tracer := otel.Tracer("simulate")
+	worker := func(ctx context.Context, it item) (item, error) {
+		ctx, span := tracer.Start(ctx, st.Name, trace.WithAttributes(attribute.Int("item.id", it.id)))
+		defer span.End()
+
+		queued := time.Now()
+		err := r.wait(ctx, limiter)
+		begin := time.Now()
+		if err == nil {
+			err = r.process(ctx, st)
+		}
+		stats.record(r.unscale(begin.Sub(queued)), r.unscale(time.Since(begin)), err)
+		if err != nil {
+			span.RecordError(err)
+		}
+		return it, err
+	}
+
+	out := make(chan item)
+	results := patterns.WorkerPoolStream(ctx, in, worker, st.Workers)
+	go func() {
+		defer close(out)
+		for res := range results {
+			if res.Err != nil {
+				continue
+			}
+			select {
+			case <-ctx.Done():
+			case out <- res.Value:
+			}
+		}
+	}()
+	return out
+}
+
+// wait queues on the stage's rate limit, if it has one.
+func (r *runner) wait(ctx context.Context, limiter *patterns.RateLimiter) error {
+	if limiter == nil {
+		return nil
+	}
+	return limiter.Wait(ctx)
+}
+
+// process simulates the work on one item.
+func (r *runner) process(ctx context.Context, st Stage) error {
+	latency, fail := r.draw(st)
+
+	timer := time.NewTimer(r.scale(latency))
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+	}
+	if fail {
+		return errSimulated
+	}
+	return nil
+}
+
+func (r *runner) scale(d time.Duration) time.Duration {
+	return time.Duration(float64(d) / r.speed)
+}
+
+func (r *runner) unscale(d time.Duration) time.Duration {
+	return time.Duration(float64(d) * r.speed)
+}
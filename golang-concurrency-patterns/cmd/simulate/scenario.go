@@ -0,0 +1,145 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Duration is a time.Duration written as "50ms" in YAML.
+type Duration time.Duration
+
+func (d *Duration) UnmarshalYAML(node *yaml.Node) error {
+	var s string
+	if err := node.Decode(&s); err != nil {
+		return err
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return fmt.Errorf("line %d: %w", node.Line, err)
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// Scenario is a pipeline of stages that every item goes through in order.
+type Scenario struct {
+	Name    string   `yaml:"name"`
+	Items   int      `yaml:"items"`
+	Seed    int64    `yaml:"seed"`
+	Timeout Duration `yaml:"timeout"`
+	Stages  []Stage  `yaml:"stages"`
+}
+
+// Stage is processed by a pool of workers. Each item takes a latency drawn
+// from the distribution and fails with the given probability.
+type Stage struct {
+	Name        string     `yaml:"name"`
+	Workers     int        `yaml:"workers"`
+	Latency     Latency    `yaml:"latency"`
+	FailureRate float64    `yaml:"failure_rate"`
+	RateLimit   *RateLimit `yaml:"rate_limit"`
+}
+
+// RateLimit caps how many items per second a stage starts.
+type RateLimit struct {
+	Rate  float64 `yaml:"rate"`
+	Burst int     `yaml:"burst"`
+}
+
+// Latency is a simulated latency distribution:
+//
+//	constant:    value
+//	uniform:     min, max
+//	normal:      mean, stddev (never below zero)
+//	exponential: mean
+type Latency struct {
+	Distribution string   `yaml:"distribution"`
+	Value        Duration `yaml:"value"`
+	Min          Duration `yaml:"min"`
+	Max          Duration `yaml:"max"`
+	Mean         Duration `yaml:"mean"`
+	StdDev       Duration `yaml:"stddev"`
+}
+
+func loadScenario(path string) (*Scenario, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var sc Scenario
+	if err := yaml.Unmarshal(data, &sc); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	if err := sc.validate(); err != nil {
+		return nil, fmt.Errorf("invalid scenario %s: %w", path, err)
+	}
+	return &sc, nil
+}
+
+func (sc *Scenario) validate() error {
+	var errs []error
+	if sc.Items <= 0 {
+		errs = append(errs, errors.New("items must be positive"))
+	}
+	if len(sc.Stages) == 0 {
+		errs = append(errs, errors.New("at least one stage is required"))
+	}
+	for i, st := range sc.Stages {
+		name := st.Name
+		if name == "" {
+			name = fmt.Sprintf("#%d", i+1)
+		}
+		if st.Workers <= 0 {
+			errs = append(errs, fmt.Errorf("stage %s: workers must be positive", name))
+		}
+		if st.FailureRate < 0 || st.FailureRate > 1 {
+			errs = append(errs, fmt.Errorf("stage %s: failure_rate must be between 0 and 1", name))
+		}
+		if st.RateLimit != nil && (st.RateLimit.Rate <= 0 || st.RateLimit.Burst <= 0) {
+			errs = append(errs, fmt.Errorf("stage %s: rate_limit needs a positive rate and burst", name))
+		}
+		if err := st.Latency.validate(); err != nil {
+			errs = append(errs, fmt.Errorf("stage %s: %w", name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (l Latency) validate() error {
+	switch l.Distribution {
+	case "", "constant", "exponential":
+	case "uniform":
+		if l.Max < l.Min {
+			return errors.New("latency: max is below min")
+		}
+	case "normal":
+		if l.StdDev < 0 {
+			return errors.New("latency: stddev is negative")
+		}
+	default:
+		return fmt.Errorf("latency: unknown distribution %q", l.Distribution)
+	}
+	return nil
+}
+
+// sample draws a latency. rnd must not be used concurrently.
+func (l Latency) sample(rnd *rand.Rand) time.Duration {
+	var d float64
+	switch l.Distribution {
+	case "uniform":
+		d = float64(l.Min) + rnd.Float64()*float64(l.Max-l.Min)
+	case "normal":
+		d = float64(l.Mean) + rnd.NormFloat64()*float64(l.StdDev)
+	case "exponential":
+		d = rnd.ExpFloat64() * float64(l.Mean)
+	default:
+		d = float64(l.Value)
+	}
+	return time.Duration(math.Max(d, 0))
+}
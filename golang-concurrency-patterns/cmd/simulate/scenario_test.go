@@ -0,0 +1,130 @@
+package main
+
+import (
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeScenario(t *testing.T, yaml string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "scenario.yaml")
+	if err := os.WriteFile(path, []byte(yaml), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadScenario(t *testing.T) {
+	sc, err := loadScenario(writeScenario(t, `
+name: demo
+items: 10
+seed: 7
+timeout: 2s
+stages:
+  - name: fetch
+    workers: 4
+    latency: {distribution: uniform, min: 10ms, max: 30ms}
+    failure_rate: 0.1
+    rate_limit: {rate: 50, burst: 5}
+  - name: store
+    workers: 1
+    latency: {value: 5ms}
+`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if sc.Name != "demo" || sc.Items != 10 || sc.Seed != 7 || time.Duration(sc.Timeout) != 2*time.Second {
+		t.Errorf("unexpected scenario header %+v", sc)
+	}
+	if len(sc.Stages) != 2 {
+		t.Fatalf("expected 2 stages, got %d", len(sc.Stages))
+	}
+	fetch := sc.Stages[0]
+	if fetch.Workers != 4 || fetch.FailureRate != 0.1 || fetch.RateLimit == nil || fetch.RateLimit.Burst != 5 {
+		t.Errorf("unexpected stage %+v", fetch)
+	}
+	if time.Duration(fetch.Latency.Min) != 10*time.Millisecond || time.Duration(fetch.Latency.Max) != 30*time.Millisecond {
+		t.Errorf("unexpected latency %+v", fetch.Latency)
+	}
+	if sc.Stages[1].RateLimit != nil {
+		t.Error("expected no rate limit on the second stage")
+	}
+}
+
+func TestLoadScenario_Examples(t *testing.T) {
+	paths, err := filepath.Glob("../../scenarios/*.yaml")
+	if err != nil || len(paths) == 0 {
+		t.Fatalf("no example scenarios found: %v", err)
+	}
+	for _, path := range paths {
+		if _, err := loadScenario(path); err != nil {
+			t.Errorf("%s: %v", path, err)
+		}
+	}
+}
+
+func TestLoadScenario_BadDuration(t *testing.T) {
+	_, err := loadScenario(writeScenario(t, `
+items: 1
+stages:
+  - workers: 1
+    latency: {value: soon}
+`))
+	if err == nil || !strings.Contains(err.Error(), "line 5") {
+		t.Errorf("expected a parse error pointing at line 5, got %v", err)
+	}
+}
+
+func TestScenario_Validate(t *testing.T) {
+	sc := Scenario{
+		Stages: []Stage{
+			{Name: "a", Workers: 0, FailureRate: 1.5},
+			{Workers: 1, RateLimit: &RateLimit{Rate: 10}},
+			{Name: "c", Workers: 1, Latency: Latency{Distribution: "uniform", Min: Duration(time.Second)}},
+			{Name: "d", Workers: 1, Latency: Latency{Distribution: "normal", StdDev: Duration(-time.Second)}},
+			{Name: "e", Workers: 1, Latency: Latency{Distribution: "pareto"}},
+		},
+	}
+	err := sc.validate()
+	if err == nil {
+		t.Fatal("expected validation errors")
+	}
+	for _, want := range []string{
+		"items must be positive",
+		"stage a: workers must be positive",
+		"stage a: failure_rate must be between 0 and 1",
+		"stage #2: rate_limit needs a positive rate and burst",
+		"stage c: latency: max is below min",
+		"stage d: latency: stddev is negative",
+		`stage e: latency: unknown distribution "pareto"`,
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected %q in %v", want, err)
+		}
+	}
+
+	if err := (&Scenario{}).validate(); err == nil || !strings.Contains(err.Error(), "at least one stage is required") {
+		t.Errorf("expected missing stages to be reported, got %v", err)
+	}
+}
+
+func TestLatency_Sample(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	if d := (Latency{Value: Duration(5 * time.Millisecond)}).sample(rnd); d != 5*time.Millisecond {
+		t.Errorf("expected constant 5ms, got %v", d)
+	}
+	uniform := Latency{Distribution: "uniform", Min: Duration(10 * time.Millisecond), Max: Duration(20 * time.Millisecond)}
+	normal := Latency{Distribution: "normal", StdDev: Duration(time.Second)}
+	for i := 0; i < 1000; i++ {
+		if d := uniform.sample(rnd); d < 10*time.Millisecond || d > 20*time.Millisecond {
+			t.Fatalf("uniform sample %v out of range", d)
+		}
+		if d := normal.sample(rnd); d < 0 {
+			t.Fatalf("normal sample %v below zero", d)
+		}
+	}
+}
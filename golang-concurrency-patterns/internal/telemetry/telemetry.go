@@ -0,0 +1,81 @@
+// Package telemetry sets up the trace exporter shared by the simulation binaries.
+package telemetry
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+)
+
+// Exporters lists the accepted values of Config.Exporter.
+var Exporters = []string{"otlp", "stdout", "none"}
+
+// Config selects where traces go.
+type Config struct {
+	ServiceName string
+	Exporter    string    // otlp, stdout or none
+	Endpoint    string    // OTLP gRPC endpoint, default localhost:4317
+	Writer      io.Writer // stdout exporter output, default os.Stdout
+}
+
+// Resource describes the running service.
+func Resource(ctx context.Context, serviceName string) (*resource.Resource, error) {
+	return resource.New(ctx,
+		resource.WithAttributes(
+			semconv.ServiceName(serviceName),
+		),
+	)
+}
+
+// SetupTracing installs a global TracerProvider for cfg and returns its shutdown function.
+// With the "none" exporter nothing is installed and spans are dropped.
+func SetupTracing(ctx context.Context, cfg Config) (func(context.Context) error, error) {
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch cfg.Exporter {
+	case "none":
+		return func(context.Context) error { return nil }, nil
+	case "otlp", "":
+		endpoint := cfg.Endpoint
+		if endpoint == "" {
+			endpoint = "localhost:4317"
+		}
+		// The gRPC connection is established lazily, so a missing collector
+		// only costs dropped spans, not a failed start
+		exporter, err = otlptracegrpc.New(ctx,
+			otlptracegrpc.WithEndpoint(endpoint),
+			otlptracegrpc.WithInsecure(),
+		)
+	case "stdout":
+		w := cfg.Writer
+		if w == nil {
+			w = os.Stdout
+		}
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(w), stdouttrace.WithPrettyPrint())
+	default:
+		return nil, fmt.Errorf("unknown exporter %q (want one of %v)", cfg.Exporter, Exporters)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("create %s exporter: %w", cfg.Exporter, err)
+	}
+
+	res, err := Resource(ctx, cfg.ServiceName)
+	if err != nil {
+		return nil, fmt.Errorf("create resource: %w", err)
+	}
+
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(tp)
+	return tp.Shutdown, nil
+}
@@ -0,0 +1,52 @@
+package telemetry
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+)
+
+func TestSetupTracing_UnknownExporter(t *testing.T) {
+	_, err := SetupTracing(context.Background(), Config{ServiceName: "test", Exporter: "zipkin"})
+	if err == nil || !strings.Contains(err.Error(), `unknown exporter "zipkin"`) {
+		t.Errorf("expected unknown exporter error, got %v", err)
+	}
+}
+
+func TestSetupTracing_None(t *testing.T) {
+	before := otel.GetTracerProvider()
+	shutdown, err := SetupTracing(context.Background(), Config{ServiceName: "test", Exporter: "none"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := shutdown(context.Background()); err != nil {
+		t.Errorf("unexpected shutdown error: %v", err)
+	}
+	if otel.GetTracerProvider() != before {
+		t.Error("expected the none exporter to leave the global provider alone")
+	}
+}
+
+func TestSetupTracing_Stdout(t *testing.T) {
+	before := otel.GetTracerProvider()
+	defer otel.SetTracerProvider(before)
+
+	var buf bytes.Buffer
+	shutdown, err := SetupTracing(context.Background(), Config{ServiceName: "sim-test", Exporter: "stdout", Writer: &buf})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	_, span := otel.Tracer("test").Start(context.Background(), "test_span")
+	span.End()
+	if err := shutdown(context.Background()); err != nil {
+		t.Fatalf("unexpected shutdown error: %v", err)
+	}
+	for _, want := range []string{"test_span", "sim-test"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("expected %q in exported spans", want)
+		}
+	}
+}
@@ -2,59 +2,76 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	"log"
 	"math/rand"
+	"net/http"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/rinkachi/golang-demos/golang-concurrency-patterns/internal/telemetry"
 	"github.com/rinkachi/golang-demos/golang-concurrency-patterns/patterns"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
-	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
-	"go.opentelemetry.io/otel/sdk/resource"
-	sdktrace "go.opentelemetry.io/otel/sdk/trace"
-	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/exporters/prometheus"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
 	"go.opentelemetry.io/otel/trace"
 )
 
-func initTracer() func(context.Context) error {
-	ctx := context.Background()
-
-	exporter, err := otlptracegrpc.New(ctx,
-		otlptracegrpc.WithEndpoint("localhost:4317"),
-		otlptracegrpc.WithInsecure(),
-	)
+// initMeter exports the pattern metrics in Prometheus format on :2112/metrics.
+func initMeter() func(context.Context) error {
+	res, err := telemetry.Resource(context.Background(), serviceName)
 	if err != nil {
-		log.Fatalf("failed to create exporter: %v", err)
+		log.Fatalf("failed to create resource: %v", err)
 	}
-
-	res, err := resource.New(ctx,
-		resource.WithAttributes(
-			semconv.ServiceName("concurrency-simulation"),
-		),
-	)
+	exporter, err := prometheus.New()
 	if err != nil {
-		log.Fatalf("failed to create resource: %v", err)
+		log.Fatalf("failed to create metrics exporter: %v", err)
 	}
 
-	tp := sdktrace.NewTracerProvider(
-		sdktrace.WithBatcher(exporter),
-		sdktrace.WithResource(res),
+	mp := sdkmetric.NewMeterProvider(
+		sdkmetric.WithReader(exporter),
+		sdkmetric.WithResource(res),
 	)
-	otel.SetTracerProvider(tp)
+	otel.SetMeterProvider(mp)
+	patterns.SetMeterProvider(mp)
+
+	go func() {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", promhttp.Handler())
+		if err := http.ListenAndServe(":2112", mux); err != nil {
+			log.Printf("metrics server stopped: %v", err)
+		}
+	}()
 
-	return tp.Shutdown
+	return mp.Shutdown
 }
 
+const serviceName = "concurrency-simulation"
+
 func main() {
-	shutdown := initTracer()
+	exporter := flag.String("exporter", "otlp", "trace exporter: otlp, stdout or none")
+	endpoint := flag.String("otlp-endpoint", "localhost:4317", "OTLP gRPC endpoint")
+	flag.Parse()
+
+	shutdown, err := telemetry.SetupTracing(context.Background(), telemetry.Config{
+		ServiceName: serviceName,
+		Exporter:    *exporter,
+		Endpoint:    *endpoint,
+	})
+	if err != nil {
+		log.Fatalf("failed to set up tracing: %v", err)
+	}
 	defer shutdown(context.Background())
+	shutdownMeter := initMeter()
+	defer shutdownMeter(context.Background())
 
 	tracer := otel.Tracer("simulation-main")
 	ctx, span := tracer.Start(context.Background(), "run_simulation")
 	defer span.End()
 
-	log.Println("Starting simulation... Open Jaeger to see traces!")
+	log.Println("Starting simulation... Open Jaeger to see traces and scrape :2112/metrics!")
 
 	// Scenario: Image Processing Pipeline
 	// 1. Generate Images (Generator)
@@ -78,15 +95,20 @@ func main() {
 	g, gCtx := patterns.WithContext(ctx)
 
 	// Phase 1: Processing Pipeline
-	g.Go(gCtx, func() error {
+	g.GoNamed(gCtx, "processing", func() error {
 		defer barrier.Await(gCtx, "processing_done")
 
+		// Metadata is memoized and concurrent fetches for one image are collapsed
+		metadata := patterns.NewMemoCache(func(ctx context.Context, id int) (string, error) {
+			time.Sleep(time.Duration(rand.Intn(100)) * time.Millisecond)
+			return fmt.Sprintf("Meta-%d", id), nil
+		}, patterns.WithCacheTTL(time.Minute), patterns.WithStaleWhileRevalidate(time.Minute), patterns.WithMaxEntries(1000))
+
 		// Processing Worker Function
 		processor := func(ctx context.Context, id int) (string, error) {
 			// Simulate Async Metadata Fetch using Future
 			metaFuture := patterns.Async(ctx, func(ctx context.Context) (string, error) {
-				time.Sleep(time.Duration(rand.Intn(100)) * time.Millisecond)
-				return fmt.Sprintf("Meta-%d", id), nil
+				return metadata.Get(ctx, id)
 			})
 
 			// Simulate Heavy Computation (Resize)
@@ -110,18 +132,12 @@ func main() {
 			return fmt.Sprintf("Image %d processed with %s", id, meta), nil
 		}
 
-		// Run Worker Pool
-		// We collect items from generator first to pass slice (simpler for this demo adaptation)
-		var workItems []int
-		for i := range images {
-			workItems = append(workItems, i)
-		}
-
-		results := patterns.WorkerPool(ctx, workItems, processor, 3) // 3 Concurrent workers
+		// Run Worker Pool straight off the generator, results in input order
+		results := patterns.WorkerPoolStream(ctx, images, processor, 3, patterns.PreserveOrder()) // 3 Concurrent workers
 
 		for res := range results {
 			if res.Err != nil {
-				log.Printf("Error: %v", res.Err)
+				log.Printf("Error (image #%d): %v", res.Index, res.Err)
 			} else {
 				log.Println(res.Value)
 			}
@@ -130,7 +146,7 @@ func main() {
 	})
 
 	// Phase 2: Analytics (Simulated background task)
-	g.Go(gCtx, func() error {
+	g.GoNamed(gCtx, "analytics", func() error {
 		defer barrier.Await(gCtx, "analytics_done")
 		// Simulate parallel analytics task
 		_, span := tracer.Start(gCtx, "analytics_batch")
@@ -0,0 +1,438 @@
+package actor
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"sync"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/rinkachi/golang-demos/golang-concurrency-patterns/patterns"
+)
+
+// Actor handles the messages of one mailbox, one at a time, so its state
+// needs no locking.
+type Actor[M any] interface {
+	Receive(ctx context.Context, msg M) error
+}
+
+// ActorFunc adapts a function to the Actor interface.
+type ActorFunc[M any] func(ctx context.Context, msg M) error
+
+func (f ActorFunc[M]) Receive(ctx context.Context, msg M) error {
+	return f(ctx, msg)
+}
+
+// Directive is a supervision decision for a failed message.
+type Directive int
+
+const (
+	// Restart replaces the actor with a fresh one from its factory and goes on
+	// with the next message.
+	Restart Directive = iota
+	// Resume keeps the actor and its state and goes on with the next message.
+	Resume
+	// Stop stops the actor.
+	Stop
+	// Escalate stops the actor and reports the failure to its parent, which
+	// handles it as a failure of its own.
+	Escalate
+)
+
+func (d Directive) String() string {
+	switch d {
+	case Restart:
+		return "restart"
+	case Resume:
+		return "resume"
+	case Stop:
+		return "stop"
+	case Escalate:
+		return "escalate"
+	default:
+		return "unknown"
+	}
+}
+
+// SpawnOption configures an actor.
+type SpawnOption func(*spawnConfig)
+
+type spawnConfig struct {
+	mailbox   int
+	parent    Addr
+	supervise func(err error) Directive
+}
+
+// WithMailboxSize bounds the mailbox. Tell blocks while it is full. The default is 64.
+func WithMailboxSize(n int) SpawnOption {
+	return func(c *spawnConfig) {
+		if n > 0 {
+			c.mailbox = n
+		}
+	}
+}
+
+// WithParent links the actor to parent: it stops when parent stops and
+// escalated failures go to parent.
+func WithParent(parent Addr) SpawnOption {
+	return func(c *spawnConfig) {
+		c.parent = parent
+	}
+}
+
+// WithSupervision decides what happens when Receive fails or panics. The default is Restart.
+func WithSupervision(decide func(err error) Directive) SpawnOption {
+	return func(c *spawnConfig) {
+		c.supervise = decide
+	}
+}
+
+// envelope carries a message and the trace context of its sender.
+type envelope[M any] struct {
+	msg    M
+	span   trace.SpanContext
+	poison bool
+}
+
+// failure is a child failure escalated to this actor.
+type failure struct {
+	child string
+	err   error
+}
+
+// Ref is the address of an actor accepting messages of type M.
+type Ref[M any] struct {
+	sys     *System
+	name    string
+	factory func() Actor[M]
+	cfg     spawnConfig
+
+	mailbox  chan envelope[M]
+	failures chan failure
+	stop     chan struct{}
+	stopOnce sync.Once
+	exited   chan struct{} // closed when the loop returns, before shutdown
+	done     chan struct{}
+
+	mu       sync.Mutex
+	err      error
+	children []Addr
+	stopping bool // set before shutdown collects the children; no more can be linked
+}
+
+// Spawn starts an actor created by factory and registers it under name.
+// factory is called again each time the actor is restarted.
+func Spawn[M any](sys *System, name string, factory func() Actor[M], opts ...SpawnOption) (*Ref[M], error) {
+	cfg := spawnConfig{
+		mailbox:   64,
+		supervise: func(error) Directive { return Restart },
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	r := &Ref[M]{
+		sys:      sys,
+		name:     name,
+		factory:  factory,
+		cfg:      cfg,
+		mailbox:  make(chan envelope[M], cfg.mailbox),
+		failures: make(chan failure),
+		stop:     make(chan struct{}),
+		exited:   make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	if err := sys.register(r); err != nil {
+		return nil, err
+	}
+	// A parent that is stopping would never stop this child
+	if cfg.parent != nil && !cfg.parent.link(r) {
+		sys.unregister(r)
+		return nil, fmt.Errorf("%w: parent %s", ErrActorStopped, cfg.parent.Name())
+	}
+	go r.run(factory())
+	return r, nil
+}
+
+// Name returns the registered name.
+func (r *Ref[M]) Name() string {
+	return r.name
+}
+
+// Tell sends msg, waiting for mailbox room until ctx ends. The sender's span,
+// if any, becomes the parent of the receive span.
+func (r *Ref[M]) Tell(ctx context.Context, msg M) error {
+	return r.send(ctx, envelope[M]{msg: msg, span: trace.SpanContextFromContext(ctx)})
+}
+
+// TryTell sends msg only if the mailbox has room.
+func (r *Ref[M]) TryTell(ctx context.Context, msg M) error {
+	select {
+	case <-r.exited:
+		return ErrActorStopped
+	default:
+	}
+	select {
+	case r.mailbox <- envelope[M]{msg: msg, span: trace.SpanContextFromContext(ctx)}:
+		return nil
+	default:
+		return ErrMailboxFull
+	}
+}
+
+// Poison asks the actor to stop once it has handled the messages sent before.
+func (r *Ref[M]) Poison(ctx context.Context) error {
+	return r.send(ctx, envelope[M]{poison: true})
+}
+
+// send refuses messages as soon as the loop has exited: nothing would read
+// them while the children are still being stopped.
+func (r *Ref[M]) send(ctx context.Context, env envelope[M]) error {
+	select {
+	case <-r.exited:
+		return ErrActorStopped
+	default:
+	}
+	select {
+	case r.mailbox <- env:
+		return nil
+	case <-r.exited:
+		return ErrActorStopped
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Stop stops the actor after the message in progress; queued messages are dropped.
+func (r *Ref[M]) Stop() {
+	r.stopOnce.Do(func() { close(r.stop) })
+}
+
+// Done is closed once the actor and its children have stopped.
+func (r *Ref[M]) Done() <-chan struct{} {
+	return r.done
+}
+
+// Err returns why the actor stopped: nil for Stop and poison pills, the
+// failure for the Stop and Escalate directives.
+func (r *Ref[M]) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+// escalate hands a child failure to the loop. It gives up once the loop has
+// exited, so a child never blocks a parent that is stopping and waiting for it.
+func (r *Ref[M]) escalate(child string, err error) {
+	select {
+	case r.failures <- failure{child: child, err: err}:
+	case <-r.exited:
+	}
+}
+
+// link adds a child. It fails once the actor is stopping.
+func (r *Ref[M]) link(child Addr) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.stopping {
+		return false
+	}
+	r.children = append(r.children, child)
+	return true
+}
+
+func (r *Ref[M]) unlink(child Addr) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, c := range r.children {
+		if c == child {
+			r.children = append(r.children[:i], r.children[i+1:]...)
+			return
+		}
+	}
+}
+
+// run drives the actor, then stops it. A failure to escalate is reported to
+// the parent only once this actor and its children have stopped.
+func (r *Ref[M]) run(actor Actor[M]) {
+	ctx, cancel := context.WithCancel(context.Background())
+	escalated := r.loop(ctx, actor)
+	cancel()
+	close(r.exited)
+	r.shutdown()
+	if escalated != nil && r.cfg.parent != nil {
+		r.cfg.parent.escalate(r.name, escalated)
+	}
+}
+
+// loop handles messages until the actor stops. It returns the failure to
+// escalate, if any.
+func (r *Ref[M]) loop(ctx context.Context, actor Actor[M]) error {
+	for {
+		// A stop request wins over pending messages
+		select {
+		case <-r.stop:
+			return nil
+		default:
+		}
+
+		var err error
+		var directive Directive
+		select {
+		case <-r.stop:
+			return nil
+		case f := <-r.failures:
+			err, directive = r.childFailed(f)
+		case env := <-r.mailbox:
+			if env.poison {
+				return nil
+			}
+			err, directive = r.receive(ctx, actor, env)
+		}
+		if err == nil {
+			continue
+		}
+
+		switch directive {
+		case Resume:
+		case Restart:
+			actor = r.factory()
+		case Stop:
+			r.fail(err)
+			return nil
+		case Escalate:
+			r.fail(err)
+			return err
+		}
+	}
+}
+
+// childFailed handles a failure escalated by a child as a failure of this actor.
+func (r *Ref[M]) childFailed(f failure) (error, Directive) {
+	tracer := otel.Tracer("actor")
+	_, span := tracer.Start(context.Background(), "actor_child_failure", trace.WithAttributes(
+		attribute.String("actor.name", r.name),
+		attribute.String("actor.child", f.child),
+	))
+	defer span.End()
+
+	err := fmt.Errorf("child %s failed: %w", f.child, f.err)
+	directive := r.cfg.supervise(err)
+	span.RecordError(err)
+	span.SetAttributes(attribute.String("actor.directive", directive.String()))
+	return err, directive
+}
+
+// receive runs Receive under a span parented on the sender's span. A panic
+// counts as a failure; failures are passed to the supervision function.
+func (r *Ref[M]) receive(ctx context.Context, actor Actor[M], env envelope[M]) (err error, directive Directive) {
+	if env.span.IsValid() {
+		ctx = trace.ContextWithRemoteSpanContext(ctx, env.span)
+	}
+	tracer := otel.Tracer("actor")
+	ctx, span := tracer.Start(ctx, "actor_receive", trace.WithAttributes(
+		attribute.String("actor.name", r.name),
+		attribute.String("actor.system", r.sys.Name()),
+	))
+	defer span.End()
+
+	defer func() {
+		if p := recover(); p != nil {
+			err = &patterns.PanicError{Value: p, Stack: debug.Stack()}
+		}
+		if err != nil {
+			directive = r.cfg.supervise(err)
+			span.RecordError(err)
+			span.SetAttributes(attribute.String("actor.directive", directive.String()))
+		}
+	}()
+	return actor.Receive(ctx, env.msg), Resume
+}
+
+func (r *Ref[M]) fail(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.err = err
+}
+
+// shutdown stops the children, newest first, then unregisters the actor.
+func (r *Ref[M]) shutdown() {
+	r.mu.Lock()
+	r.stopping = true
+	children := append([]Addr(nil), r.children...)
+	r.mu.Unlock()
+	for i := len(children) - 1; i >= 0; i-- {
+		children[i].Stop()
+		<-children[i].Done()
+	}
+
+	r.sys.unregister(r)
+	if r.cfg.parent != nil {
+		r.cfg.parent.unlink(r)
+	}
+	close(r.done)
+}
+
+// Reply is how an actor answers an Ask.
+type Reply[R any] struct {
+	ch chan askResult[R]
+}
+
+type askResult[R any] struct {
+	val R
+	err error
+}
+
+// Send answers with v. Only the first answer counts.
+func (r Reply[R]) Send(v R) {
+	select {
+	case r.ch <- askResult[R]{val: v}:
+	default:
+	}
+}
+
+// Fail answers with err.
+func (r Reply[R]) Fail(err error) {
+	select {
+	case r.ch <- askResult[R]{err: err}:
+	default:
+	}
+}
+
+// Ask sends the message built by msg and waits for the actor to answer through
+// the Reply, until ctx ends or the actor stops.
+func Ask[M, R any](ctx context.Context, ref *Ref[M], msg func(Reply[R]) M) (R, error) {
+	tracer := otel.Tracer("actor")
+	ctx, span := tracer.Start(ctx, "actor_ask", trace.WithAttributes(attribute.String("actor.name", ref.name)))
+	defer span.End()
+
+	var zero R
+	reply := Reply[R]{ch: make(chan askResult[R], 1)}
+	if err := ref.Tell(ctx, msg(reply)); err != nil {
+		span.RecordError(err)
+		return zero, err
+	}
+
+	select {
+	case res := <-reply.ch:
+		if res.err != nil {
+			span.RecordError(res.err)
+		}
+		return res.val, res.err
+	case <-ref.done:
+		// The answer may have been sent just before the actor stopped
+		select {
+		case res := <-reply.ch:
+			return res.val, res.err
+		default:
+		}
+		span.RecordError(ErrActorStopped)
+		return zero, ErrActorStopped
+	case <-ctx.Done():
+		span.RecordError(ctx.Err())
+		return zero, ctx.Err()
+	}
+}
@@ -0,0 +1,334 @@
+package actor
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rinkachi/golang-demos/golang-concurrency-patterns/patterns/patternstest"
+)
+
+// counterMsg is either an increment or a query for the current count.
+type counterMsg struct {
+	add     int
+	fail    bool
+	get     *Reply[int]
+	block   chan struct{}
+	blocked chan struct{} // closed once the actor waits on block
+}
+
+func newCounter() Actor[counterMsg] {
+	n := 0
+	return ActorFunc[counterMsg](func(ctx context.Context, msg counterMsg) error {
+		switch {
+		case msg.block != nil:
+			if msg.blocked != nil {
+				close(msg.blocked)
+			}
+			<-msg.block
+		case msg.fail:
+			return errors.New("boom")
+		case msg.get != nil:
+			msg.get.Send(n)
+		default:
+			n += msg.add
+		}
+		return nil
+	})
+}
+
+func get(ctx context.Context, ref *Ref[counterMsg]) (int, error) {
+	return Ask(ctx, ref, func(r Reply[int]) counterMsg { return counterMsg{get: &r} })
+}
+
+func TestActor_TellAndAsk(t *testing.T) {
+	sys := NewSystem("test")
+	defer sys.Shutdown(context.Background())
+
+	ref, err := Spawn(sys, "counter", newCounter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	for i := 1; i <= 3; i++ {
+		if err := ref.Tell(ctx, counterMsg{add: i}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n, err := get(ctx, ref); err != nil || n != 6 {
+		t.Fatalf("got %d, %v; want 6", n, err)
+	}
+
+	// An actor that never answers makes Ask time out
+	silent, _ := Spawn(sys, "silent", func() Actor[counterMsg] {
+		return ActorFunc[counterMsg](func(context.Context, counterMsg) error { return nil })
+	})
+	tctx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	if _, err := get(tctx, silent); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("want deadline exceeded, got %v", err)
+	}
+}
+
+func TestActor_Lookup(t *testing.T) {
+	sys := NewSystem("test")
+	defer sys.Shutdown(context.Background())
+
+	ref, _ := Spawn(sys, "counter", newCounter)
+	if _, err := Spawn(sys, "counter", newCounter); !errors.Is(err, ErrNameTaken) {
+		t.Fatalf("want ErrNameTaken, got %v", err)
+	}
+
+	found, err := Lookup[counterMsg](sys, "counter")
+	if err != nil || found != ref {
+		t.Fatalf("lookup: %v", err)
+	}
+	if _, err := Lookup[string](sys, "counter"); err == nil {
+		t.Fatal("want an error for the wrong message type")
+	}
+	if _, err := Lookup[counterMsg](sys, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("want ErrNotFound, got %v", err)
+	}
+
+	ref.Stop()
+	<-ref.Done()
+	if _, err := Lookup[counterMsg](sys, "counter"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("stopped actor still registered: %v", err)
+	}
+	if err := ref.Tell(context.Background(), counterMsg{add: 1}); !errors.Is(err, ErrActorStopped) {
+		t.Fatalf("want ErrActorStopped, got %v", err)
+	}
+}
+
+func TestActor_BoundedMailbox(t *testing.T) {
+	sys := NewSystem("test")
+	defer sys.Shutdown(context.Background())
+
+	ref, _ := Spawn(sys, "counter", newCounter, WithMailboxSize(1))
+	ctx := context.Background()
+	block, blocked := make(chan struct{}), make(chan struct{})
+	if err := ref.Tell(ctx, counterMsg{block: block, blocked: blocked}); err != nil {
+		t.Fatal(err)
+	}
+	// Wait for the blocking message to leave the mailbox
+	<-blocked
+
+	if err := ref.TryTell(ctx, counterMsg{add: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ref.TryTell(ctx, counterMsg{add: 1}); !errors.Is(err, ErrMailboxFull) {
+		t.Fatalf("want ErrMailboxFull, got %v", err)
+	}
+	tctx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	if err := ref.Tell(tctx, counterMsg{add: 1}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("want Tell to block until the deadline, got %v", err)
+	}
+
+	close(block)
+	if n, err := get(ctx, ref); err != nil || n != 1 {
+		t.Fatalf("got %d, %v; want 1", n, err)
+	}
+}
+
+func TestActor_PoisonAndStop(t *testing.T) {
+	sys := NewSystem("test")
+	defer sys.Shutdown(context.Background())
+	ctx := context.Background()
+
+	var mu sync.Mutex
+	var seen []int
+	ref, _ := Spawn(sys, "recorder", func() Actor[int] {
+		return ActorFunc[int](func(_ context.Context, n int) error {
+			mu.Lock()
+			seen = append(seen, n)
+			mu.Unlock()
+			return nil
+		})
+	})
+	for i := 0; i < 3; i++ {
+		ref.Tell(ctx, i)
+	}
+	ref.Poison(ctx)
+	<-ref.Done()
+	if len(seen) != 3 || ref.Err() != nil {
+		t.Fatalf("poison pill should come after earlier messages: %v, %v", seen, ref.Err())
+	}
+
+	// Stop drops the queued messages
+	block := make(chan struct{})
+	stopped, _ := Spawn(sys, "stopped", newCounter)
+	stopped.Tell(ctx, counterMsg{block: block})
+	stopped.Tell(ctx, counterMsg{add: 1})
+	stopped.Stop()
+	close(block)
+	select {
+	case <-stopped.Done():
+	case <-time.After(time.Second):
+		t.Fatal("actor did not stop")
+	}
+	if _, err := get(ctx, stopped); !errors.Is(err, ErrActorStopped) {
+		t.Fatalf("want ErrActorStopped, got %v", err)
+	}
+}
+
+func TestActor_Supervision(t *testing.T) {
+	sys := NewSystem("test")
+	defer sys.Shutdown(context.Background())
+	ctx := context.Background()
+
+	// Restart loses the state, Resume keeps it
+	for _, tc := range []struct {
+		directive Directive
+		want      int
+	}{{Restart, 0}, {Resume, 5}} {
+		ref, _ := Spawn(sys, tc.directive.String(), newCounter,
+			WithSupervision(func(error) Directive { return tc.directive }))
+		ref.Tell(ctx, counterMsg{add: 5})
+		ref.Tell(ctx, counterMsg{fail: true})
+		if n, err := get(ctx, ref); err != nil || n != tc.want {
+			t.Fatalf("%s: got %d, %v; want %d", tc.directive, n, err, tc.want)
+		}
+	}
+
+	// Panics are failures too; Stop keeps the error
+	ref, _ := Spawn(sys, "panics", func() Actor[int] {
+		return ActorFunc[int](func(context.Context, int) error { panic("oops") })
+	}, WithSupervision(func(error) Directive { return Stop }))
+	ref.Tell(ctx, 1)
+	<-ref.Done()
+	if ref.Err() == nil {
+		t.Fatal("want the panic as the stop reason")
+	}
+}
+
+func TestActor_EscalateAndLinkedStop(t *testing.T) {
+	sys := NewSystem("test")
+	defer sys.Shutdown(context.Background())
+	ctx := context.Background()
+
+	parent, _ := Spawn(sys, "parent", newCounter,
+		WithSupervision(func(error) Directive { return Stop }))
+	child, _ := Spawn(sys, "child", newCounter, WithParent(parent),
+		WithSupervision(func(error) Directive { return Escalate }))
+	sibling, _ := Spawn(sys, "sibling", newCounter, WithParent(parent))
+
+	child.Tell(ctx, counterMsg{fail: true})
+	select {
+	case <-parent.Done():
+	case <-time.After(time.Second):
+		t.Fatal("escalated failure did not stop the parent")
+	}
+	if child.Err() == nil || !errors.Is(parent.Err(), child.Err()) {
+		t.Fatalf("parent should stop with the child failure: %v", parent.Err())
+	}
+	select {
+	case <-sibling.Done():
+	default:
+		t.Fatal("children must stop before their parent is done")
+	}
+	if names := sys.Actors(); len(names) != 0 {
+		t.Fatalf("actors still registered: %v", names)
+	}
+}
+
+func TestSystem_Shutdown(t *testing.T) {
+	patternstest.VerifyNoLeaks(t)
+	sys := NewSystem("test")
+	a, _ := Spawn(sys, "a", newCounter)
+	b, _ := Spawn(sys, "b", newCounter)
+
+	if err := sys.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	for _, ref := range []*Ref[counterMsg]{a, b} {
+		select {
+		case <-ref.Done():
+		default:
+			t.Fatalf("%s still running", ref.Name())
+		}
+	}
+	if _, err := Spawn(sys, "late", newCounter); !errors.Is(err, ErrSystemShutdown) {
+		t.Fatalf("want ErrSystemShutdown, got %v", err)
+	}
+}
+
+func TestActor_EscalateWhileParentStops(t *testing.T) {
+	sys := NewSystem("test")
+	defer sys.Shutdown(context.Background())
+
+	parent, _ := Spawn(sys, "parent", newCounter)
+	started, release := make(chan struct{}), make(chan struct{})
+	child, _ := Spawn(sys, "child", func() Actor[int] {
+		return ActorFunc[int](func(context.Context, int) error {
+			close(started)
+			<-release
+			return errors.New("boom")
+		})
+	}, WithParent(parent), WithSupervision(func(error) Directive { return Escalate }))
+
+	child.Tell(context.Background(), 1)
+	<-started
+	parent.Stop()
+	// Wait until the parent is stopping its children, then let the child fail
+	select {
+	case <-child.stop:
+	case <-time.After(time.Second):
+		t.Fatal("parent did not stop its child")
+	}
+	close(release)
+
+	select {
+	case <-parent.Done():
+	case <-time.After(time.Second):
+		t.Fatal("parent and escalating child deadlocked")
+	}
+	if child.Err() == nil {
+		t.Error("want the child failure recorded")
+	}
+}
+
+func TestActor_StoppingParent(t *testing.T) {
+	sys := NewSystem("test")
+	defer sys.Shutdown(context.Background())
+	ctx := context.Background()
+
+	parent, _ := Spawn(sys, "parent", newCounter)
+	child, _ := Spawn(sys, "child", newCounter, WithParent(parent))
+	release, blocked := make(chan struct{}), make(chan struct{})
+	child.Tell(ctx, counterMsg{block: release, blocked: blocked})
+	<-blocked
+
+	// The parent's loop has exited but it waits for the busy child
+	parent.Stop()
+	select {
+	case <-child.stop:
+	case <-time.After(time.Second):
+		t.Fatal("parent did not stop its child")
+	}
+
+	if _, err := Spawn(sys, "late", newCounter, WithParent(parent)); !errors.Is(err, ErrActorStopped) {
+		t.Errorf("want ErrActorStopped for a child of a stopping parent, got %v", err)
+	}
+	if _, err := Lookup[counterMsg](sys, "late"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("rejected child still registered: %v", err)
+	}
+	if err := parent.Tell(ctx, counterMsg{add: 1}); !errors.Is(err, ErrActorStopped) {
+		t.Errorf("Tell: want ErrActorStopped, got %v", err)
+	}
+	if err := parent.TryTell(ctx, counterMsg{add: 1}); !errors.Is(err, ErrActorStopped) {
+		t.Errorf("TryTell: want ErrActorStopped, got %v", err)
+	}
+	if err := parent.Poison(ctx); !errors.Is(err, ErrActorStopped) {
+		t.Errorf("Poison: want ErrActorStopped, got %v", err)
+	}
+
+	close(release)
+	select {
+	case <-parent.Done():
+	case <-time.After(time.Second):
+		t.Fatal("parent did not stop")
+	}
+}
@@ -0,0 +1,132 @@
+// Package actor is a lightweight actor system: typed actors with bounded
+// mailboxes, request/reply, a name registry, supervision and poison pills.
+package actor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+var (
+	// ErrNotFound is returned by Lookup for an unknown name.
+	ErrNotFound = errors.New("actor not found")
+	// ErrNameTaken is returned by Spawn when the name is already registered.
+	ErrNameTaken = errors.New("actor name already taken")
+	// ErrActorStopped is returned when sending to or asking a stopped actor,
+	// or when spawning a child of one.
+	ErrActorStopped = errors.New("actor is stopped")
+	// ErrMailboxFull is returned by TryTell when the mailbox has no room.
+	ErrMailboxFull = errors.New("actor mailbox is full")
+	// ErrSystemShutdown is returned by Spawn once the system is shutting down.
+	ErrSystemShutdown = errors.New("actor system is shut down")
+)
+
+// Addr is the untyped part of an actor reference.
+type Addr interface {
+	Name() string
+	Stop()
+	Done() <-chan struct{}
+	Err() error
+
+	escalate(child string, err error)
+	link(child Addr) bool
+	unlink(child Addr)
+}
+
+// System owns a set of actors and the registry of their names.
+type System struct {
+	name string
+
+	mu       sync.Mutex
+	actors   map[string]Addr
+	order    []Addr // spawn order, for shutdown
+	shutdown bool
+}
+
+func NewSystem(name string) *System {
+	return &System{name: name, actors: make(map[string]Addr)}
+}
+
+// Name returns the system name.
+func (s *System) Name() string {
+	return s.name
+}
+
+// Lookup returns the actor registered under name. It fails if there is none
+// or if it does not accept messages of type M.
+func Lookup[M any](s *System, name string) (*Ref[M], error) {
+	s.mu.Lock()
+	addr, ok := s.actors[name]
+	s.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+	ref, ok := addr.(*Ref[M])
+	if !ok {
+		var zero M
+		return nil, fmt.Errorf("actor %s does not accept %T messages", name, zero)
+	}
+	return ref, nil
+}
+
+// Actors returns the names of the running actors.
+func (s *System) Actors() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	names := make([]string, 0, len(s.order))
+	for _, a := range s.order {
+		names = append(names, a.Name())
+	}
+	return names
+}
+
+// Shutdown stops every actor, newest first, and waits until they have
+// stopped or ctx ends.
+func (s *System) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	s.shutdown = true
+	actors := append([]Addr(nil), s.order...)
+	s.mu.Unlock()
+
+	for i := len(actors) - 1; i >= 0; i-- {
+		actors[i].Stop()
+	}
+	for _, a := range actors {
+		select {
+		case <-a.Done():
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+func (s *System) register(a Addr) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.shutdown {
+		return ErrSystemShutdown
+	}
+	if _, ok := s.actors[a.Name()]; ok {
+		return fmt.Errorf("%w: %s", ErrNameTaken, a.Name())
+	}
+	s.actors[a.Name()] = a
+	s.order = append(s.order, a)
+	return nil
+}
+
+func (s *System) unregister(a Addr) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.actors[a.Name()] == a {
+		delete(s.actors, a.Name())
+	}
+	for i, other := range s.order {
+		if other == a {
+			s.order = append(s.order[:i], s.order[i+1:]...)
+			break
+		}
+	}
+}
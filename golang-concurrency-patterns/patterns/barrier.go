@@ -2,42 +2,173 @@ package patterns
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"sync"
+	"time"
 
 	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
 )
 
+// ErrBrokenBarrier is returned to every waiter of a generation that was
+// broken by a cancelled waiter, a failed barrier action or Reset.
+var ErrBrokenBarrier = errors.New("barrier is broken")
+
+// BarrierOption configures a Barrier.
+type BarrierOption func(*Barrier)
+
+// WithBarrierAction runs action by the last arriving party each time the barrier trips,
+// before the others are released. An error or panic breaks the barrier.
+func WithBarrierAction(action func() error) BarrierOption {
+	return func(b *Barrier) {
+		b.action = action
+	}
+}
+
+// generation is one use of a cyclic barrier.
+// trip is closed when the generation completes or breaks.
+type generation struct {
+	trip   chan struct{}
+	broken bool
+}
+
+func newGeneration() *generation {
+	return &generation{trip: make(chan struct{})}
+}
+
 // Barrier synchronizes a fixed number of parties.
+// It is cyclic: once all parties arrived it resets for the next round.
 type Barrier struct {
 	n      int
 	count  int
 	mutex  sync.Mutex
-	cond   *sync.Cond
+	gen    *generation
+	action func() error
 }
 
-func NewBarrier(n int) *Barrier {
+func NewBarrier(n int, opts ...BarrierOption) *Barrier {
 	b := &Barrier{
-		n: n,
+		n:   n,
+		gen: newGeneration(),
+	}
+	for _, opt := range opts {
+		opt(b)
 	}
-	b.cond = sync.NewCond(&b.mutex)
 	return b
 }
 
-func (b *Barrier) Await(ctx context.Context, id string) {
+// Await blocks until all parties arrived. If ctx ends first, the caller
+// gives up and breaks the barrier so the other waiters fail fast with
+// ErrBrokenBarrier instead of hanging.
+func (b *Barrier) Await(ctx context.Context, id string) error {
 	tracer := otel.Tracer("barrier")
 	_, span := tracer.Start(ctx, "barrier_await")
+	span.SetAttributes(attribute.String("barrier.party", id))
 	defer span.End()
 
+	start := time.Now()
+	err := b.await(ctx)
+	metrics().barrierWait.Record(ctx, sinceSeconds(start), patternAttr("barrier"))
+	if err != nil {
+		span.RecordError(err)
+	}
+	return err
+}
+
+func (b *Barrier) await(ctx context.Context) error {
 	b.mutex.Lock()
-	defer b.mutex.Unlock()
+	g := b.gen
+	if g.broken {
+		b.mutex.Unlock()
+		return ErrBrokenBarrier
+	}
+	if err := ctx.Err(); err != nil {
+		b.breakLocked()
+		b.mutex.Unlock()
+		return err
+	}
 
 	b.count++
 	if b.count == b.n {
-		// Last one arrived, wake everyone up
+		// Last one arrived, run the action and wake everyone up
+		if err := b.runAction(); err != nil {
+			b.breakLocked()
+			b.mutex.Unlock()
+			return err
+		}
 		b.count = 0
-		b.cond.Broadcast()
-	} else {
-		// Wait for others
-		b.cond.Wait()
+		b.gen = newGeneration()
+		close(g.trip)
+		b.mutex.Unlock()
+		return nil
+	}
+	b.mutex.Unlock()
+
+	// Wait for others
+	select {
+	case <-g.trip:
+	case <-ctx.Done():
+		b.mutex.Lock()
+		defer b.mutex.Unlock()
+		select {
+		case <-g.trip:
+			// Tripped at the same time, the round has completed
+		default:
+			b.breakLocked()
+			return ctx.Err()
+		}
 	}
+	if g.broken {
+		return ErrBrokenBarrier
+	}
+	return nil
+}
+
+func (b *Barrier) runAction() (err error) {
+	if b.action == nil {
+		return nil
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("barrier action panicked: %v", r)
+		}
+	}()
+	return b.action()
+}
+
+// breakLocked breaks the current generation. Must be called with mutex held.
+func (b *Barrier) breakLocked() {
+	if b.gen.broken {
+		return
+	}
+	b.gen.broken = true
+	b.count = 0
+	close(b.gen.trip)
+}
+
+// Reset breaks the current round, if any party is waiting, and starts a fresh one.
+func (b *Barrier) Reset() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if b.count > 0 {
+		b.breakLocked()
+	}
+	b.count = 0
+	b.gen = newGeneration()
+}
+
+// IsBroken reports whether the current round is broken.
+func (b *Barrier) IsBroken() bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.gen.broken
+}
+
+// Waiting returns the number of parties currently blocked in Await.
+func (b *Barrier) Waiting() int {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.count
 }
@@ -0,0 +1,375 @@
+package patterns
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+var (
+	// ErrBrokerClosed is returned when using a closed Broker.
+	ErrBrokerClosed = errors.New("broker is closed")
+	// ErrSubscriberDisconnected is reported by a subscription that fell behind under the Disconnect policy.
+	ErrSubscriberDisconnected = errors.New("subscriber disconnected: buffer full")
+)
+
+// OverflowPolicy decides what Publish does when a subscriber's buffer is full.
+type OverflowPolicy int
+
+const (
+	// Block makes the publisher wait until the subscriber has room. While it
+	// waits, Block subscribers after this one do not get the message either,
+	// so one slow Block subscriber slows down every other one on the topic.
+	Block OverflowPolicy = iota
+	// DropOldest discards the oldest buffered message to make room.
+	DropOldest
+	// DropNewest discards the message being published.
+	DropNewest
+	// Disconnect closes the subscription with ErrSubscriberDisconnected.
+	Disconnect
+)
+
+func (p OverflowPolicy) String() string {
+	switch p {
+	case Block:
+		return "block"
+	case DropOldest:
+		return "drop_oldest"
+	case DropNewest:
+		return "drop_newest"
+	case Disconnect:
+		return "disconnect"
+	}
+	return "unknown"
+}
+
+const defaultSubscriberBuffer = 16
+
+// SubscribeOption configures a subscription.
+type SubscribeOption func(*subscribeConfig)
+
+type subscribeConfig struct {
+	buffer int
+	policy OverflowPolicy
+}
+
+// WithBuffer sets the subscriber's buffer size.
+func WithBuffer(n int) SubscribeOption {
+	return func(c *subscribeConfig) {
+		if n > 0 {
+			c.buffer = n
+		}
+	}
+}
+
+// WithOverflow sets what happens when the subscriber falls behind.
+func WithOverflow(policy OverflowPolicy) SubscribeOption {
+	return func(c *subscribeConfig) {
+		c.policy = policy
+	}
+}
+
+// SubscriberStats describes how far a subscriber is behind.
+type SubscriberStats struct {
+	Topic     string
+	Policy    OverflowPolicy
+	Lag       int // messages buffered but not yet received
+	Capacity  int
+	Delivered uint64
+	Dropped   uint64
+}
+
+// Broker is an in-process pub/sub with named topics. Every subscriber has
+// its own bounded buffer and overflow policy, so one slow consumer only
+// affects the publishers if it chose Block.
+type Broker[T any] struct {
+	mu     sync.RWMutex
+	topics map[string]map[*Subscription[T]]struct{}
+	closed bool
+}
+
+func NewBroker[T any]() *Broker[T] {
+	return &Broker[T]{
+		topics: make(map[string]map[*Subscription[T]]struct{}),
+	}
+}
+
+// Subscribe registers a subscriber on topic. The subscription ends when ctx
+// is done, Unsubscribe is called or the broker is closed.
+func (b *Broker[T]) Subscribe(ctx context.Context, topic string, opts ...SubscribeOption) (*Subscription[T], error) {
+	cfg := subscribeConfig{buffer: defaultSubscriberBuffer, policy: Block}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	sub := &Subscription[T]{
+		broker: b,
+		topic:  topic,
+		policy: cfg.policy,
+		ch:     make(chan T, cfg.buffer),
+		done:   make(chan struct{}),
+	}
+
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return nil, ErrBrokerClosed
+	}
+	subs, ok := b.topics[topic]
+	if !ok {
+		subs = make(map[*Subscription[T]]struct{})
+		b.topics[topic] = subs
+	}
+	subs[sub] = struct{}{}
+	b.mu.Unlock()
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			sub.close(ctx.Err())
+		case <-sub.done:
+		}
+	}()
+
+	return sub, nil
+}
+
+// Publish delivers msg to every subscriber of topic according to their policies.
+// Subscribers that never block are served first, then the Block ones in turn.
+// It fails when the broker is closed or when ctx ends while blocked on
+// subscribers; the others still get msg and the errors are joined.
+func (b *Broker[T]) Publish(ctx context.Context, topic string, msg T) error {
+	tracer := otel.Tracer("broker")
+	ctx, span := tracer.Start(ctx, "broker_publish")
+	span.SetAttributes(attribute.String("broker.topic", topic))
+	defer span.End()
+
+	b.mu.RLock()
+	if b.closed {
+		b.mu.RUnlock()
+		return ErrBrokerClosed
+	}
+	subs := make([]*Subscription[T], 0, len(b.topics[topic]))
+	for sub := range b.topics[topic] {
+		subs = append(subs, sub)
+	}
+	b.mu.RUnlock()
+	// A blocking subscriber must not hold up those that would never wait
+	sort.SliceStable(subs, func(i, j int) bool {
+		return subs[i].policy != Block && subs[j].policy == Block
+	})
+
+	span.SetAttributes(attribute.Int("broker.subscribers", len(subs)))
+	var errs []error
+	for _, sub := range subs {
+		if err := sub.deliver(ctx, msg); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	err := errors.Join(errs...)
+	if err != nil {
+		span.RecordError(err)
+	}
+	return err
+}
+
+// Topics returns the topics that have subscribers, sorted.
+func (b *Broker[T]) Topics() []string {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	topics := make([]string, 0, len(b.topics))
+	for topic := range b.topics {
+		topics = append(topics, topic)
+	}
+	sort.Strings(topics)
+	return topics
+}
+
+// Stats returns the stats of every subscriber of topic.
+func (b *Broker[T]) Stats(topic string) []SubscriberStats {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	stats := make([]SubscriberStats, 0, len(b.topics[topic]))
+	for sub := range b.topics[topic] {
+		stats = append(stats, sub.Stats())
+	}
+	return stats
+}
+
+// Close ends all subscriptions with ErrBrokerClosed.
+func (b *Broker[T]) Close() {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return
+	}
+	b.closed = true
+	var subs []*Subscription[T]
+	for _, topicSubs := range b.topics {
+		for sub := range topicSubs {
+			subs = append(subs, sub)
+		}
+	}
+	b.mu.Unlock()
+
+	for _, sub := range subs {
+		sub.close(ErrBrokerClosed)
+	}
+}
+
+func (b *Broker[T]) remove(sub *Subscription[T]) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	subs := b.topics[sub.topic]
+	delete(subs, sub)
+	if len(subs) == 0 {
+		delete(b.topics, sub.topic)
+	}
+}
+
+// Subscription is one subscriber's view of a topic.
+type Subscription[T any] struct {
+	broker *Broker[T]
+	topic  string
+	policy OverflowPolicy
+
+	sendMu sync.Mutex // serializes deliveries and guards closing ch
+	ch     chan T
+
+	done      chan struct{}
+	closeOnce sync.Once
+	err       error
+
+	delivered atomic.Uint64
+	dropped   atomic.Uint64
+}
+
+// C returns the channel messages arrive on. It is closed when the subscription ends.
+func (s *Subscription[T]) C() <-chan T {
+	return s.ch
+}
+
+// Done is closed when the subscription ends.
+func (s *Subscription[T]) Done() <-chan struct{} {
+	return s.done
+}
+
+// Err tells why the subscription ended, or nil while it is active.
+func (s *Subscription[T]) Err() error {
+	select {
+	case <-s.done:
+		return s.err
+	default:
+		return nil
+	}
+}
+
+// Unsubscribe ends the subscription.
+func (s *Subscription[T]) Unsubscribe() {
+	s.close(nil)
+}
+
+// Stats returns the subscriber's current lag and counters.
+func (s *Subscription[T]) Stats() SubscriberStats {
+	return SubscriberStats{
+		Topic:     s.topic,
+		Policy:    s.policy,
+		Lag:       len(s.ch),
+		Capacity:  cap(s.ch),
+		Delivered: s.delivered.Load(),
+		Dropped:   s.dropped.Load(),
+	}
+}
+
+func (s *Subscription[T]) deliver(ctx context.Context, msg T) error {
+	s.sendMu.Lock()
+	defer s.sendMu.Unlock()
+
+	select {
+	case <-s.done:
+		return nil
+	default:
+	}
+
+	// Fast path: there is room
+	select {
+	case s.ch <- msg:
+		s.delivered.Add(1)
+		return nil
+	default:
+	}
+
+	switch s.policy {
+	case DropNewest:
+		s.drop(ctx)
+		return nil
+
+	case DropOldest:
+		for {
+			select {
+			case <-s.ch:
+				s.drop(ctx)
+			default:
+			}
+			select {
+			case s.ch <- msg:
+				s.delivered.Add(1)
+				return nil
+			default:
+			}
+		}
+
+	case Disconnect:
+		s.drop(ctx)
+		s.shutdown(ErrSubscriberDisconnected, true)
+		return nil
+
+	default: // Block
+		select {
+		case s.ch <- msg:
+			s.delivered.Add(1)
+			return nil
+		case <-s.done:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (s *Subscription[T]) drop(ctx context.Context) {
+	s.dropped.Add(1)
+	metrics().brokerDropped.Add(ctx, 1, metric.WithAttributes(
+		attribute.String("broker.topic", s.topic),
+		attribute.String("broker.policy", s.policy.String()),
+	))
+}
+
+func (s *Subscription[T]) close(err error) {
+	s.shutdown(err, false)
+}
+
+// shutdown ends the subscription once. locked tells whether the caller already holds sendMu.
+func (s *Subscription[T]) shutdown(err error, locked bool) {
+	s.closeOnce.Do(func() {
+		s.err = err
+		close(s.done)
+		s.broker.remove(s)
+
+		// A blocked deliver sees done and releases sendMu
+		if !locked {
+			s.sendMu.Lock()
+			defer s.sendMu.Unlock()
+		}
+		close(s.ch)
+	})
+}
@@ -0,0 +1,124 @@
+package patterns
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+var (
+	// ErrBulkheadFull is returned when all slots and queue places are taken.
+	ErrBulkheadFull = errors.New("bulkhead is full")
+	// ErrBulkheadTimeout is returned when a queued call did not get a slot in time.
+	ErrBulkheadTimeout = errors.New("bulkhead queue timeout")
+)
+
+// BulkheadOption configures a Bulkhead.
+type BulkheadOption func(*Bulkhead)
+
+// WithBulkheadClock sets the time source used for the queue timeout.
+func WithBulkheadClock(clock Clock) BulkheadOption {
+	return func(b *Bulkhead) {
+		b.clock = clock
+	}
+}
+
+// Bulkhead caps the number of concurrent calls to a dependency so that a slow
+// one cannot use up every goroutine. Calls beyond the cap wait in a bounded
+// queue for at most queueTimeout.
+type Bulkhead struct {
+	name         string
+	slots        chan struct{}
+	queue        chan struct{}
+	queueTimeout time.Duration
+	clock        Clock
+}
+
+func NewBulkhead(name string, maxConcurrent, maxQueue int, queueTimeout time.Duration, opts ...BulkheadOption) *Bulkhead {
+	b := &Bulkhead{
+		name:         name,
+		slots:        make(chan struct{}, maxConcurrent),
+		queue:        make(chan struct{}, maxQueue),
+		queueTimeout: queueTimeout,
+		clock:        RealClock(),
+	}
+	for _, opt := range opts {
+		opt(b)
+	}
+	return b
+}
+
+// Acquire takes a slot, waiting in the queue if needed. The returned
+// function gives the slot back and must be called exactly once.
+func (b *Bulkhead) Acquire(ctx context.Context) (func(), error) {
+	tracer := otel.Tracer("bulkhead")
+	_, span := tracer.Start(ctx, "bulkhead_acquire")
+	span.SetAttributes(attribute.String("bulkhead.name", b.name))
+	defer span.End()
+
+	release := func() { <-b.slots }
+
+	// Fast path: a slot is free
+	select {
+	case b.slots <- struct{}{}:
+		return release, nil
+	default:
+	}
+
+	select {
+	case b.queue <- struct{}{}:
+	default:
+		span.AddEvent("rejected")
+		span.RecordError(ErrBulkheadFull)
+		b.reject(ctx, "full")
+		return nil, ErrBulkheadFull
+	}
+	defer func() { <-b.queue }()
+	span.AddEvent("queued")
+
+	timer := b.clock.NewTimer(b.queueTimeout)
+	defer timer.Stop()
+
+	select {
+	case b.slots <- struct{}{}:
+		return release, nil
+	case <-timer.C():
+		span.RecordError(ErrBulkheadTimeout)
+		b.reject(ctx, "timeout")
+		return nil, ErrBulkheadTimeout
+	case <-ctx.Done():
+		span.RecordError(ctx.Err())
+		return nil, ctx.Err()
+	}
+}
+
+func (b *Bulkhead) reject(ctx context.Context, reason string) {
+	metrics().bulkheadRejected.Add(ctx, 1, metric.WithAttributes(
+		attribute.String("bulkhead.name", b.name),
+		attribute.String("reason", reason),
+	))
+}
+
+// Execute runs fn inside the bulkhead.
+func (b *Bulkhead) Execute(ctx context.Context, fn func(context.Context) error) error {
+	release, err := b.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+	return fn(ctx)
+}
+
+// InFlight returns the number of calls holding a slot.
+func (b *Bulkhead) InFlight() int {
+	return len(b.slots)
+}
+
+// Queued returns the number of calls waiting for a slot.
+func (b *Bulkhead) Queued() int {
+	return len(b.queue)
+}
@@ -0,0 +1,381 @@
+package patterns
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ErrCircuitOpen is returned without calling the function while the breaker is open
+// or while half-open and all probe slots are taken.
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// BreakerState is the state of a CircuitBreaker.
+type BreakerState int
+
+const (
+	StateClosed BreakerState = iota
+	StateOpen
+	StateHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+// TripPolicy decides when a closed breaker opens. It is only called with
+// the breaker's lock held, so implementations need no locking.
+type TripPolicy interface {
+	// Record registers the outcome of a call and reports whether the breaker should trip.
+	Record(now time.Time, success bool) bool
+	// Reset forgets all outcomes; called whenever the breaker closes.
+	Reset()
+}
+
+// ConsecutiveFailures trips after n failures in a row.
+func ConsecutiveFailures(n int) TripPolicy {
+	return &consecutiveFailures{threshold: n}
+}
+
+type consecutiveFailures struct {
+	threshold int
+	failures  int
+}
+
+func (p *consecutiveFailures) Record(_ time.Time, success bool) bool {
+	if success {
+		p.failures = 0
+		return false
+	}
+	p.failures++
+	return p.failures >= p.threshold
+}
+
+func (p *consecutiveFailures) Reset() {
+	p.failures = 0
+}
+
+// FailureRatio trips when at least minCalls were made during the last window
+// and the share of failures among them reaches ratio. The window is split
+// into buckets that roll over as time passes.
+func FailureRatio(ratio float64, minCalls int, window time.Duration, buckets int) TripPolicy {
+	if buckets < 1 {
+		buckets = 1
+	}
+	width := window / time.Duration(buckets)
+	if width <= 0 {
+		width = time.Nanosecond
+	}
+	return &failureRatio{
+		ratio:    ratio,
+		minCalls: minCalls,
+		width:    width,
+		buckets:  make([]ratioBucket, buckets),
+	}
+}
+
+type ratioBucket struct {
+	start     time.Time
+	successes int
+	failures  int
+}
+
+type failureRatio struct {
+	ratio    float64
+	minCalls int
+	width    time.Duration
+	buckets  []ratioBucket
+}
+
+func (p *failureRatio) Record(now time.Time, success bool) bool {
+	start := now.Truncate(p.width)
+	b := &p.buckets[int(start.UnixNano()/int64(p.width))%len(p.buckets)]
+	if !b.start.Equal(start) {
+		*b = ratioBucket{start: start}
+	}
+	if success {
+		b.successes++
+	} else {
+		b.failures++
+	}
+
+	// Sum only buckets that are still inside the window
+	oldest := start.Add(-p.width * time.Duration(len(p.buckets)-1))
+	var successes, failures int
+	for _, b := range p.buckets {
+		if b.start.Before(oldest) {
+			continue
+		}
+		successes += b.successes
+		failures += b.failures
+	}
+	total := successes + failures
+	return total >= p.minCalls && float64(failures)/float64(total) >= p.ratio
+}
+
+func (p *failureRatio) Reset() {
+	for i := range p.buckets {
+		p.buckets[i] = ratioBucket{}
+	}
+}
+
+// CircuitBreakerOption configures a CircuitBreaker.
+type CircuitBreakerOption func(*breakerConfig)
+
+type breakerConfig struct {
+	clock         Clock
+	policy        TripPolicy
+	openTimeout   time.Duration
+	halfOpenCalls int
+	onStateChange func(from, to BreakerState)
+	isFailure     func(error) bool
+}
+
+// WithTripPolicy sets when the breaker opens. Default: 5 consecutive failures.
+func WithTripPolicy(p TripPolicy) CircuitBreakerOption {
+	return func(c *breakerConfig) {
+		c.policy = p
+	}
+}
+
+// WithOpenTimeout sets how long the breaker stays open before probing. Default: 30s.
+func WithOpenTimeout(d time.Duration) CircuitBreakerOption {
+	return func(c *breakerConfig) {
+		c.openTimeout = d
+	}
+}
+
+// WithHalfOpenCalls sets how many probe calls are let through while half-open;
+// all of them must succeed to close the breaker. Default: 1.
+func WithHalfOpenCalls(n int) CircuitBreakerOption {
+	return func(c *breakerConfig) {
+		if n > 0 {
+			c.halfOpenCalls = n
+		}
+	}
+}
+
+// WithStateChange registers a callback for state transitions.
+// It runs with the breaker locked, so it must not call back into the breaker.
+func WithStateChange(fn func(from, to BreakerState)) CircuitBreakerOption {
+	return func(c *breakerConfig) {
+		c.onStateChange = fn
+	}
+}
+
+// WithFailurePredicate decides which errors count as failures.
+// By default every error does. Calls whose caller's context ended before
+// they returned are never recorded either way; a timeout inside fn while
+// the caller is still waiting goes through the predicate like any error.
+func WithFailurePredicate(fn func(error) bool) CircuitBreakerOption {
+	return func(c *breakerConfig) {
+		c.isFailure = fn
+	}
+}
+
+// WithBreakerClock sets the breaker's time source.
+func WithBreakerClock(clock Clock) CircuitBreakerOption {
+	return func(c *breakerConfig) {
+		c.clock = clock
+	}
+}
+
+// CircuitBreaker stops calling a failing dependency for a while and then
+// probes it with a limited number of calls before resuming normal traffic.
+type CircuitBreaker[T any] struct {
+	name string
+	cfg  breakerConfig
+
+	mu         sync.Mutex
+	state      BreakerState
+	generation uint64 // bumped on every transition, stale results are ignored
+	openedAt   time.Time
+	probes     int // calls let through in the current half-open period
+	probeOKs   int
+}
+
+func NewCircuitBreaker[T any](name string, opts ...CircuitBreakerOption) *CircuitBreaker[T] {
+	cfg := breakerConfig{
+		clock:         RealClock(),
+		policy:        ConsecutiveFailures(5),
+		openTimeout:   30 * time.Second,
+		halfOpenCalls: 1,
+		isFailure: func(err error) bool {
+			return err != nil
+		},
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return &CircuitBreaker[T]{name: name, cfg: cfg}
+}
+
+// State returns the current state, moving from open to half-open if the timeout has passed.
+func (cb *CircuitBreaker[T]) State() BreakerState {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	cb.refresh(cb.cfg.clock.Now(), nil)
+	return cb.state
+}
+
+// Execute calls fn unless the breaker rejects the call with ErrCircuitOpen.
+func (cb *CircuitBreaker[T]) Execute(ctx context.Context, fn func(context.Context) (T, error)) (T, error) {
+	tracer := otel.Tracer("circuit-breaker")
+	ctx, span := tracer.Start(ctx, "circuit_breaker_execute")
+	span.SetAttributes(attribute.String("breaker.name", cb.name))
+	defer span.End()
+
+	var zero T
+	if err := ctx.Err(); err != nil {
+		return zero, err
+	}
+
+	generation, err := cb.before(span)
+	if err != nil {
+		span.AddEvent("rejected")
+		span.RecordError(err)
+		return zero, err
+	}
+
+	// A panicking call still has to give back its half-open probe slot
+	defer func() {
+		if p := recover(); p != nil {
+			cb.after(span, generation, outcomeFailure)
+			panic(p)
+		}
+	}()
+
+	val, err := fn(ctx)
+	cb.after(span, generation, cb.outcome(ctx, err))
+	if err != nil {
+		span.RecordError(err)
+	}
+	return val, err
+}
+
+func (cb *CircuitBreaker[T]) before(span trace.Span) (uint64, error) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	cb.refresh(cb.cfg.clock.Now(), span)
+	span.SetAttributes(attribute.String("breaker.state", cb.state.String()))
+
+	switch cb.state {
+	case StateOpen:
+		return 0, ErrCircuitOpen
+	case StateHalfOpen:
+		if cb.probes >= cb.cfg.halfOpenCalls {
+			return 0, ErrCircuitOpen
+		}
+		cb.probes++
+	}
+	return cb.generation, nil
+}
+
+// callOutcome is how a finished call is accounted for.
+type callOutcome int
+
+const (
+	outcomeSuccess callOutcome = iota
+	outcomeFailure
+	outcomeIgnored // the caller gave up, the dependency's health is unknown
+)
+
+// outcome classifies a call. Only the caller's own cancellation is neutral:
+// a dependency that times out under a live ctx is exactly what should trip.
+func (cb *CircuitBreaker[T]) outcome(ctx context.Context, err error) callOutcome {
+	switch {
+	case err != nil && ctx.Err() != nil:
+		return outcomeIgnored
+	case cb.cfg.isFailure(err):
+		return outcomeFailure
+	default:
+		return outcomeSuccess
+	}
+}
+
+func (cb *CircuitBreaker[T]) after(span trace.Span, generation uint64, outcome callOutcome) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if generation != cb.generation {
+		return
+	}
+	if outcome == outcomeIgnored {
+		// Free the probe slot without counting the call
+		if cb.state == StateHalfOpen {
+			cb.probes--
+		}
+		return
+	}
+	now := cb.cfg.clock.Now()
+	success := outcome == outcomeSuccess
+
+	switch cb.state {
+	case StateClosed:
+		if cb.cfg.policy.Record(now, success) {
+			cb.transition(now, StateOpen, span)
+		}
+	case StateHalfOpen:
+		if !success {
+			cb.transition(now, StateOpen, span)
+			return
+		}
+		cb.probeOKs++
+		if cb.probeOKs >= cb.cfg.halfOpenCalls {
+			cb.transition(now, StateClosed, span)
+		}
+	}
+}
+
+// refresh moves an open breaker to half-open once the timeout has passed.
+func (cb *CircuitBreaker[T]) refresh(now time.Time, span trace.Span) {
+	if cb.state == StateOpen && !now.Before(cb.openedAt.Add(cb.cfg.openTimeout)) {
+		cb.transition(now, StateHalfOpen, span)
+	}
+}
+
+func (cb *CircuitBreaker[T]) transition(now time.Time, to BreakerState, span trace.Span) {
+	from := cb.state
+	if from == to {
+		return
+	}
+	cb.state = to
+	cb.generation++
+	cb.probes, cb.probeOKs = 0, 0
+
+	switch to {
+	case StateOpen:
+		cb.openedAt = now
+	case StateClosed:
+		cb.cfg.policy.Reset()
+	}
+
+	metrics().breakerChanges.Add(context.Background(), 1, metric.WithAttributes(
+		attribute.String("breaker.name", cb.name),
+		attribute.String("breaker.to", to.String()),
+	))
+	if span != nil {
+		span.AddEvent("state_change", trace.WithAttributes(
+			attribute.String("breaker.from", from.String()),
+			attribute.String("breaker.to", to.String()),
+		))
+	}
+	if cb.cfg.onStateChange != nil {
+		cb.cfg.onStateChange(from, to)
+	}
+}
@@ -2,51 +2,192 @@ package patterns
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
 	"sync"
 
 	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// PanicError is returned by Wait for a task that panicked.
+type PanicError struct {
+	Value any
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("task panicked: %v\n%s", e.Value, e.Stack)
+}
+
+// GroupOption configures a Group.
+type GroupOption func(*Group)
+
+// CollectAll makes Wait return every task error joined with errors.Join.
+// In this mode a failing task does not cancel the group's context.
+func CollectAll() GroupOption {
+	return func(g *Group) {
+		g.collectAll = true
+	}
+}
+
 // Group is a collection of goroutines working on subtasks that are part of the same overall task.
 type Group struct {
+	ctx    context.Context
 	cancel func()
 	wg     sync.WaitGroup
-	errOnce sync.Once
-	err     error
+	sem    chan struct{}
+
+	collectAll bool
+	errOnce    sync.Once
+	err        error
+	mu         sync.Mutex
+	errs       []error
 }
 
-func WithContext(ctx context.Context) (*Group, context.Context) {
+func WithContext(ctx context.Context, opts ...GroupOption) (*Group, context.Context) {
 	ctx, cancel := context.WithCancel(ctx)
-	return &Group{cancel: cancel}, ctx
+	g := &Group{ctx: ctx, cancel: cancel}
+	for _, opt := range opts {
+		opt(g)
+	}
+	return g, ctx
 }
 
+// SetLimit bounds the number of active tasks; n < 0 removes the limit.
+// It must not be called while tasks are running.
+func (g *Group) SetLimit(n int) {
+	if n < 0 {
+		g.sem = nil
+		return
+	}
+	if len(g.sem) != 0 {
+		panic(fmt.Errorf("errgroup: modify limit while %d tasks are still active", len(g.sem)))
+	}
+	g.sem = make(chan struct{}, n)
+}
+
+// Go runs f in a new goroutine, blocking while the limit is reached.
+// The task span's parent is taken from ctx.
 func (g *Group) Go(ctx context.Context, f func() error) {
+	g.GoNamed(ctx, "", f)
+}
+
+// GoNamed is Go with a task name recorded on the span.
+func (g *Group) GoNamed(ctx context.Context, name string, f func() error) {
+	if g.sem != nil {
+		g.sem <- struct{}{}
+	}
+	g.start(ctx, name, func(context.Context) error { return f() })
+}
+
+// GoContext runs f with a context derived from the group's context, so
+// cancellation and the task span travel together.
+func (g *Group) GoContext(name string, f func(ctx context.Context) error) {
+	if g.sem != nil {
+		g.sem <- struct{}{}
+	}
+	g.start(g.context(), name, f)
+}
+
+// TryGo runs f only if the limit allows it right now and reports whether it did.
+func (g *Group) TryGo(ctx context.Context, name string, f func() error) bool {
+	if g.sem != nil {
+		select {
+		case g.sem <- struct{}{}:
+		default:
+			return false
+		}
+	}
+	g.start(ctx, name, func(context.Context) error { return f() })
+	return true
+}
+
+func (g *Group) start(parent context.Context, name string, f func(context.Context) error) {
 	g.wg.Add(1)
 	tracer := otel.Tracer("errgroup")
 
+	// The span hangs off the caller's span but lives in the group's context,
+	// so it is cancelled together with the group.
+	ctx := g.context()
+	if s := trace.SpanFromContext(parent); s.SpanContext().IsValid() {
+		ctx = trace.ContextWithSpan(ctx, s)
+	}
+
 	go func() {
 		defer g.wg.Done()
-		
+		defer func() {
+			if g.sem != nil {
+				<-g.sem
+			}
+		}()
+
 		// Create a span for this goroutine
-		_, span := tracer.Start(ctx, "errgroup_task")
+		ctx, span := tracer.Start(ctx, "errgroup_task")
 		defer span.End()
+		if name != "" {
+			span.SetAttributes(attribute.String("task.name", name))
+		}
 
-		if err := f(); err != nil {
-			g.errOnce.Do(func() {
-				g.err = err
-				if g.cancel != nil {
-					g.cancel()
-				}
-			})
+		var err error
+		observeTask(ctx, "errgroup", func() {
+			err = g.run(ctx, f)
+		})
+		if err != nil {
+			metrics().groupFailures.Add(ctx, 1, patternAttr("errgroup"))
+			if name != "" {
+				err = fmt.Errorf("%s: %w", name, err)
+			}
+			g.fail(err)
 			span.RecordError(err)
 		}
 	}()
 }
 
+// context returns the group's context; a zero Group has none.
+func (g *Group) context() context.Context {
+	if g.ctx == nil {
+		return context.Background()
+	}
+	return g.ctx
+}
+
+// run executes f, turning a panic into a *PanicError.
+func (g *Group) run(ctx context.Context, f func(context.Context) error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = &PanicError{Value: r, Stack: debug.Stack()}
+		}
+	}()
+	return f(ctx)
+}
+
+func (g *Group) fail(err error) {
+	if g.collectAll {
+		g.mu.Lock()
+		g.errs = append(g.errs, err)
+		g.mu.Unlock()
+		return
+	}
+	g.errOnce.Do(func() {
+		g.err = err
+		if g.cancel != nil {
+			g.cancel()
+		}
+	})
+}
+
+// Wait blocks until all tasks finished. It returns the first error, or all
+// of them joined when the group was created with CollectAll.
 func (g *Group) Wait() error {
 	g.wg.Wait()
 	if g.cancel != nil {
 		g.cancel()
 	}
+	if g.collectAll {
+		return errors.Join(g.errs...)
+	}
 	return g.err
 }
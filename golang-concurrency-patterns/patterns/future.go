@@ -2,15 +2,31 @@ package patterns
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"sync"
 
 	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
 )
 
+// ErrAllFuturesFailed is returned by Any when no future succeeded.
+var ErrAllFuturesFailed = errors.New("all futures failed")
+
 // Future represents a value that will be available in the future.
 type Future[T any] struct {
-	val  T
-	err  error
-	done chan struct{}
+	once   sync.Once
+	val    T
+	err    error
+	done   chan struct{}
+	cancel context.CancelFunc // cancels the task context, if there is one
+}
+
+func newFuture[T any](cancel context.CancelFunc) *Future[T] {
+	return &Future[T]{
+		done:   make(chan struct{}),
+		cancel: cancel,
+	}
 }
 
 // Result blocks until the future is resolved/rejected.
@@ -24,26 +40,204 @@ func (f *Future[T]) Result(ctx context.Context) (T, error) {
 	}
 }
 
+// Done is closed once the future is settled.
+func (f *Future[T]) Done() <-chan struct{} {
+	return f.done
+}
+
+// Cancel cancels the context of the underlying task and settles the future
+// with context.Canceled, unless it has already settled.
+func (f *Future[T]) Cancel() {
+	if f.cancel != nil {
+		f.cancel()
+	}
+	var zero T
+	f.complete(zero, context.Canceled)
+}
+
+// complete settles the future once; later calls are ignored.
+func (f *Future[T]) complete(val T, err error) bool {
+	settled := false
+	f.once.Do(func() {
+		f.val, f.err = val, err
+		close(f.done)
+		settled = true
+	})
+	return settled
+}
+
 // Async executes a function asynchronously and returns a Future.
 func Async[T any](ctx context.Context, f func(context.Context) (T, error)) *Future[T] {
-	fut := &Future[T]{
-		done: make(chan struct{}),
-	}
+	return async(ctx, "async_future", nil, f)
+}
+
+// async runs f under a span named spanName and settles the returned future with its result.
+func async[T any](ctx context.Context, spanName string, attrs []attribute.KeyValue, f func(context.Context) (T, error)) *Future[T] {
+	ctx, cancel := context.WithCancel(ctx)
+	fut := newFuture[T](cancel)
 
 	tracer := otel.Tracer("future")
 
 	go func() {
-		defer close(fut.done)
+		defer cancel()
 
 		// Start span for async task
-		subCtx, span := tracer.Start(ctx, "async_future")
+		subCtx, span := tracer.Start(ctx, spanName)
+		span.SetAttributes(attrs...)
 		defer span.End()
 
-		fut.val, fut.err = f(subCtx)
-		if fut.err != nil {
-			span.RecordError(fut.err)
+		val, err := f(subCtx)
+		if err != nil {
+			span.RecordError(err)
 		}
+		fut.complete(val, err)
 	}()
 
 	return fut
 }
+
+// Promise is a Future settled from the outside.
+type Promise[T any] struct {
+	fut *Future[T]
+}
+
+func NewPromise[T any]() *Promise[T] {
+	return &Promise[T]{fut: newFuture[T](nil)}
+}
+
+// Resolve settles the promise with val. It reports false if it was already settled.
+func (p *Promise[T]) Resolve(val T) bool {
+	return p.fut.complete(val, nil)
+}
+
+// Reject settles the promise with err. It reports false if it was already settled.
+func (p *Promise[T]) Reject(err error) bool {
+	var zero T
+	return p.fut.complete(zero, err)
+}
+
+// Future returns the read side of the promise.
+func (p *Promise[T]) Future() *Future[T] {
+	return p.fut
+}
+
+// Then runs next with the value of f once f succeeds; an error of f is passed through.
+// Cancelling the returned future cancels next's context.
+func Then[T any, R any](ctx context.Context, f *Future[T], next func(context.Context, T) (R, error)) *Future[R] {
+	return async(ctx, "future_then", nil, func(ctx context.Context) (R, error) {
+		val, err := f.Result(ctx)
+		if err != nil {
+			var zero R
+			return zero, err
+		}
+		return next(ctx, val)
+	})
+}
+
+// MapFuture transforms the value of f. It is Then for functions that cannot fail.
+// (Named MapFuture because Map is the pipeline stage.)
+func MapFuture[T any, R any](ctx context.Context, f *Future[T], transform func(T) R) *Future[R] {
+	return Then(ctx, f, func(_ context.Context, val T) (R, error) {
+		return transform(val), nil
+	})
+}
+
+// Settled is the outcome of one future in AllSettled.
+type Settled[T any] struct {
+	Value T
+	Err   error
+}
+
+type indexedResult[T any] struct {
+	idx int
+	val T
+	err error
+}
+
+// collect delivers the results of futures in completion order.
+func collect[T any](ctx context.Context, futures []*Future[T]) <-chan indexedResult[T] {
+	out := make(chan indexedResult[T], len(futures))
+	for i, f := range futures {
+		go func(i int, f *Future[T]) {
+			val, err := f.Result(ctx)
+			out <- indexedResult[T]{idx: i, val: val, err: err}
+		}(i, f)
+	}
+	return out
+}
+
+func cancelAll[T any](futures []*Future[T]) {
+	for _, f := range futures {
+		f.Cancel()
+	}
+}
+
+func countAttr(n int) []attribute.KeyValue {
+	return []attribute.KeyValue{attribute.Int("futures.count", n)}
+}
+
+// All resolves to every value in input order, or fails with the first error,
+// in which case the remaining futures are cancelled.
+func All[T any](ctx context.Context, futures ...*Future[T]) *Future[[]T] {
+	return async(ctx, "future_all", countAttr(len(futures)), func(ctx context.Context) ([]T, error) {
+		values := make([]T, len(futures))
+		results := collect(ctx, futures)
+		for range futures {
+			r := <-results
+			if r.err != nil {
+				cancelAll(futures)
+				return nil, r.err
+			}
+			values[r.idx] = r.val
+		}
+		return values, nil
+	})
+}
+
+// AllSettled waits for every future and reports each outcome in input order.
+func AllSettled[T any](ctx context.Context, futures ...*Future[T]) *Future[[]Settled[T]] {
+	return async(ctx, "future_all_settled", countAttr(len(futures)), func(ctx context.Context) ([]Settled[T], error) {
+		settled := make([]Settled[T], len(futures))
+		results := collect(ctx, futures)
+		for range futures {
+			r := <-results
+			settled[r.idx] = Settled[T]{Value: r.val, Err: r.err}
+		}
+		return settled, ctx.Err()
+	})
+}
+
+// Any resolves to the first successful value and cancels the others.
+// If every future fails, it fails with ErrAllFuturesFailed wrapping all errors.
+func Any[T any](ctx context.Context, futures ...*Future[T]) *Future[T] {
+	return async(ctx, "future_any", countAttr(len(futures)), func(ctx context.Context) (T, error) {
+		var zero T
+		if len(futures) == 0 {
+			return zero, ErrAllFuturesFailed
+		}
+		errs := make([]error, len(futures))
+		results := collect(ctx, futures)
+		for range futures {
+			r := <-results
+			if r.err == nil {
+				cancelAll(futures)
+				return r.val, nil
+			}
+			errs[r.idx] = r.err
+		}
+		return zero, fmt.Errorf("%w: %w", ErrAllFuturesFailed, errors.Join(errs...))
+	})
+}
+
+// Race settles like the first future to settle and cancels the others.
+func Race[T any](ctx context.Context, futures ...*Future[T]) *Future[T] {
+	return async(ctx, "future_race", countAttr(len(futures)), func(ctx context.Context) (T, error) {
+		if len(futures) == 0 {
+			var zero T
+			return zero, errors.New("race of no futures")
+		}
+		r := <-collect(ctx, futures)
+		cancelAll(futures)
+		return r.val, r.err
+	})
+}
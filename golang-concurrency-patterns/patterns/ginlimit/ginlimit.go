@@ -0,0 +1,41 @@
+// Package ginlimit adapts patterns.KeyedRateLimiter to Gin.
+// It is a separate module so the patterns module does not depend on Gin.
+package ginlimit
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rinkachi/golang-demos/golang-concurrency-patterns/patterns"
+)
+
+// Middleware limits requests per key and aborts with 429 Too Many Requests
+// and a Retry-After header when the key's bucket is empty.
+// Requests for which keyFunc returns "" are not limited.
+func Middleware(kl *patterns.KeyedRateLimiter[string], keyFunc func(*gin.Context) string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := keyFunc(c)
+		if key == "" {
+			c.Next()
+			return
+		}
+		if ok, retryAfter := kl.Take(key); !ok {
+			c.Header("Retry-After", patterns.RetryAfterSeconds(retryAfter))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "rate limit exceeded"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// ClientIPKey keys requests by gin's resolved client IP.
+func ClientIPKey(c *gin.Context) string {
+	return c.ClientIP()
+}
+
+// HeaderKey keys requests by the value of a header, e.g. a tenant ID.
+func HeaderKey(name string) func(*gin.Context) string {
+	return func(c *gin.Context) string {
+		return c.GetHeader(name)
+	}
+}
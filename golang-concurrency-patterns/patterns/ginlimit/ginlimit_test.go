@@ -0,0 +1,70 @@
+package ginlimit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rinkachi/golang-demos/golang-concurrency-patterns/patterns"
+)
+
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	clock := patterns.NewFakeClock(time.Unix(0, 0))
+	kl := patterns.NewKeyedRateLimiter[string](1, 1, patterns.WithKeyedClock(clock))
+
+	r := gin.New()
+	r.Use(Middleware(kl, HeaderKey("X-Tenant-ID")))
+	r.GET("/", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	get := func(tenant string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if tenant != "" {
+			req.Header.Set("X-Tenant-ID", tenant)
+		}
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := get("acme"); rec.Code != http.StatusOK {
+		t.Fatalf("expected first request to pass, got %d", rec.Code)
+	}
+	rec := get("acme")
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429, got %d", rec.Code)
+	}
+	if got := rec.Header().Get("Retry-After"); got != "1" {
+		t.Errorf("expected Retry-After 1, got %q", got)
+	}
+	if body := rec.Body.String(); body != `{"error":"rate limit exceeded"}` {
+		t.Errorf("unexpected body %s", body)
+	}
+	if rec := get("other"); rec.Code != http.StatusOK {
+		t.Errorf("expected another key to have its own bucket, got %d", rec.Code)
+	}
+
+	// Requests without a key are not limited
+	for i := 0; i < 3; i++ {
+		if rec := get(""); rec.Code != http.StatusOK {
+			t.Fatalf("expected unkeyed request %d to pass, got %d", i, rec.Code)
+		}
+	}
+
+	clock.Advance(time.Second)
+	if rec := get("acme"); rec.Code != http.StatusOK {
+		t.Errorf("expected refilled bucket to pass, got %d", rec.Code)
+	}
+}
+
+func TestClientIPKey(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+	c.Request.RemoteAddr = "192.0.2.7:4321"
+	if got := ClientIPKey(c); got != "192.0.2.7" {
+		t.Errorf("expected 192.0.2.7, got %q", got)
+	}
+}
@@ -0,0 +1,242 @@
+package patterns
+
+import (
+	"container/list"
+	"context"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// KeyedRateLimiterOption configures a KeyedRateLimiter.
+type KeyedRateLimiterOption func(*keyedConfig)
+
+type keyedConfig struct {
+	clock   Clock
+	maxKeys int
+	idleTTL time.Duration
+}
+
+// WithMaxKeys bounds the number of buckets; the least recently used one is evicted first.
+func WithMaxKeys(n int) KeyedRateLimiterOption {
+	return func(c *keyedConfig) {
+		c.maxKeys = n
+	}
+}
+
+// WithIdleTTL evicts buckets that have not been used for d.
+func WithIdleTTL(d time.Duration) KeyedRateLimiterOption {
+	return func(c *keyedConfig) {
+		c.idleTTL = d
+	}
+}
+
+// WithKeyedClock sets the time source for the limiter and all of its buckets.
+func WithKeyedClock(clock Clock) KeyedRateLimiterOption {
+	return func(c *keyedConfig) {
+		c.clock = clock
+	}
+}
+
+type keyedLimit struct {
+	rate  float64
+	burst int
+}
+
+type keyedEntry[K comparable] struct {
+	key      K
+	limiter  *RateLimiter
+	lastSeen time.Time
+}
+
+// KeyedRateLimiter keeps one token bucket per key (tenant, client IP, ...).
+// Buckets are created on first use and evicted when idle or when there are too many,
+// so an evicted key simply starts again with a full bucket.
+type KeyedRateLimiter[K comparable] struct {
+	mu        sync.Mutex
+	cfg       keyedConfig
+	defaults  keyedLimit
+	overrides map[K]keyedLimit
+	entries   map[K]*list.Element
+	lru       *list.List // front = most recently used
+}
+
+func NewKeyedRateLimiter[K comparable](rate float64, burst int, opts ...KeyedRateLimiterOption) *KeyedRateLimiter[K] {
+	cfg := keyedConfig{clock: RealClock()}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return &KeyedRateLimiter[K]{
+		cfg:       cfg,
+		defaults:  keyedLimit{rate: rate, burst: burst},
+		overrides: make(map[K]keyedLimit),
+		entries:   make(map[K]*list.Element),
+		lru:       list.New(),
+	}
+}
+
+// Limiter returns the bucket of key, creating it if needed.
+func (k *KeyedRateLimiter[K]) Limiter(key K) *RateLimiter {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	now := k.cfg.clock.Now()
+	k.evictIdle(now)
+
+	if el, ok := k.entries[key]; ok {
+		e := el.Value.(*keyedEntry[K])
+		e.lastSeen = now
+		k.lru.MoveToFront(el)
+		return e.limiter
+	}
+
+	limit, ok := k.overrides[key]
+	if !ok {
+		limit = k.defaults
+	}
+	e := &keyedEntry[K]{
+		key:      key,
+		limiter:  NewRateLimiter(limit.rate, limit.burst, WithClock(k.cfg.clock)),
+		lastSeen: now,
+	}
+	k.entries[key] = k.lru.PushFront(e)
+
+	if k.cfg.maxKeys > 0 {
+		for k.lru.Len() > k.cfg.maxKeys {
+			k.remove(k.lru.Back())
+		}
+	}
+	return e.limiter
+}
+
+// Allow reports whether an event for key may happen now.
+func (k *KeyedRateLimiter[K]) Allow(key K) bool {
+	return k.Limiter(key).Allow()
+}
+
+// Wait blocks until an event for key may happen.
+func (k *KeyedRateLimiter[K]) Wait(ctx context.Context, key K) error {
+	return k.Limiter(key).Wait(ctx)
+}
+
+// Take consumes a token for key if one is available. Otherwise it returns
+// false and how long until a token would be available.
+func (k *KeyedRateLimiter[K]) Take(key K) (bool, time.Duration) {
+	r := k.Limiter(key).Reserve()
+	if !r.OK() {
+		metrics().rateLimiterRejected.Add(context.Background(), 1, patternAttr("keyed_ratelimiter"))
+		return false, time.Duration(math.MaxInt64)
+	}
+	if delay := r.Delay(); delay > 0 {
+		r.Cancel()
+		metrics().rateLimiterRejected.Add(context.Background(), 1, patternAttr("keyed_ratelimiter"))
+		return false, delay
+	}
+	return true, 0
+}
+
+// SetOverride gives key its own rate and burst, also for an existing bucket.
+func (k *KeyedRateLimiter[K]) SetOverride(key K, rate float64, burst int) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	k.overrides[key] = keyedLimit{rate: rate, burst: burst}
+	if el, ok := k.entries[key]; ok {
+		l := el.Value.(*keyedEntry[K]).limiter
+		l.SetRate(rate)
+		l.SetBurst(burst)
+	}
+}
+
+// RemoveOverride reverts key to the default rate and burst.
+func (k *KeyedRateLimiter[K]) RemoveOverride(key K) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	delete(k.overrides, key)
+	if el, ok := k.entries[key]; ok {
+		l := el.Value.(*keyedEntry[K]).limiter
+		l.SetRate(k.defaults.rate)
+		l.SetBurst(k.defaults.burst)
+	}
+}
+
+// Len returns the number of live buckets.
+func (k *KeyedRateLimiter[K]) Len() int {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.evictIdle(k.cfg.clock.Now())
+	return k.lru.Len()
+}
+
+// evictIdle drops buckets unused for longer than idleTTL. Since the list is
+// ordered by last use, it only walks from the back until a fresh entry.
+func (k *KeyedRateLimiter[K]) evictIdle(now time.Time) {
+	if k.cfg.idleTTL <= 0 {
+		return
+	}
+	for el := k.lru.Back(); el != nil; el = k.lru.Back() {
+		if now.Sub(el.Value.(*keyedEntry[K]).lastSeen) < k.cfg.idleTTL {
+			return
+		}
+		k.remove(el)
+	}
+}
+
+func (k *KeyedRateLimiter[K]) remove(el *list.Element) {
+	e := k.lru.Remove(el).(*keyedEntry[K])
+	delete(k.entries, e.key)
+}
+
+// RateLimitMiddleware limits requests per key and answers 429 Too Many
+// Requests with a Retry-After header when the key's bucket is empty.
+// Requests for which keyFunc returns "" are not limited.
+func RateLimitMiddleware(kl *KeyedRateLimiter[string], keyFunc func(*http.Request) string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := keyFunc(r)
+			if key == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if ok, retryAfter := kl.Take(key); !ok {
+				w.Header().Set("Retry-After", RetryAfterSeconds(retryAfter))
+				http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RetryAfterSeconds formats d for a Retry-After header, rounding up to whole seconds.
+// Waits that can never end are reported as one minute.
+func RetryAfterSeconds(d time.Duration) string {
+	if d == time.Duration(math.MaxInt64) {
+		return "60"
+	}
+	secs := int64(math.Ceil(d.Seconds()))
+	if secs < 1 {
+		secs = 1
+	}
+	return strconv.FormatInt(secs, 10)
+}
+
+// ClientIPKey keys requests by the remote IP address.
+func ClientIPKey(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// HeaderKey keys requests by the value of a header, e.g. a tenant ID.
+func HeaderKey(name string) func(*http.Request) string {
+	return func(r *http.Request) string {
+		return r.Header.Get(name)
+	}
+}
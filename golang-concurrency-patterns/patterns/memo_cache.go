@@ -0,0 +1,263 @@
+package patterns
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// MemoCacheOption configures a MemoCache.
+type MemoCacheOption func(*memoConfig)
+
+type memoConfig struct {
+	ttl         time.Duration
+	staleTTL    time.Duration
+	negativeTTL time.Duration
+	maxEntries  int
+	clock       Clock
+}
+
+// WithCacheTTL sets how long a loaded value is fresh. The default is one minute.
+func WithCacheTTL(d time.Duration) MemoCacheOption {
+	return func(c *memoConfig) {
+		c.ttl = d
+	}
+}
+
+// WithStaleWhileRevalidate keeps serving an expired value for up to d
+// while it is reloaded in the background.
+func WithStaleWhileRevalidate(d time.Duration) MemoCacheOption {
+	return func(c *memoConfig) {
+		c.staleTTL = d
+	}
+}
+
+// WithNegativeTTL caches load errors for d, so a failing key is not
+// reloaded on every call. By default errors are not cached.
+func WithNegativeTTL(d time.Duration) MemoCacheOption {
+	return func(c *memoConfig) {
+		c.negativeTTL = d
+	}
+}
+
+// WithMaxEntries bounds the cache size; the least recently used entry is evicted first.
+func WithMaxEntries(n int) MemoCacheOption {
+	return func(c *memoConfig) {
+		c.maxEntries = n
+	}
+}
+
+// WithCacheClock sets the time source used for expiry.
+func WithCacheClock(clock Clock) MemoCacheOption {
+	return func(c *memoConfig) {
+		c.clock = clock
+	}
+}
+
+type memoEntry[K comparable, V any] struct {
+	key       K
+	val       V
+	err       error
+	expiresAt time.Time
+	staleAt   time.Time // end of the stale-while-revalidate window
+}
+
+// memoLoads tracks the loads of one key that are still running.
+type memoLoads struct {
+	running int
+	gen     uint64 // bumped by Set, Invalidate and Purge; loads that started before are not stored
+}
+
+// MemoCache memoizes a loader function per key. Concurrent misses for the
+// same key share one load through a SingleFlight.
+type MemoCache[K comparable, V any] struct {
+	load   func(context.Context, K) (V, error)
+	cfg    memoConfig
+	flight *SingleFlight[K, V]
+
+	mu      sync.Mutex
+	entries map[K]*list.Element
+	lru     *list.List // front = most recently used
+	loading map[K]*memoLoads
+}
+
+func NewMemoCache[K comparable, V any](load func(context.Context, K) (V, error), opts ...MemoCacheOption) *MemoCache[K, V] {
+	cfg := memoConfig{ttl: time.Minute, clock: RealClock()}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return &MemoCache[K, V]{
+		load:    load,
+		cfg:     cfg,
+		flight:  NewSingleFlight[K, V](),
+		entries: make(map[K]*list.Element),
+		lru:     list.New(),
+		loading: make(map[K]*memoLoads),
+	}
+}
+
+// Get returns the value of key, loading it on a miss. An expired value inside
+// the stale-while-revalidate window is returned right away and refreshed in the background.
+func (c *MemoCache[K, V]) Get(ctx context.Context, key K) (V, error) {
+	tracer := otel.Tracer("memo-cache")
+	ctx, span := tracer.Start(ctx, "memocache_get")
+	span.SetAttributes(attribute.String("cache.key", fmt.Sprint(key)))
+	defer span.End()
+
+	now := c.cfg.clock.Now()
+	c.mu.Lock()
+	if el, ok := c.entries[key]; ok {
+		e := el.Value.(*memoEntry[K, V])
+		switch {
+		case now.Before(e.expiresAt):
+			c.lru.MoveToFront(el)
+			c.mu.Unlock()
+			if e.err != nil {
+				span.SetAttributes(attribute.String("cache.result", "negative_hit"))
+				return e.val, e.err
+			}
+			span.SetAttributes(attribute.String("cache.result", "hit"))
+			return e.val, nil
+		case e.err == nil && now.Before(e.staleAt):
+			c.lru.MoveToFront(el)
+			c.mu.Unlock()
+			span.SetAttributes(attribute.String("cache.result", "stale"))
+			go c.refresh(context.WithoutCancel(ctx), key)
+			return e.val, nil
+		}
+	}
+	c.mu.Unlock()
+
+	span.SetAttributes(attribute.String("cache.result", "miss"))
+	v, _, err := c.flight.Do(ctx, key, func(ctx context.Context) (V, error) {
+		return c.loadAndStore(ctx, key)
+	})
+	if err != nil {
+		span.RecordError(err)
+	}
+	return v, err
+}
+
+// refresh reloads key, joining a load that is already running.
+func (c *MemoCache[K, V]) refresh(ctx context.Context, key K) {
+	_, _, _ = c.flight.Do(ctx, key, func(ctx context.Context) (V, error) {
+		return c.loadAndStore(ctx, key)
+	})
+}
+
+// loadAndStore loads key and caches the result, unless Set, Invalidate or
+// Purge touched the key while the load was running: the result is older
+// than what they left behind.
+func (c *MemoCache[K, V]) loadAndStore(ctx context.Context, key K) (v V, err error) {
+	c.mu.Lock()
+	l := c.loading[key]
+	if l == nil {
+		l = &memoLoads{}
+		c.loading[key] = l
+	}
+	l.running++
+	gen := l.gen
+	c.mu.Unlock()
+
+	loaded := false
+	defer func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if l.running--; l.running == 0 {
+			delete(c.loading, key)
+		}
+		if loaded && l.gen == gen {
+			c.storeLocked(key, v, err)
+		}
+	}()
+	v, err = c.load(ctx, key)
+	loaded = true
+	return v, err
+}
+
+// storeLocked caches a load result. Must be called with mu held.
+func (c *MemoCache[K, V]) storeLocked(key K, v V, err error) {
+	now := c.cfg.clock.Now()
+	if err != nil {
+		if c.cfg.negativeTTL <= 0 {
+			// Keep a stale value around rather than replacing it with nothing
+			return
+		}
+		c.put(&memoEntry[K, V]{key: key, err: err, expiresAt: now.Add(c.cfg.negativeTTL)})
+		return
+	}
+	expiresAt := now.Add(c.cfg.ttl)
+	c.put(&memoEntry[K, V]{key: key, val: v, expiresAt: expiresAt, staleAt: expiresAt.Add(c.cfg.staleTTL)})
+}
+
+// put inserts or replaces an entry. Must be called with mu held.
+func (c *MemoCache[K, V]) put(e *memoEntry[K, V]) {
+	if el, ok := c.entries[e.key]; ok {
+		el.Value = e
+		c.lru.MoveToFront(el)
+		return
+	}
+	c.entries[e.key] = c.lru.PushFront(e)
+	if c.cfg.maxEntries > 0 {
+		for c.lru.Len() > c.cfg.maxEntries {
+			c.remove(c.lru.Back())
+		}
+	}
+}
+
+func (c *MemoCache[K, V]) remove(el *list.Element) {
+	e := c.lru.Remove(el).(*memoEntry[K, V])
+	delete(c.entries, e.key)
+}
+
+// Set stores v for key as if it had just been loaded. A load already in
+// flight for key does not overwrite it.
+func (c *MemoCache[K, V]) Set(key K, v V) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.supersedeLocked(key)
+	c.storeLocked(key, v, nil)
+}
+
+// Invalidate drops key, so the next Get loads it again.
+// A load already in flight for key is neither joined by later calls nor stored.
+func (c *MemoCache[K, V]) Invalidate(key K) {
+	c.mu.Lock()
+	if el, ok := c.entries[key]; ok {
+		c.remove(el)
+	}
+	c.supersedeLocked(key)
+	c.mu.Unlock()
+	c.flight.Forget(key)
+}
+
+// Purge drops every entry. Loads in flight are not stored.
+func (c *MemoCache[K, V]) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = make(map[K]*list.Element)
+	c.lru.Init()
+	for _, l := range c.loading {
+		l.gen++
+	}
+}
+
+// supersedeLocked keeps the running loads of key from storing their result.
+// Must be called with mu held.
+func (c *MemoCache[K, V]) supersedeLocked(key K) {
+	if l, ok := c.loading[key]; ok {
+		l.gen++
+	}
+}
+
+// Len returns the number of cached entries, including expired ones not yet replaced.
+func (c *MemoCache[K, V]) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lru.Len()
+}
@@ -0,0 +1,123 @@
+package patterns
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+const meterName = "github.com/rinkachi/golang-demos/golang-concurrency-patterns/patterns"
+
+var (
+	metricsMu     sync.Mutex // serializes creating instruments on a cache miss
+	meterProvider atomic.Pointer[metric.MeterProvider]
+	cached        atomic.Pointer[providerInstruments]
+)
+
+// providerInstruments are the instruments created from provider.
+type providerInstruments struct {
+	provider metric.MeterProvider
+	ins      *instruments
+}
+
+// SetMeterProvider sets the MeterProvider the patterns record metrics with.
+// Without it the global provider (otel.GetMeterProvider) is used.
+func SetMeterProvider(mp metric.MeterProvider) {
+	if mp == nil {
+		meterProvider.Store(nil)
+		return
+	}
+	meterProvider.Store(&mp)
+}
+
+// instruments holds every metric instrument of the package.
+type instruments struct {
+	meter               metric.Meter // for instruments registered per object, like Pool gauges
+	poolQueueDepth      metric.Int64UpDownCounter
+	poolBusyWorkers     metric.Int64UpDownCounter
+	taskDuration        metric.Float64Histogram
+	rateLimiterWait     metric.Float64Histogram
+	rateLimiterRejected metric.Int64Counter
+	barrierWait         metric.Float64Histogram
+	groupFailures       metric.Int64Counter
+	brokerDropped       metric.Int64Counter
+	breakerChanges      metric.Int64Counter
+	bulkheadRejected    metric.Int64Counter
+	resourceBorrowWait  metric.Float64Histogram
+}
+
+// metrics returns the instruments for the current provider, creating them on
+// first use. It is called on hot paths, so a cache hit takes no lock.
+func metrics() *instruments {
+	var mp metric.MeterProvider
+	if p := meterProvider.Load(); p != nil {
+		mp = *p
+	} else {
+		mp = otel.GetMeterProvider()
+	}
+	if c := cached.Load(); c != nil && c.provider == mp {
+		return c.ins
+	}
+
+	metricsMu.Lock()
+	defer metricsMu.Unlock()
+	if c := cached.Load(); c != nil && c.provider == mp {
+		return c.ins
+	}
+
+	m := mp.Meter(meterName)
+	// Instrument creation only fails on invalid names, which are constants here
+	ins := &instruments{meter: m}
+	ins.poolQueueDepth, _ = m.Int64UpDownCounter("patterns.pool.queue_depth",
+		metric.WithDescription("Tasks accepted by a worker pool and waiting for a worker"))
+	ins.poolBusyWorkers, _ = m.Int64UpDownCounter("patterns.pool.busy_workers",
+		metric.WithDescription("Workers currently running a task"))
+	ins.taskDuration, _ = m.Float64Histogram("patterns.task.duration",
+		metric.WithDescription("Duration of tasks run by worker pools and errgroups"), metric.WithUnit("s"))
+	ins.rateLimiterWait, _ = m.Float64Histogram("patterns.ratelimiter.wait",
+		metric.WithDescription("Time spent waiting for rate limiter tokens"), metric.WithUnit("s"))
+	ins.rateLimiterRejected, _ = m.Int64Counter("patterns.ratelimiter.rejections",
+		metric.WithDescription("Requests denied by a rate limiter"))
+	ins.barrierWait, _ = m.Float64Histogram("patterns.barrier.wait",
+		metric.WithDescription("Time parties spend waiting at a barrier"), metric.WithUnit("s"))
+	ins.groupFailures, _ = m.Int64Counter("patterns.errgroup.task_failures",
+		metric.WithDescription("Errgroup tasks that returned an error or panicked"))
+	ins.brokerDropped, _ = m.Int64Counter("patterns.broker.dropped",
+		metric.WithDescription("Messages dropped because a subscriber fell behind"))
+	ins.breakerChanges, _ = m.Int64Counter("patterns.circuit_breaker.state_changes",
+		metric.WithDescription("Circuit breaker state transitions"))
+	ins.bulkheadRejected, _ = m.Int64Counter("patterns.bulkhead.rejections",
+		metric.WithDescription("Calls rejected by a bulkhead (full or queue timeout)"))
+
+	ins.resourceBorrowWait, _ = m.Float64Histogram("patterns.resource_pool.borrow_wait",
+		metric.WithDescription("Time spent waiting to borrow a pooled resource"), metric.WithUnit("s"))
+
+	cached.Store(&providerInstruments{provider: mp, ins: ins})
+	return ins
+}
+
+func patternAttr(name string) metric.MeasurementOption {
+	return metric.WithAttributes(attribute.String("pattern", name))
+}
+
+func sinceSeconds(start time.Time) float64 {
+	return time.Since(start).Seconds()
+}
+
+// observeTask records how long a task took and tracks it as a busy worker while it runs.
+func observeTask(ctx context.Context, pattern string, run func()) {
+	m := metrics()
+	attrs := patternAttr(pattern)
+	m.poolBusyWorkers.Add(ctx, 1, attrs)
+	start := time.Now()
+	defer func() {
+		m.taskDuration.Record(ctx, sinceSeconds(start), attrs)
+		m.poolBusyWorkers.Add(ctx, -1, attrs)
+	}()
+	run()
+}
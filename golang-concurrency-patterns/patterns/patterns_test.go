@@ -4,10 +4,22 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
 	"sort"
+	"strconv"
+	"runtime"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
+
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+
+	"github.com/rinkachi/golang-demos/golang-concurrency-patterns/patterns/patternstest"
 )
 
 func TestWorkerPool(t *testing.T) {
@@ -16,7 +28,6 @@ func TestWorkerPool(t *testing.T) {
 	
 	// Task: Square the number
 	worker := func(ctx context.Context, n int) (int, error) {
-		time.Sleep(10 * time.Millisecond) // Simulate work
 		return n * n, nil
 	}
 
@@ -127,7 +138,9 @@ func TestFanIn(t *testing.T) {
 }
 
 func TestCancellation(t *testing.T) {
+	patternstest.VerifyNoLeaks(t)
 	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 	
 	// Infinite generator
 	gen := make(chan int)
@@ -159,3 +172,1823 @@ func TestCancellation(t *testing.T) {
 		}
 	}
 }
+
+func TestStreamPool_PreserveOrder(t *testing.T) {
+	patternstest.VerifyNoLeaks(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Later inputs finish first, so ordering has to come from the pool
+	clock := NewFakeClock(time.Unix(0, 0))
+	worker := func(ctx context.Context, n int) (int, error) {
+		<-clock.NewTimer(time.Duration(10-n) * time.Millisecond).C()
+		return n * n, nil
+	}
+	drive(ctx, clock, time.Millisecond)
+
+	input := Filter(ctx, Generator(ctx, 0, 1, 2, 3, 4, 5, 6, 7, 8, 9), func(n int) bool {
+		return n != 5
+	})
+	results := WorkerPoolStream(ctx, input, worker, 4, PreserveOrder())
+
+	expected := []int{0, 1, 4, 9, 16, 36, 49, 64, 81}
+	i := 0
+	for res := range results {
+		if res.Index != i {
+			t.Errorf("expected index %d, got %d", i, res.Index)
+		}
+		if res.Value != expected[i] {
+			t.Errorf("at index %d: expected %d, got %d", i, expected[i], res.Value)
+		}
+		i++
+	}
+	if i != len(expected) {
+		t.Fatalf("expected %d results, got %d", len(expected), i)
+	}
+}
+
+func TestStreamPool_Resize(t *testing.T) {
+	ctx := context.Background()
+
+	var mu sync.Mutex
+	running, peak := 0, 0
+	release := make(chan struct{})
+	worker := func(ctx context.Context, n int) (int, error) {
+		mu.Lock()
+		running++
+		if running > peak {
+			peak = running
+		}
+		mu.Unlock()
+		<-release
+		mu.Lock()
+		running--
+		mu.Unlock()
+		return n, nil
+	}
+
+	in := make(chan int)
+	pool := NewStreamPool(worker, 1)
+	results := pool.Run(ctx, in)
+
+	go func() {
+		defer close(in)
+		for i := 0; i < 6; i++ {
+			in <- i
+		}
+	}()
+
+	runningIs := func(n int) func() bool {
+		return func() bool {
+			mu.Lock()
+			defer mu.Unlock()
+			return running == n
+		}
+	}
+	eventually(t, runningIs(1), "the first worker did not start")
+	pool.Resize(3)
+	eventually(t, runningIs(3), "resize did not start two more workers")
+	close(release)
+
+	count := 0
+	for range results {
+		count++
+	}
+	if count != 6 {
+		t.Fatalf("expected 6 results, got %d", count)
+	}
+	if peak != 3 {
+		t.Errorf("expected peak concurrency 3 after resize, got %d", peak)
+	}
+	if pool.Concurrency() != 3 {
+		t.Errorf("expected concurrency 3, got %d", pool.Concurrency())
+	}
+}
+
+func TestRateLimiter_TokenBucket(t *testing.T) {
+	clock := NewFakeClock(time.Unix(0, 0))
+	rl := NewRateLimiter(10, 2, WithClock(clock))
+
+	if !rl.Allow() || !rl.Allow() {
+		t.Fatal("expected burst of 2 to be allowed")
+	}
+	if rl.Allow() {
+		t.Fatal("expected empty bucket to deny")
+	}
+
+	clock.Advance(100 * time.Millisecond)
+	if !rl.Allow() {
+		t.Fatal("expected one token after 100ms at 10/s")
+	}
+
+	r := rl.Reserve()
+	if !r.OK() || r.Delay() != 100*time.Millisecond {
+		t.Fatalf("expected reservation with 100ms delay, got ok=%v delay=%v", r.OK(), r.Delay())
+	}
+	r.Cancel()
+	if got := rl.Tokens(); got != 0 {
+		t.Errorf("expected cancelled reservation to restore the token, got %v", got)
+	}
+
+	rl.SetRate(100)
+	clock.Advance(10 * time.Millisecond)
+	if !rl.Allow() {
+		t.Fatal("expected new rate to apply")
+	}
+
+	rl.SetBurst(1)
+	if rl.ReserveN(2).OK() {
+		t.Error("expected reservation above burst to fail")
+	}
+	if err := rl.WaitN(context.Background(), 2); !errors.Is(err, ErrRateLimitExceeded) {
+		t.Errorf("expected ErrRateLimitExceeded, got %v", err)
+	}
+}
+
+func TestRateLimiter_RejectsInvalidN(t *testing.T) {
+	clock := NewFakeClock(time.Unix(0, 0))
+	rl := NewRateLimiter(1, 2, WithClock(clock))
+
+	for _, n := range []int{0, -5, 3} {
+		if rl.AllowN(n) {
+			t.Errorf("expected AllowN(%d) to be rejected", n)
+		}
+		if rl.ReserveN(n).OK() {
+			t.Errorf("expected ReserveN(%d) to be rejected", n)
+		}
+		if err := rl.WaitN(context.Background(), n); !errors.Is(err, ErrRateLimitExceeded) {
+			t.Errorf("expected WaitN(%d) to fail with ErrRateLimitExceeded, got %v", n, err)
+		}
+	}
+	// A negative n must not have minted tokens above the bucket
+	if got := rl.Tokens(); got != 2 {
+		t.Errorf("expected the bucket to stay at 2 tokens, got %v", got)
+	}
+	if !rl.AllowN(2) || rl.Allow() {
+		t.Error("expected exactly the burst to be available")
+	}
+}
+
+func TestRateLimiter_WaitDeadlineOnFakeClock(t *testing.T) {
+	// Fake time far from the wall clock must not make a live deadline look passed
+	clock := NewFakeClock(time.Now().Add(time.Hour))
+	rl := NewRateLimiter(1, 1, WithClock(clock))
+	rl.Allow()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	errs := make(chan error, 1)
+	go func() {
+		errs <- rl.WaitN(ctx, 1)
+	}()
+	if err := clock.BlockUntil(ctx, 1); err != nil {
+		t.Fatalf("WaitN did not wait for a token: %v", err)
+	}
+	clock.Advance(time.Second)
+	if err := <-errs; err != nil {
+		t.Fatalf("expected the wait to succeed, got %v", err)
+	}
+
+	// On the real clock a deadline closer than the wait still fails fast
+	rl = NewRateLimiter(0.001, 1)
+	rl.Allow()
+	if err := rl.WaitN(ctx, 1); !errors.Is(err, ErrRateLimitExceeded) {
+		t.Errorf("expected ErrRateLimitExceeded, got %v", err)
+	}
+}
+
+func TestKeyedRateLimiter(t *testing.T) {
+	clock := NewFakeClock(time.Unix(0, 0))
+	kl := NewKeyedRateLimiter[string](1, 1, WithKeyedClock(clock), WithMaxKeys(2), WithIdleTTL(time.Minute))
+	kl.SetOverride("vip", 1, 3)
+
+	if !kl.Allow("a") || kl.Allow("a") {
+		t.Fatal("expected key a to get exactly one token")
+	}
+	if !kl.Allow("b") {
+		t.Fatal("expected key b to have its own bucket")
+	}
+	for i := 0; i < 3; i++ {
+		if !kl.Allow("vip") {
+			t.Fatalf("expected override burst of 3, denied at %d", i)
+		}
+	}
+	if n := kl.Len(); n != 2 {
+		t.Errorf("expected LRU to cap buckets at 2, got %d", n)
+	}
+
+	clock.Advance(2 * time.Minute)
+	if n := kl.Len(); n != 0 {
+		t.Errorf("expected idle buckets to be evicted, got %d", n)
+	}
+
+	srv := RateLimitMiddleware(kl, HeaderKey("X-Tenant-ID"))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	var codes []int
+	for i := 0; i < 2; i++ {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("X-Tenant-ID", "acme")
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, req)
+		codes = append(codes, rec.Code)
+		if rec.Code == http.StatusTooManyRequests && rec.Header().Get("Retry-After") != "1" {
+			t.Errorf("expected Retry-After 1, got %q", rec.Header().Get("Retry-After"))
+		}
+	}
+	if codes[0] != http.StatusOK || codes[1] != http.StatusTooManyRequests {
+		t.Errorf("expected 200 then 429, got %v", codes)
+	}
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	clock := NewFakeClock(time.Unix(0, 0))
+	kl := NewKeyedRateLimiter[string](1, 1, WithKeyedClock(clock))
+	var served int
+	srv := RateLimitMiddleware(kl, ClientIPKey)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		served++
+	}))
+
+	get := func(remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = remoteAddr
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := get("192.0.2.7:1000"); rec.Code != http.StatusOK {
+		t.Fatalf("expected first request to pass, got %d", rec.Code)
+	}
+	// Same IP from another port shares the bucket
+	rec := get("192.0.2.7:2000")
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429, got %d", rec.Code)
+	}
+	if got := rec.Header().Get("Retry-After"); got != "1" {
+		t.Errorf("expected Retry-After 1, got %q", got)
+	}
+	if rec := get("192.0.2.8:1000"); rec.Code != http.StatusOK {
+		t.Errorf("expected another IP to have its own bucket, got %d", rec.Code)
+	}
+	if served != 2 {
+		t.Errorf("expected the limited request not to reach the handler, served %d", served)
+	}
+
+	// Requests without a key are not limited
+	unkeyed := RateLimitMiddleware(kl, HeaderKey("X-Tenant-ID"))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	for i := 0; i < 3; i++ {
+		rec := httptest.NewRecorder()
+		unkeyed.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected unkeyed request %d to pass, got %d", i, rec.Code)
+		}
+	}
+
+	for d, want := range map[time.Duration]string{
+		0:                            "1",
+		1500 * time.Millisecond:      "2",
+		time.Duration(math.MaxInt64): "60",
+	} {
+		if got := RetryAfterSeconds(d); got != want {
+			t.Errorf("RetryAfterSeconds(%v) = %q, want %q", d, got, want)
+		}
+	}
+}
+
+func TestBarrier_BreaksOnCancel(t *testing.T) {
+	var trips int
+	b := NewBarrier(3, WithBarrierAction(func() error {
+		trips++
+		return nil
+	}))
+
+	// A full round trips the barrier and runs the action
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := b.Await(context.Background(), "party"); err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if trips != 1 {
+		t.Fatalf("expected action to run once, got %d", trips)
+	}
+
+	// One party times out: the others must fail fast instead of hanging
+	errs := make(chan error, 1)
+	go func() {
+		errs <- b.Await(context.Background(), "waiter")
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := b.Await(ctx, "impatient"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline error, got %v", err)
+	}
+	select {
+	case err := <-errs:
+		if !errors.Is(err, ErrBrokenBarrier) {
+			t.Errorf("expected ErrBrokenBarrier, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("waiter was not released when the barrier broke")
+	}
+
+	b.Reset()
+	if b.IsBroken() {
+		t.Error("expected Reset to repair the barrier")
+	}
+}
+
+func TestPhaser_DynamicParties(t *testing.T) {
+	p := NewPhaser(1)
+	if _, err := p.Register(); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan int)
+	go func() {
+		phase, _ := p.ArriveAndAwaitAdvance(context.Background())
+		done <- phase
+	}()
+
+	if _, err := p.ArriveAndDeregister(); err != nil {
+		t.Fatal(err)
+	}
+	if phase := <-done; phase != 1 {
+		t.Fatalf("expected phase 1, got %d", phase)
+	}
+
+	// Only one party left, so it advances alone
+	if phase, err := p.ArriveAndAwaitAdvance(context.Background()); err != nil || phase != 2 {
+		t.Fatalf("expected phase 2, got %d (%v)", phase, err)
+	}
+
+	if _, err := p.ArriveAndDeregister(); err != nil {
+		t.Fatal(err)
+	}
+	if !p.IsTerminated() {
+		t.Error("expected phaser to terminate without parties")
+	}
+	if _, err := p.Register(); !errors.Is(err, ErrPhaserTerminated) {
+		t.Errorf("expected ErrPhaserTerminated, got %v", err)
+	}
+}
+
+func TestGroup_LimitPanicAndCollectAll(t *testing.T) {
+	g, _ := WithContext(context.Background(), CollectAll())
+	g.SetLimit(1)
+
+	block := make(chan struct{})
+	g.GoNamed(context.Background(), "blocker", func() error {
+		<-block
+		return errors.New("first")
+	})
+	if g.TryGo(context.Background(), "extra", func() error { return nil }) {
+		t.Error("expected TryGo to fail while the limit is reached")
+	}
+	close(block)
+
+	g.GoContext("panicky", func(ctx context.Context) error {
+		panic("boom")
+	})
+
+	err := g.Wait()
+	var panicErr *PanicError
+	if !errors.As(err, &panicErr) || panicErr.Value != "boom" || len(panicErr.Stack) == 0 {
+		t.Fatalf("expected PanicError with stack, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "blocker: first") {
+		t.Errorf("expected all errors to be collected, got %v", err)
+	}
+}
+
+func TestFuture_Combinators(t *testing.T) {
+	ctx := context.Background()
+
+	p := NewPromise[int]()
+	doubled := MapFuture(ctx, p.Future(), func(n int) int { return n * 2 })
+	text := Then(ctx, doubled, func(ctx context.Context, n int) (string, error) {
+		return strings.Repeat("x", n), nil
+	})
+	p.Resolve(2)
+	if s, err := text.Result(ctx); err != nil || s != "xxxx" {
+		t.Fatalf("expected xxxx, got %q (%v)", s, err)
+	}
+
+	// The slow task must see its context cancelled once the fast one wins
+	slowCancelled := make(chan struct{})
+	slow := Async(ctx, func(ctx context.Context) (int, error) {
+		<-ctx.Done()
+		close(slowCancelled)
+		return 0, ctx.Err()
+	})
+	fast := Async(ctx, func(ctx context.Context) (int, error) { return 1, nil })
+	if v, err := Race(ctx, slow, fast).Result(ctx); err != nil || v != 1 {
+		t.Fatalf("expected race to be won by 1, got %d (%v)", v, err)
+	}
+	select {
+	case <-slowCancelled:
+	case <-time.After(time.Second):
+		t.Fatal("expected loser to be cancelled")
+	}
+
+	failed := NewPromise[int]()
+	failed.Reject(errors.New("nope"))
+	ok := NewPromise[int]()
+	ok.Resolve(7)
+
+	if v, err := Any(ctx, failed.Future(), ok.Future()).Result(ctx); err != nil || v != 7 {
+		t.Errorf("expected Any to return 7, got %d (%v)", v, err)
+	}
+	if _, err := All(ctx, ok.Future(), failed.Future()).Result(ctx); err == nil || err.Error() != "nope" {
+		t.Errorf("expected All to fail with nope, got %v", err)
+	}
+	settled, _ := AllSettled(ctx, ok.Future(), failed.Future()).Result(ctx)
+	if settled[0].Value != 7 || settled[1].Err == nil {
+		t.Errorf("unexpected AllSettled outcome %+v", settled)
+	}
+	if _, err := Any(ctx, failed.Future()).Result(ctx); !errors.Is(err, ErrAllFuturesFailed) {
+		t.Errorf("expected ErrAllFuturesFailed, got %v", err)
+	}
+}
+
+func TestPipeline_Operators(t *testing.T) {
+	ctx := context.Background()
+
+	stage := Compose(
+		FilterStage(func(n int) bool { return n%2 == 1 }),
+		MapStage(func(n int) int { return n * 10 }),
+	)
+	words := FlatMap(ctx, stage(ctx, Generator(ctx, 1, 2, 3, 3)), func(n int) []int { return []int{n, n} })
+	distinct := Distinct(ctx, words)
+
+	var got []int
+	for n := range distinct {
+		got = append(got, n)
+	}
+	if fmt.Sprint(got) != "[10 30]" {
+		t.Errorf("expected [10 30], got %v", got)
+	}
+
+	batches := Batch(ctx, Generator(ctx, 1, 2, 3, 4, 5), 2, time.Second)
+	var sizes []int
+	for b := range batches {
+		sizes = append(sizes, len(b))
+	}
+	if fmt.Sprint(sizes) != "[2 2 1]" {
+		t.Errorf("expected batch sizes [2 2 1], got %v", sizes)
+	}
+
+	squares := ParallelMap(ctx, Generator(ctx, 1, 2, 3, 4), 3, func(n int) int { return n * n }, PreserveOrder())
+	var ordered []int
+	for n := range squares {
+		ordered = append(ordered, n)
+	}
+	if fmt.Sprint(ordered) != "[1 4 9 16]" {
+		t.Errorf("expected ordered squares, got %v", ordered)
+	}
+
+	parsed := TryMap(ctx, Generator(ctx, "1", "x", "3"), strconv.Atoi)
+	doubled := MapResult(ctx, parsed, func(n int) (int, error) { return n * 2, nil })
+	var values, failures []int
+	for r := range doubled {
+		if r.Err != nil {
+			failures = append(failures, r.Index)
+			continue
+		}
+		values = append(values, r.Value)
+	}
+	if fmt.Sprint(values) != "[2 6]" || fmt.Sprint(failures) != "[1]" {
+		t.Errorf("expected values [2 6] and failure at 1, got %v / %v", values, failures)
+	}
+
+	copies := Tee(ctx, Generator(ctx, 1, 2), 2)
+	var wg sync.WaitGroup
+	sums := make([]int, 2)
+	for i, c := range copies {
+		wg.Add(1)
+		go func(i int, c <-chan int) {
+			defer wg.Done()
+			for n := range c {
+				sums[i] += n
+			}
+		}(i, c)
+	}
+	wg.Wait()
+	if sums[0] != 3 || sums[1] != 3 {
+		t.Errorf("expected both tee outputs to sum to 3, got %v", sums)
+	}
+}
+
+func TestBroker_PublishReachesEverySubscriber(t *testing.T) {
+	ctx := context.Background()
+	b := NewBroker[int]()
+	defer b.Close()
+
+	var blocked []*Subscription[int]
+	for i := 0; i < 2; i++ {
+		sub, _ := b.Subscribe(ctx, "events", WithBuffer(1))
+		blocked = append(blocked, sub)
+	}
+	roomy, _ := b.Subscribe(ctx, "events", WithBuffer(4))
+	lossy, _ := b.Subscribe(ctx, "events", WithBuffer(1), WithOverflow(DropNewest))
+	if err := b.Publish(ctx, "events", 1); err != nil {
+		t.Fatal(err)
+	}
+	<-roomy.C()
+	<-lossy.C()
+
+	// Both Block subscribers are full: the non-blocking one gets the message
+	// before Publish waits on them
+	pubCtx, cancel := context.WithCancel(ctx)
+	errs := make(chan error, 1)
+	go func() {
+		errs <- b.Publish(pubCtx, "events", 2)
+	}()
+	select {
+	case v := <-lossy.C():
+		if v != 2 {
+			t.Fatalf("expected 2, got %d", v)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("a blocked subscriber held up the non-blocking one")
+	}
+	cancel()
+	err := <-errs
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected the cancellation to be reported, got %v", err)
+	}
+	if n := len(roomy.C()); n != 1 {
+		t.Errorf("expected the subscriber with room to get the message despite the error, got %d", n)
+	}
+	for _, sub := range blocked {
+		if st := sub.Stats(); st.Lag != 1 {
+			t.Errorf("expected blocked subscriber to keep its first message, got %+v", st)
+		}
+	}
+}
+
+func TestBroker_OverflowPolicies(t *testing.T) {
+	patternstest.VerifyNoLeaks(t)
+	ctx := context.Background()
+	b := NewBroker[int]()
+	defer b.Close()
+
+	oldest, _ := b.Subscribe(ctx, "events", WithBuffer(2), WithOverflow(DropOldest))
+	newest, _ := b.Subscribe(ctx, "events", WithBuffer(2), WithOverflow(DropNewest))
+	strict, _ := b.Subscribe(ctx, "events", WithBuffer(2), WithOverflow(Disconnect))
+
+	subCtx, cancel := context.WithCancel(ctx)
+	transient, _ := b.Subscribe(subCtx, "events")
+	cancel()
+	<-transient.Done()
+
+	for i := 1; i <= 3; i++ {
+		if err := b.Publish(ctx, "events", i); err != nil {
+			t.Fatalf("publish %d: %v", i, err)
+		}
+	}
+
+	if st := oldest.Stats(); st.Lag != 2 || st.Dropped != 1 {
+		t.Errorf("unexpected drop-oldest stats %+v", st)
+	}
+	if got := []int{<-oldest.C(), <-oldest.C()}; fmt.Sprint(got) != "[2 3]" {
+		t.Errorf("expected drop-oldest to keep [2 3], got %v", got)
+	}
+	if got := []int{<-newest.C(), <-newest.C()}; fmt.Sprint(got) != "[1 2]" {
+		t.Errorf("expected drop-newest to keep [1 2], got %v", got)
+	}
+	if !errors.Is(strict.Err(), ErrSubscriberDisconnected) {
+		t.Errorf("expected slow subscriber to be disconnected, got %v", strict.Err())
+	}
+	if !errors.Is(transient.Err(), context.Canceled) {
+		t.Errorf("expected context to unsubscribe, got %v", transient.Err())
+	}
+	if n := len(b.Stats("events")); n != 2 {
+		t.Errorf("expected 2 remaining subscribers, got %d", n)
+	}
+}
+
+func TestCircuitBreaker_States(t *testing.T) {
+	ctx := context.Background()
+	clock := NewFakeClock(time.Unix(0, 0))
+	var transitions []string
+	cb := NewCircuitBreaker[int]("db",
+		WithBreakerClock(clock),
+		WithTripPolicy(ConsecutiveFailures(2)),
+		WithOpenTimeout(time.Second),
+		WithStateChange(func(from, to BreakerState) {
+			transitions = append(transitions, to.String())
+		}),
+	)
+
+	fail := func(context.Context) (int, error) { return 0, errors.New("down") }
+	ok := func(context.Context) (int, error) { return 1, nil }
+
+	cb.Execute(ctx, fail)
+	cb.Execute(ctx, fail)
+	if cb.State() != StateOpen {
+		t.Fatalf("expected open after 2 failures, got %v", cb.State())
+	}
+	if _, err := cb.Execute(ctx, ok); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("expected ErrCircuitOpen, got %v", err)
+	}
+
+	clock.Advance(time.Second)
+	if cb.State() != StateHalfOpen {
+		t.Fatalf("expected half-open after timeout, got %v", cb.State())
+	}
+	cb.Execute(ctx, fail)
+	if cb.State() != StateOpen {
+		t.Fatalf("expected failed probe to reopen, got %v", cb.State())
+	}
+
+	clock.Advance(time.Second)
+	if v, err := cb.Execute(ctx, ok); err != nil || v != 1 {
+		t.Fatalf("expected probe to pass, got %d (%v)", v, err)
+	}
+	if fmt.Sprint(transitions) != "[open half-open open half-open closed]" {
+		t.Errorf("unexpected transitions %v", transitions)
+	}
+}
+
+func TestCircuitBreaker_CancelledAndPanickingProbes(t *testing.T) {
+	ctx := context.Background()
+	clock := NewFakeClock(time.Unix(0, 0))
+	cb := NewCircuitBreaker[int]("db",
+		WithBreakerClock(clock),
+		WithTripPolicy(ConsecutiveFailures(2)),
+		WithOpenTimeout(time.Second),
+	)
+	fail := func(context.Context) (int, error) { return 0, errors.New("down") }
+	// giveUp runs a call whose caller goes away before it returns
+	giveUp := func(err error) {
+		callCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		cb.Execute(callCtx, func(context.Context) (int, error) {
+			cancel()
+			return 0, err
+		})
+	}
+
+	// A cancelled call neither resets nor adds to the failure streak
+	cb.Execute(ctx, fail)
+	giveUp(context.Canceled)
+	cb.Execute(ctx, fail)
+	if cb.State() != StateOpen {
+		t.Fatalf("cancelled call reset the failure count, got %v", cb.State())
+	}
+
+	// A probe abandoned by its caller frees its slot and leaves the breaker half-open
+	clock.Advance(time.Second)
+	giveUp(context.DeadlineExceeded)
+	if cb.State() != StateHalfOpen {
+		t.Fatalf("abandoned probe decided the state, got %v", cb.State())
+	}
+
+	// A panicking probe counts as a failure and still releases the slot
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatal("panic was swallowed")
+			}
+		}()
+		cb.Execute(ctx, func(context.Context) (int, error) { panic("oops") })
+	}()
+	if cb.State() != StateOpen {
+		t.Fatalf("panicking probe should reopen, got %v", cb.State())
+	}
+	clock.Advance(time.Second)
+	if _, err := cb.Execute(ctx, func(context.Context) (int, error) { return 1, nil }); err != nil {
+		t.Fatalf("probe slot leaked: %v", err)
+	}
+}
+
+func TestCircuitBreaker_DependencyTimeoutTrips(t *testing.T) {
+	ctx := context.Background()
+	clock := NewFakeClock(time.Unix(0, 0))
+	cb := NewCircuitBreaker[int]("api",
+		WithBreakerClock(clock),
+		WithTripPolicy(ConsecutiveFailures(2)),
+		WithOpenTimeout(time.Second),
+	)
+	// fn's own timeout fires while the caller is still waiting
+	slow := func(context.Context) (int, error) {
+		return 0, fmt.Errorf("GET /orders: %w", context.DeadlineExceeded)
+	}
+
+	cb.Execute(ctx, slow)
+	cb.Execute(ctx, slow)
+	if cb.State() != StateOpen {
+		t.Fatalf("expected a timing-out dependency to open the breaker, got %v", cb.State())
+	}
+	if _, err := cb.Execute(ctx, slow); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("expected ErrCircuitOpen, got %v", err)
+	}
+
+	// A timed-out probe under a live ctx reopens the breaker
+	clock.Advance(time.Second)
+	cb.Execute(ctx, slow)
+	if cb.State() != StateOpen {
+		t.Errorf("expected the timed-out probe to reopen, got %v", cb.State())
+	}
+}
+
+func TestFailureRatio_RollingWindow(t *testing.T) {
+	p := FailureRatio(0.5, 4, 10*time.Second, 10)
+	now := time.Unix(100, 0)
+
+	p.Record(now, false)
+	p.Record(now, false)
+	if p.Record(now, true) {
+		t.Fatal("expected no trip below minCalls")
+	}
+	// The old failures leave the window, so 1 failure out of 3 recent calls stays below 50%
+	later := now.Add(15 * time.Second)
+	p.Record(later, true)
+	p.Record(later, true)
+	p.Record(later, false)
+	if p.Record(later, true) {
+		t.Error("expected old failures to have rolled out of the window")
+	}
+	if p.Record(later, false) {
+		t.Error("expected no trip at 40% failures")
+	}
+	if !p.Record(later, false) {
+		t.Error("expected trip at 50% failures")
+	}
+}
+
+func TestBulkhead_QueueAndTimeout(t *testing.T) {
+	ctx := context.Background()
+	clock := NewFakeClock(time.Unix(0, 0))
+	b := NewBulkhead("payments", 1, 1, time.Second, WithBulkheadClock(clock))
+
+	release, err := b.Acquire(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A queued call times out once the queue timeout has passed
+	queued := make(chan error)
+	go func() {
+		_, err := b.Acquire(ctx)
+		queued <- err
+	}()
+	if err := clock.BlockUntil(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(time.Second)
+	if err := <-queued; !errors.Is(err, ErrBulkheadTimeout) {
+		t.Errorf("expected ErrBulkheadTimeout, got %v", err)
+	}
+
+	b.queue <- struct{}{} // occupy the only queue place
+	if _, err := b.Acquire(ctx); !errors.Is(err, ErrBulkheadFull) {
+		t.Errorf("expected ErrBulkheadFull, got %v", err)
+	}
+	<-b.queue
+
+	release()
+	if err := b.Execute(ctx, func(context.Context) error { return nil }); err != nil {
+		t.Errorf("expected free slot after release, got %v", err)
+	}
+	if b.InFlight() != 0 || b.Queued() != 0 {
+		t.Errorf("expected empty bulkhead, got %d in flight, %d queued", b.InFlight(), b.Queued())
+	}
+}
+
+func TestMetrics_Recorded(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	SetMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)))
+	defer SetMeterProvider(nil)
+
+	ctx := context.Background()
+	for range WorkerPool(ctx, []int{1, 2, 3}, func(ctx context.Context, n int) (int, error) { return n, nil }, 2) {
+	}
+
+	rl := NewRateLimiter(1, 1)
+	rl.Allow()
+	rl.Allow() // rejected
+
+	g, _ := WithContext(ctx)
+	g.GoNamed(ctx, "failing", func() error { return errors.New("boom") })
+	_ = g.Wait()
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(ctx, &rm); err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]metricdata.Aggregation)
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			got[m.Name] = m.Data
+		}
+	}
+
+	hist, ok := got["patterns.task.duration"].(metricdata.Histogram[float64])
+	if !ok {
+		t.Fatalf("task duration histogram missing, got %v", got)
+	}
+	var tasks uint64
+	for _, dp := range hist.DataPoints {
+		tasks += dp.Count
+	}
+	if tasks != 4 {
+		t.Errorf("expected 4 task durations (3 pool, 1 errgroup), got %d", tasks)
+	}
+
+	queue, ok := got["patterns.pool.queue_depth"].(metricdata.Sum[int64])
+	if !ok || len(queue.DataPoints) == 0 || queue.DataPoints[0].Value != 0 {
+		t.Errorf("expected the drained queue depth to be 0, got %+v", got["patterns.pool.queue_depth"])
+	}
+
+	for _, name := range []string{"patterns.ratelimiter.rejections", "patterns.errgroup.task_failures"} {
+		sum, ok := got[name].(metricdata.Sum[int64])
+		if !ok || len(sum.DataPoints) == 0 || sum.DataPoints[0].Value != 1 {
+			t.Errorf("expected %s to be 1, got %+v", name, got[name])
+		}
+	}
+}
+
+func TestMetrics_CachedPerProvider(t *testing.T) {
+	first := metrics()
+	if metrics() != first {
+		t.Fatal("expected the instruments to be cached")
+	}
+	if allocs := testing.AllocsPerRun(100, func() { metrics() }); allocs != 0 {
+		t.Errorf("expected a cache hit not to allocate, got %v allocs", allocs)
+	}
+
+	SetMeterProvider(sdkmetric.NewMeterProvider())
+	defer SetMeterProvider(nil)
+	if metrics() == first {
+		t.Error("expected new instruments for a new provider")
+	}
+}
+
+func TestSingleFlight_CollapsesCalls(t *testing.T) {
+	sf := NewSingleFlight[string, int]()
+	var calls atomic.Int32
+	release := make(chan struct{})
+	fn := func(ctx context.Context) (int, error) {
+		calls.Add(1)
+		<-release
+		return 42, nil
+	}
+
+	const n = 10
+	var wg sync.WaitGroup
+	var sharedCount atomic.Int32
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v, shared, err := sf.Do(context.Background(), "k", fn)
+			if err != nil || v != 42 {
+				t.Errorf("got %d, %v", v, err)
+			}
+			if shared {
+				sharedCount.Add(1)
+			}
+		}()
+	}
+	// Release the call once every other caller has joined it
+	eventually(t, func() bool {
+		sf.mu.Lock()
+		defer sf.mu.Unlock()
+		c := sf.calls["k"]
+		return c != nil && c.dups == n-1
+	}, "callers did not join the flight")
+	close(release)
+	wg.Wait()
+
+	if calls.Load() != 1 {
+		t.Errorf("expected 1 call, got %d", calls.Load())
+	}
+	if sharedCount.Load() != n {
+		t.Errorf("expected every caller to see a shared result, got %d", sharedCount.Load())
+	}
+}
+
+func TestSingleFlight_CallerCancel(t *testing.T) {
+	sf := NewSingleFlight[string, int]()
+	release := make(chan struct{})
+	fn := func(ctx context.Context) (int, error) {
+		<-release
+		return 1, ctx.Err()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		_, _, err := sf.Do(ctx, "k", fn)
+		done <- err
+	}()
+	eventually(t, func() bool { return sf.InFlight() > 0 }, "call did not start")
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected canceled, got %v", err)
+	}
+
+	// The flight keeps running for the remaining callers
+	go close(release)
+	v, _, err := sf.Do(context.Background(), "k", fn)
+	if err != nil || v != 1 {
+		t.Errorf("got %d, %v", v, err)
+	}
+}
+
+func TestSingleFlight_Panic(t *testing.T) {
+	sf := NewSingleFlight[string, int]()
+	_, _, err := sf.Do(context.Background(), "k", func(ctx context.Context) (int, error) {
+		panic("boom")
+	})
+	var pe *PanicError
+	if !errors.As(err, &pe) || pe.Value != "boom" {
+		t.Fatalf("expected PanicError, got %v", err)
+	}
+}
+
+func TestMemoCache_TTLAndStale(t *testing.T) {
+	clock := NewFakeClock(time.Unix(0, 0))
+	var loads atomic.Int32
+	c := NewMemoCache(func(ctx context.Context, k string) (int, error) {
+		return int(loads.Add(1)), nil
+	}, WithCacheTTL(time.Second), WithStaleWhileRevalidate(time.Second), WithCacheClock(clock))
+	ctx := context.Background()
+
+	if v, _ := c.Get(ctx, "a"); v != 1 {
+		t.Fatalf("expected 1, got %d", v)
+	}
+	if v, _ := c.Get(ctx, "a"); v != 1 {
+		t.Fatalf("expected cached 1, got %d", v)
+	}
+
+	// Expired but within the stale window: old value, reload in the background
+	clock.Advance(1500 * time.Millisecond)
+	if v, _ := c.Get(ctx, "a"); v != 1 {
+		t.Fatalf("expected stale 1, got %d", v)
+	}
+	eventually(t, func() bool { return loads.Load() == 2 && c.flight.InFlight() == 0 }, "no background reload")
+	if v, _ := c.Get(ctx, "a"); v != 2 {
+		t.Fatalf("expected refreshed 2, got %d", v)
+	}
+
+	// Past the stale window: a blocking reload
+	clock.Advance(3 * time.Second)
+	if v, _ := c.Get(ctx, "a"); v != 3 {
+		t.Fatalf("expected reloaded 3, got %d", v)
+	}
+}
+
+func TestMemoCache_NegativeCaching(t *testing.T) {
+	clock := NewFakeClock(time.Unix(0, 0))
+	var loads atomic.Int32
+	errLoad := errors.New("unavailable")
+	c := NewMemoCache(func(ctx context.Context, k string) (int, error) {
+		loads.Add(1)
+		return 0, errLoad
+	}, WithNegativeTTL(time.Second), WithCacheClock(clock))
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		if _, err := c.Get(ctx, "a"); !errors.Is(err, errLoad) {
+			t.Fatalf("expected load error, got %v", err)
+		}
+	}
+	if loads.Load() != 1 {
+		t.Errorf("expected the error to be cached, got %d loads", loads.Load())
+	}
+	clock.Advance(2 * time.Second)
+	c.Get(ctx, "a")
+	if loads.Load() != 2 {
+		t.Errorf("expected a reload after the negative TTL, got %d loads", loads.Load())
+	}
+}
+
+func TestMemoCache_EvictionAndConcurrency(t *testing.T) {
+	var loads atomic.Int32
+	release := make(chan struct{})
+	c := NewMemoCache(func(ctx context.Context, k int) (int, error) {
+		loads.Add(1)
+		<-release
+		return k * 10, nil
+	}, WithMaxEntries(2))
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if v, err := c.Get(ctx, 1); err != nil || v != 10 {
+				t.Errorf("got %d, %v", v, err)
+			}
+		}()
+	}
+	eventually(t, func() bool {
+		c.flight.mu.Lock()
+		defer c.flight.mu.Unlock()
+		call := c.flight.calls[1]
+		return call != nil && call.dups == 49
+	}, "misses did not join the load")
+	close(release)
+	wg.Wait()
+	if loads.Load() != 1 {
+		t.Errorf("expected concurrent misses to share one load, got %d", loads.Load())
+	}
+
+	c.Get(ctx, 2)
+	c.Get(ctx, 1) // 1 is now the most recently used
+	c.Get(ctx, 3) // evicts 2
+	if c.Len() != 2 {
+		t.Fatalf("expected 2 entries, got %d", c.Len())
+	}
+	before := loads.Load()
+	c.Get(ctx, 1)
+	if loads.Load() != before {
+		t.Errorf("expected 1 to stay cached")
+	}
+	c.Get(ctx, 2)
+	if loads.Load() != before+1 {
+		t.Errorf("expected 2 to be evicted and reloaded")
+	}
+}
+
+func TestMemoCache_InvalidateDuringLoad(t *testing.T) {
+	var loads atomic.Int32
+	started := make(chan struct{})
+	release := make(chan struct{})
+	c := NewMemoCache(func(ctx context.Context, k string) (int, error) {
+		n := int(loads.Add(1))
+		if n == 1 {
+			close(started)
+			<-release
+		}
+		return n, nil
+	})
+	ctx := context.Background()
+
+	got := make(chan int)
+	go func() {
+		v, _ := c.Get(ctx, "a")
+		got <- v
+	}()
+	<-started
+	c.Invalidate("a")
+	close(release)
+	if v := <-got; v != 1 {
+		t.Fatalf("expected the caller of the load to get 1, got %d", v)
+	}
+	if c.Len() != 0 {
+		t.Fatal("expected the invalidated load not to be cached")
+	}
+	if v, _ := c.Get(ctx, "a"); v != 2 {
+		t.Fatalf("expected a fresh load after Invalidate, got %d", v)
+	}
+
+	// A slow load must not overwrite a value Set while it ran
+	started, release = make(chan struct{}), make(chan struct{})
+	loads.Store(0)
+	go func() {
+		v, _ := c.Get(ctx, "b")
+		got <- v
+	}()
+	<-started
+	c.Set("b", 42)
+	close(release)
+	<-got
+	if v, _ := c.Get(ctx, "b"); v != 42 {
+		t.Errorf("expected Set to win over the slower load, got %d", v)
+	}
+
+	// Nor one that was running when the cache was purged
+	started, release = make(chan struct{}), make(chan struct{})
+	loads.Store(0)
+	go func() {
+		v, _ := c.Get(ctx, "c")
+		got <- v
+	}()
+	<-started
+	c.Purge()
+	close(release)
+	<-got
+	if c.Len() != 0 {
+		t.Errorf("expected the purged load not to be cached, got %d entries", c.Len())
+	}
+	c.mu.Lock()
+	pending := len(c.loading)
+	c.mu.Unlock()
+	if pending != 0 {
+		t.Errorf("expected no load bookkeeping left, got %d keys", pending)
+	}
+}
+
+// eventually polls cond, yielding to other goroutines, until it holds or
+// five seconds have passed.
+func eventually(t *testing.T, cond func() bool, msg string) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal(msg)
+		}
+		runtime.Gosched()
+	}
+}
+
+// drive advances clock by step whenever a timer is pending, until ctx ends.
+func drive(ctx context.Context, clock *FakeClock, step time.Duration) {
+	go func() {
+		for clock.BlockUntil(ctx, 1) == nil {
+			clock.Advance(step)
+		}
+	}()
+}
+
+func TestCron_Next(t *testing.T) {
+	tests := []struct {
+		expr string
+		from string
+		want string
+	}{
+		{"*/15 9-17 * * 1-5", "2024-03-08T17:50:00Z", "2024-03-11T09:00:00Z"}, // Friday evening -> Monday
+		{"0 0 1 * *", "2024-01-31T12:00:00Z", "2024-02-01T00:00:00Z"},
+		{"30 2 29 2 *", "2024-03-01T00:00:00Z", "2028-02-29T02:30:00Z"},
+		{"@hourly", "2024-01-01T10:00:00Z", "2024-01-01T11:00:00Z"},
+		{"0 12 13 * 5", "2024-09-10T00:00:00Z", "2024-09-13T12:00:00Z"}, // 13th or a Friday
+		{"5 0 * * 7", "2024-09-10T00:00:00Z", "2024-09-15T00:05:00Z"},  // 7 is Sunday
+	}
+	for _, tt := range tests {
+		c, err := ParseCron(tt.expr)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.expr, err)
+		}
+		from, _ := time.Parse(time.RFC3339, tt.from)
+		if got := c.Next(from).Format(time.RFC3339); got != tt.want {
+			t.Errorf("%s from %s: got %s, want %s", tt.expr, tt.from, got, tt.want)
+		}
+	}
+
+	for _, expr := range []string{"* * * *", "60 * * * *", "*/0 * * * *", "1-x * * * *", "5-1 * * * *"} {
+		if _, err := ParseCron(expr); err == nil {
+			t.Errorf("%q: expected an error", expr)
+		}
+	}
+}
+
+func TestScheduler_DelayedAndCancel(t *testing.T) {
+	patternstest.VerifyNoLeaks(t)
+	clock := NewFakeClock(time.Unix(0, 0))
+	s := NewScheduler(WithSchedulerClock(clock))
+	defer s.Stop()
+
+	ran := make(chan struct{}, 1)
+	task, _ := s.After(5*time.Second, func(ctx context.Context) error {
+		ran <- struct{}{}
+		return nil
+	})
+	cancelled, _ := s.After(5*time.Second, func(ctx context.Context) error {
+		t.Error("cancelled task ran")
+		return nil
+	})
+	if !cancelled.Cancel() {
+		t.Fatal("expected Cancel to remove the task")
+	}
+	<-cancelled.Done()
+
+	clock.Advance(4 * time.Second)
+	select {
+	case <-ran:
+		t.Fatal("task ran too early")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	clock.Advance(time.Second)
+	<-ran
+	<-task.Done()
+	if task.Runs() != 1 || s.Pending() != 0 {
+		t.Errorf("expected one run and no pending tasks, got %d runs, %d pending", task.Runs(), s.Pending())
+	}
+}
+
+func TestScheduler_Priority(t *testing.T) {
+	clock := NewFakeClock(time.Unix(0, 0))
+	s := NewScheduler(WithSchedulerClock(clock), WithSchedulerWorkers(1))
+	defer s.Stop()
+
+	block := make(chan struct{})
+	var mu sync.Mutex
+	var order []string
+	record := func(name string) func(context.Context) error {
+		return func(ctx context.Context) error {
+			mu.Lock()
+			order = append(order, name)
+			mu.Unlock()
+			return nil
+		}
+	}
+
+	blocker, _ := s.After(0, func(ctx context.Context) error {
+		<-block
+		return nil
+	})
+	eventually(t, func() bool { return s.Pending() == 0 }, "blocker did not start")
+
+	low, _ := s.After(time.Second, record("low"), WithPriority(1))
+	high, _ := s.After(time.Second, record("high"), WithPriority(10))
+	clock.Advance(time.Second)
+	eventually(t, func() bool { return s.Pending() == 2 }, "tasks did not become due")
+	close(block)
+
+	<-blocker.Done()
+	<-low.Done()
+	<-high.Done()
+	if strings.Join(order, ",") != "high,low" {
+		t.Errorf("expected high priority first, got %v", order)
+	}
+}
+
+func TestScheduler_MisfirePolicies(t *testing.T) {
+	start := time.Unix(0, 0)
+	tests := []struct {
+		policy MisfirePolicy
+		runs   int
+	}{
+		{MisfireRunAll, 10},
+		{MisfireRunOnce, 1},
+		{MisfireSkip, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.policy.String(), func(t *testing.T) {
+			clock := NewFakeClock(start)
+			s := NewScheduler(WithSchedulerClock(clock), WithMisfirePolicy(tt.policy))
+			defer s.Stop()
+
+			task, _ := s.FixedRate(time.Second, time.Second, func(ctx context.Context) error { return nil })
+			// Jump past 10s at once: the runs planned at 1s..10s are late
+			clock.Advance(10*time.Second + 500*time.Millisecond)
+
+			want := start.Add(11 * time.Second)
+			eventually(t, func() bool {
+				next, ok := task.NextRun()
+				return ok && next.Equal(want) && task.Runs() == tt.runs
+			}, "task did not settle")
+			s.Stop() // waits for the runs in progress
+			if task.Runs() != tt.runs {
+				t.Errorf("expected %d runs, got %d", tt.runs, task.Runs())
+			}
+		})
+	}
+}
+
+func TestScheduler_FixedDelayAndCron(t *testing.T) {
+	clock := NewFakeClock(time.Date(2024, 1, 1, 0, 0, 30, 0, time.UTC))
+	var errs atomic.Int32
+	s := NewScheduler(WithSchedulerClock(clock), WithSchedulerErrorHandler(func(string, error) { errs.Add(1) }))
+
+	delayed, _ := s.FixedDelay(0, 2*time.Second, func(ctx context.Context) error {
+		return errors.New("fails every time")
+	}, WithTaskName("delayed"))
+	eventually(t, func() bool { return delayed.Runs() == 1 }, "first run missing")
+	next, _ := delayed.NextRun()
+	if want := clock.Now().Add(2 * time.Second); !next.Equal(want) {
+		t.Errorf("expected next run at %v, got %v", want, next)
+	}
+
+	cron, err := s.Cron("*/5 * * * *", func(ctx context.Context) error { return nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+	next, _ = cron.NextRun()
+	if want := time.Date(2024, 1, 1, 0, 5, 0, 0, time.UTC); !next.Equal(want) {
+		t.Errorf("expected cron at %v, got %v", want, next)
+	}
+	if _, err := s.Cron("bogus", nil); err == nil {
+		t.Error("expected a parse error")
+	}
+	for _, d := range []time.Duration{0, -time.Second} {
+		if _, err := s.FixedDelay(0, d, func(ctx context.Context) error { return nil }); err == nil {
+			t.Errorf("expected FixedDelay to reject delay %v", d)
+		}
+		if _, err := s.FixedRate(0, d, func(ctx context.Context) error { return nil }); err == nil {
+			t.Errorf("expected FixedRate to reject period %v", d)
+		}
+	}
+
+	s.Stop()
+	<-delayed.Done()
+	<-cron.Done()
+	if errs.Load() < 1 {
+		t.Error("expected the error handler to be called")
+	}
+	if _, err := s.After(0, nil); !errors.Is(err, ErrSchedulerStopped) {
+		t.Errorf("expected ErrSchedulerStopped, got %v", err)
+	}
+}
+
+func TestPool_BorrowReuseAndLimit(t *testing.T) {
+	var created atomic.Int32
+	p := NewPool(func(ctx context.Context) (int, error) {
+		return int(created.Add(1)), nil
+	}, WithMaxSize[int](2), WithHealthCheckInterval[int](0))
+	ctx := context.Background()
+
+	a, _ := p.Acquire(ctx)
+	b, _ := p.Acquire(ctx)
+	if a.Value() == b.Value() {
+		t.Fatal("expected two distinct resources")
+	}
+
+	// The pool is exhausted: borrowing times out
+	tctx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	if _, err := p.Acquire(tctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+
+	// A waiter gets the released resource
+	got := make(chan int)
+	go func() {
+		r, err := p.Acquire(ctx)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		got <- r.Value()
+		r.Release()
+	}()
+	eventually(t, func() bool { return p.Stats().Waiting == 1 }, "borrower did not wait")
+	a.Release()
+	if v := <-got; v != a.Value() {
+		t.Errorf("expected resource %d to be reused, got %d", a.Value(), v)
+	}
+
+	b.Destroy()
+	s := p.Stats()
+	if s.Open != 1 || s.Idle != 1 || s.InUse != 0 || s.Created != 2 || s.Destroyed != 1 {
+		t.Errorf("unexpected stats %+v", s)
+	}
+	if err := p.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.Acquire(ctx); !errors.Is(err, ErrPoolClosed) {
+		t.Errorf("expected ErrPoolClosed, got %v", err)
+	}
+}
+
+func TestPool_ValidationAndLifetime(t *testing.T) {
+	clock := NewFakeClock(time.Unix(0, 0))
+	var created atomic.Int32
+	var destroyed sync.Map
+	bad := sync.Map{}
+	p := NewPool(func(ctx context.Context) (int, error) {
+		return int(created.Add(1)), nil
+	},
+		WithMinSize[int](2),
+		WithMaxLifetime[int](time.Minute),
+		WithIdleTimeout[int](10*time.Second),
+		WithHealthCheckInterval[int](5*time.Second),
+		WithPoolClock[int](clock),
+		WithValidate[int](func(ctx context.Context, v int) error {
+			if _, ok := bad.Load(v); ok {
+				return errors.New("unhealthy")
+			}
+			return nil
+		}),
+		WithDestroy[int](func(v int) { destroyed.Store(v, true) }),
+	)
+	ctx := context.Background()
+	defer p.Close(ctx)
+
+	if s := p.Stats(); s.Open != 2 || s.Idle != 2 {
+		t.Fatalf("expected the pool to be filled to its minimum, got %+v", s)
+	}
+
+	// Validate-on-borrow: the unhealthy resource is replaced by the next one
+	r, _ := p.Acquire(ctx)
+	bad.Store(r.Value(), true)
+	first := r.Value()
+	r.Release()
+	r, _ = p.Acquire(ctx)
+	if r.Value() == first {
+		t.Fatal("expected the unhealthy resource not to be lent")
+	}
+	if _, ok := destroyed.Load(first); !ok {
+		t.Error("expected the unhealthy resource to be destroyed")
+	}
+	r.Release()
+
+	// The health check replaces resources past their max lifetime
+	before := created.Load()
+	wctx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	if err := clock.BlockUntil(wctx, 1); err != nil {
+		t.Fatal("health check is not scheduled")
+	}
+	clock.Advance(time.Minute)
+	eventually(t, func() bool {
+		s := p.Stats()
+		return created.Load() >= before+2 && s.Open == 2 && s.Idle == 2
+	}, "expired resources were not replaced")
+}
+
+func TestPool_CloseWaitsForBorrowed(t *testing.T) {
+	patternstest.VerifyNoLeaks(t)
+	p := NewPool(func(ctx context.Context) (string, error) { return "conn", nil })
+	ctx := context.Background()
+	r, _ := p.Acquire(ctx)
+
+	tctx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	if err := p.Close(tctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected Close to wait for the borrowed resource, got %v", err)
+	}
+	r.Release()
+	if err := p.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if s := p.Stats(); s.Open != 0 || s.Destroyed != 1 {
+		t.Errorf("expected an empty pool, got %+v", s)
+	}
+}
+
+func TestWeightedSemaphore(t *testing.T) {
+	s := NewWeightedSemaphore(10)
+	ctx := context.Background()
+
+	if err := s.Acquire(ctx, 7); err != nil {
+		t.Fatal(err)
+	}
+	if s.TryAcquire(4) {
+		t.Fatal("expected no room for 4")
+	}
+	if err := s.Acquire(ctx, 11); err == nil {
+		t.Fatal("expected an error for a weight above the size")
+	}
+
+	// A large waiter at the head blocks smaller ones behind it (FIFO)
+	var mu sync.Mutex
+	var order []int64
+	var wg sync.WaitGroup
+	acquire := func(n int64) {
+		defer wg.Done()
+		if err := s.Acquire(ctx, n); err != nil {
+			t.Error(err)
+			return
+		}
+		mu.Lock()
+		order = append(order, n)
+		mu.Unlock()
+	}
+	wg.Add(1)
+	go acquire(8)
+	eventually(t, func() bool { s.mu.Lock(); defer s.mu.Unlock(); return s.waiters.Len() == 1 }, "8 is not waiting")
+	wg.Add(1)
+	go acquire(3)
+	eventually(t, func() bool { s.mu.Lock(); defer s.mu.Unlock(); return s.waiters.Len() == 2 }, "3 is not waiting")
+	if s.TryAcquire(1) {
+		t.Fatal("expected TryAcquire not to jump the queue")
+	}
+
+	s.Release(7)
+	eventually(t, func() bool { mu.Lock(); defer mu.Unlock(); return len(order) == 1 }, "8 was not granted")
+	s.Release(8)
+	wg.Wait()
+	if len(order) != 2 || order[0] != 8 || order[1] != 3 {
+		t.Errorf("expected FIFO order [8 3], got %v", order)
+	}
+	if s.Available() != 7 {
+		t.Errorf("expected 7 available, got %d", s.Available())
+	}
+
+	// A cancelled head waiter lets the ones behind it through
+	s.Release(3)
+	s.Acquire(ctx, 5)
+	cctx, cancel := context.WithCancel(ctx)
+	done := make(chan error)
+	go func() { done <- s.Acquire(cctx, 9) }()
+	eventually(t, func() bool { s.mu.Lock(); defer s.mu.Unlock(); return s.waiters.Len() == 1 }, "9 is not waiting")
+	wg.Add(1)
+	go acquire(5)
+	eventually(t, func() bool { s.mu.Lock(); defer s.mu.Unlock(); return s.waiters.Len() == 2 }, "5 is not waiting")
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected canceled, got %v", err)
+	}
+	wg.Wait()
+}
+
+// supervisedCounter is a child that counts its starts and fails its first failures runs.
+type supervisedCounter struct {
+	starts   atomic.Int32
+	failures int32
+	panics   bool
+}
+
+func (c *supervisedCounter) run(ctx context.Context) error {
+	n := c.starts.Add(1)
+	if n <= c.failures {
+		if c.panics {
+			panic("crash")
+		}
+		return fmt.Errorf("failure %d", n)
+	}
+	<-ctx.Done()
+	return nil
+}
+
+func TestSupervisor_Strategies(t *testing.T) {
+	patternstest.VerifyNoLeaks(t)
+	tests := []struct {
+		strategy RestartStrategy
+		want     [3]int32 // starts of a, b (fails once), c
+	}{
+		{OneForOne, [3]int32{1, 2, 1}},
+		{OneForAll, [3]int32{2, 2, 2}},
+		{RestForOne, [3]int32{1, 2, 2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.strategy.String(), func(t *testing.T) {
+			a, b, c := &supervisedCounter{}, &supervisedCounter{failures: 1, panics: true}, &supervisedCounter{}
+			clock := NewFakeClock(time.Unix(0, 0))
+			sv := NewSupervisor("test", WithStrategy(tt.strategy), WithRestartBackoff(time.Millisecond, 10*time.Millisecond),
+				WithSupervisorClock(clock))
+			sv.Add(ChildSpec{Name: "a", Run: a.run})
+			sv.Add(ChildSpec{Name: "b", Run: b.run})
+			sv.Add(ChildSpec{Name: "c", Run: c.run})
+
+			ctx, cancel := context.WithCancel(context.Background())
+			done := make(chan error)
+			go func() { done <- sv.Run(ctx) }()
+
+			// Every restarted child waits out its backoff on the clock
+			restarts := int(tt.want[0] + tt.want[1] + tt.want[2] - 3)
+			eventually(t, func() bool { return clock.Timers() == restarts }, "restarts not scheduled")
+			clock.Advance(time.Millisecond)
+			eventually(t, func() bool {
+				return a.starts.Load() == tt.want[0] && b.starts.Load() == tt.want[1] && c.starts.Load() == tt.want[2]
+			}, "unexpected restarts")
+			if n := clock.Timers(); n != 0 {
+				t.Errorf("expected no further restarts, %d pending", n)
+			}
+			cancel()
+			if err := <-done; err != nil {
+				t.Fatal(err)
+			}
+			got := [3]int32{a.starts.Load(), b.starts.Load(), c.starts.Load()}
+			if got != tt.want {
+				t.Errorf("expected starts %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestSupervisor_PoliciesAndOrderedShutdown(t *testing.T) {
+	clock := NewFakeClock(time.Unix(0, 0))
+	sv := NewSupervisor("test", WithRestartBackoff(time.Millisecond, time.Millisecond), WithSupervisorClock(clock))
+
+	var mu sync.Mutex
+	var stopped []string
+	service := func(name string) func(context.Context) error {
+		return func(ctx context.Context) error {
+			<-ctx.Done()
+			mu.Lock()
+			stopped = append(stopped, name)
+			mu.Unlock()
+			return nil
+		}
+	}
+	var transientRuns, temporaryRuns atomic.Int32
+	sv.Add(ChildSpec{Name: "db", Run: service("db")})
+	sv.Add(ChildSpec{Name: "once", Restart: Transient, Run: func(ctx context.Context) error {
+		transientRuns.Add(1)
+		return nil
+	}})
+	sv.Add(ChildSpec{Name: "temp", Restart: Temporary, Run: func(ctx context.Context) error {
+		temporaryRuns.Add(1)
+		return errors.New("failed")
+	}})
+	sv.Add(ChildSpec{Name: "http", Run: service("http")})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- sv.Run(ctx) }()
+	eventually(t, func() bool { return transientRuns.Load() == 1 && temporaryRuns.Load() == 1 }, "children did not run")
+	if err := sv.Add(ChildSpec{Name: "late"}); !errors.Is(err, ErrSupervisorRunning) {
+		t.Errorf("expected ErrSupervisorRunning, got %v", err)
+	}
+	// A restart would wait out its backoff; let any pending one through
+	clock.Advance(time.Millisecond)
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	if transientRuns.Load() != 1 || temporaryRuns.Load() != 1 {
+		t.Errorf("expected no restarts, got %d transient and %d temporary runs", transientRuns.Load(), temporaryRuns.Load())
+	}
+	if strings.Join(stopped, ",") != "http,db" {
+		t.Errorf("expected reverse shutdown order, got %v", stopped)
+	}
+}
+
+func TestSupervisor_IntensityEscalates(t *testing.T) {
+	crashing := &supervisedCounter{failures: 1 << 30}
+	child := NewSupervisor("child", WithRestartIntensity(2, time.Minute), WithRestartBackoff(time.Millisecond, time.Millisecond))
+	child.Add(ChildSpec{Name: "crashing", Run: crashing.run})
+
+	if err := child.Run(context.Background()); !errors.Is(err, ErrTooManyRestarts) {
+		t.Fatalf("expected ErrTooManyRestarts, got %v", err)
+	}
+	if crashing.starts.Load() != 3 {
+		t.Errorf("expected 3 starts (1 + 2 restarts), got %d", crashing.starts.Load())
+	}
+
+	// Under a parent the failure escalates: the parent restarts the whole child supervisor
+	crashing.starts.Store(0)
+	parent := NewSupervisor("parent", WithRestartIntensity(1, time.Minute), WithRestartBackoff(time.Millisecond, time.Millisecond))
+	parent.Add(child.AsChild())
+	if err := parent.Run(context.Background()); !errors.Is(err, ErrTooManyRestarts) {
+		t.Fatalf("expected the parent to give up too, got %v", err)
+	}
+	if crashing.starts.Load() != 6 {
+		t.Errorf("expected the child supervisor to run twice, got %d starts", crashing.starts.Load())
+	}
+}
+
+func TestFakeClock_BlockUntil(t *testing.T) {
+	clock := NewFakeClock(time.Unix(0, 0))
+	fired := make(chan time.Time)
+	go func() {
+		timer := clock.NewTimer(time.Second)
+		fired <- <-timer.C()
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := clock.BlockUntil(ctx, 1); err != nil {
+		t.Fatal("timer was not created")
+	}
+	clock.Advance(999 * time.Millisecond)
+	if clock.Timers() != 1 {
+		t.Fatal("timer fired early")
+	}
+	clock.Advance(time.Millisecond)
+	if at := <-fired; !at.Equal(time.Unix(1, 0)) {
+		t.Errorf("expected the timer to fire at 1s, got %v", at)
+	}
+}
+
+func TestStreamPool_TaskTimeout(t *testing.T) {
+	patternstest.VerifyNoLeaks(t)
+	clock := NewFakeClock(time.Unix(0, 0))
+	worker := func(ctx context.Context, n int) (int, error) {
+		if _, ok := ctx.Deadline(); !ok {
+			return n, errors.New("task context has no deadline")
+		}
+		if n == 0 {
+			return n, nil
+		}
+		<-ctx.Done()
+		return n, ctx.Err()
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	// The real clock uses a plain context.WithTimeout
+	if res := <-WorkerPool(ctx, []int{0}, worker, 1, WithTaskTimeout(time.Minute)); res.Err != nil {
+		t.Fatalf("expected a deadline on the task context, got %v", res.Err)
+	}
+
+	results := WorkerPoolStream(ctx, Generator(ctx, 0, 1), worker, 2,
+		WithTaskTimeout(time.Minute), WithStreamClock(clock))
+	if res := <-results; res.Err != nil {
+		t.Fatalf("expected the fast task to succeed, got %v", res.Err)
+	}
+	// Both timers exist; the fast task's has been stopped
+	if err := clock.BlockUntil(ctx, 1); err != nil {
+		t.Fatal("slow task has no timeout")
+	}
+	clock.Advance(time.Minute)
+	if res := <-results; !errors.Is(res.Err, context.DeadlineExceeded) {
+		t.Fatalf("expected DeadlineExceeded, got %v", res.Err)
+	}
+
+	// WorkerPool takes the same options
+	results = WorkerPool(ctx, []int{1}, worker, 1, WithTaskTimeout(time.Minute), WithStreamClock(clock))
+	if err := clock.BlockUntil(ctx, 1); err != nil {
+		t.Fatal("task has no timeout")
+	}
+	clock.Advance(time.Minute)
+	if res := <-results; !errors.Is(res.Err, context.DeadlineExceeded) {
+		t.Fatalf("expected DeadlineExceeded, got %v", res.Err)
+	}
+}
+
+func TestWindows_FakeClock(t *testing.T) {
+	patternstest.VerifyNoLeaks(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	clock := NewFakeClock(time.Unix(0, 0))
+	tick := func() {
+		if err := clock.BlockUntil(ctx, 1); err != nil {
+			t.Fatal(err)
+		}
+		clock.Advance(time.Second)
+	}
+
+	// Batch emits a partial batch once maxWait has passed
+	in := make(chan int)
+	out := Batch(ctx, in, 3, time.Second, WithWindowClock(clock))
+	in <- 1
+	tick()
+	if b := <-out; fmt.Sprint(b) != "[1]" {
+		t.Errorf("expected the partial batch [1], got %v", b)
+	}
+	close(in)
+
+	in = make(chan int)
+	out = TumblingWindow(ctx, in, time.Second, WithWindowClock(clock))
+	in <- 1
+	in <- 2
+	tick()
+	if w := <-out; fmt.Sprint(w) != "[1 2]" {
+		t.Errorf("expected the window [1 2], got %v", w)
+	}
+	in <- 3
+	close(in)
+	if w := <-out; fmt.Sprint(w) != "[3]" {
+		t.Errorf("expected the last window [3], got %v", w)
+	}
+
+	// Items older than size drop out of the sliding window
+	in = make(chan int)
+	out = SlidingWindow(ctx, in, 2*time.Second, time.Second, WithWindowClock(clock))
+	in <- 1
+	tick()
+	if w := <-out; fmt.Sprint(w) != "[1]" {
+		t.Errorf("expected the window [1], got %v", w)
+	}
+	in <- 2
+	tick()
+	if w := <-out; fmt.Sprint(w) != "[2]" {
+		t.Errorf("expected the window [2], got %v", w)
+	}
+	close(in)
+	for range out {
+	}
+
+	throttled := make(chan int)
+	go func() {
+		defer close(throttled)
+		throttled <- 1
+		throttled <- 2
+	}()
+	items := Throttle(ctx, throttled, time.Second, WithWindowClock(clock))
+	if n := <-items; n != 1 {
+		t.Fatalf("expected 1 right away, got %d", n)
+	}
+	tick()
+	if n := <-items; n != 2 {
+		t.Errorf("expected 2 after the interval, got %d", n)
+	}
+	for range items {
+	}
+}
+
+func TestWeightedSemaphore_Interleavings(t *testing.T) {
+	patternstest.Explore(t, 50, func(t *testing.T, sched *patternstest.Schedule) {
+		patternstest.VerifyNoLeaks(t)
+		const size = 5
+		s := NewWeightedSemaphore(size)
+		var held atomic.Int64
+		var wg sync.WaitGroup
+		for i := 0; i < 6; i++ {
+			n := int64(1 + sched.Intn(size))
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				sched.Yield()
+				if err := s.Acquire(context.Background(), n); err != nil {
+					t.Error(err)
+					return
+				}
+				if h := held.Add(n); h > size {
+					t.Errorf("%d units held, size is %d", h, size)
+				}
+				sched.Yield()
+				held.Add(-n)
+				s.Release(n)
+			}()
+		}
+		wg.Wait()
+		if s.Available() != size {
+			t.Errorf("expected all %d units back, got %d", size, s.Available())
+		}
+	})
+}
@@ -0,0 +1,85 @@
+package patternstest
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"runtime"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// SeedEnv names the environment variable that makes Explore run one seed only,
+// to reproduce a failure it reported.
+const SeedEnv = "PATTERNS_EXPLORE_SEED"
+
+// Schedule perturbs goroutine interleavings during one Explore run.
+type Schedule struct {
+	seed int64
+
+	mu  sync.Mutex
+	rnd *rand.Rand
+}
+
+// Seed returns the seed of this run.
+func (s *Schedule) Seed() int64 {
+	return s.seed
+}
+
+// Yield marks a point where another goroutine may run. Depending on the seed
+// it returns at once or yields the processor a few times. It is safe for
+// concurrent use, but the draw order then depends on the real schedule.
+func (s *Schedule) Yield() {
+	for n := s.draw(4); n > 0; n-- {
+		runtime.Gosched()
+	}
+}
+
+// Intn returns a number in [0, n) from the run's seed, for tests that pick
+// operations or orderings at random.
+func (s *Schedule) Intn(n int) int {
+	return s.draw(n)
+}
+
+func (s *Schedule) draw(n int) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.rnd.Intn(n)
+}
+
+// Explore runs fn runs times, each as a subtest named after its seed, with
+// GOMAXPROCS cycling through 1, 2 and the number of CPUs and with Yield
+// points perturbed by the seed. A failing seed can be replayed alone by
+// setting SeedEnv. Explore must not be used with parallel tests because it
+// changes GOMAXPROCS.
+func Explore(t *testing.T, runs int, fn func(t *testing.T, s *Schedule)) {
+	t.Helper()
+	seeds := make([]int64, runs)
+	for i := range seeds {
+		seeds[i] = int64(i + 1)
+	}
+	if env := os.Getenv(SeedEnv); env != "" {
+		seed, err := strconv.ParseInt(env, 10, 64)
+		if err != nil {
+			t.Fatalf("%s: %v", SeedEnv, err)
+		}
+		seeds = []int64{seed}
+	}
+
+	procs := []int{1, 2, runtime.NumCPU()}
+	prev := runtime.GOMAXPROCS(0)
+	defer runtime.GOMAXPROCS(prev)
+
+	for _, seed := range seeds {
+		runtime.GOMAXPROCS(procs[seed%int64(len(procs))])
+		s := &Schedule{rnd: rand.New(rand.NewSource(seed)), seed: seed}
+		ok := t.Run(fmt.Sprintf("seed=%d", seed), func(t *testing.T) {
+			fn(t, s)
+		})
+		if !ok {
+			t.Logf("replay with %s=%d", SeedEnv, seed)
+			return
+		}
+	}
+}
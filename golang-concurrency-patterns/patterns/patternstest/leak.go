@@ -0,0 +1,87 @@
+// Package patternstest has helpers for testing concurrent code
+// deterministically: goroutine leak checks and schedule exploration. A fake
+// clock lives in the patterns package as patterns.FakeClock.
+package patternstest
+
+import (
+	"bytes"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// leakGrace is how long stopping goroutines get to exit before they count as leaked.
+const leakGrace = time.Second
+
+// VerifyNoLeaks fails the test if goroutines started during it are still
+// running when it ends. Call it first so its check runs after the other
+// cleanups.
+func VerifyNoLeaks(t testing.TB) {
+	t.Helper()
+	before := goroutines()
+	t.Cleanup(func() {
+		t.Helper()
+		var leaked []string
+		deadline := time.Now().Add(leakGrace)
+		for {
+			leaked = leaked[:0]
+			for id, stack := range goroutines() {
+				if _, ok := before[id]; !ok && !ignored(stack) {
+					leaked = append(leaked, stack)
+				}
+			}
+			if len(leaked) == 0 || time.Now().After(deadline) {
+				break
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+		if len(leaked) > 0 {
+			t.Errorf("%d goroutine(s) leaked:\n\n%s", len(leaked), strings.Join(leaked, "\n\n"))
+		}
+	})
+}
+
+// goroutines returns the stack of every goroutine but the caller's, by ID.
+func goroutines() map[string]string {
+	buf := make([]byte, 1<<16)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			buf = buf[:n]
+			break
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+
+	stacks := make(map[string]string)
+	for i, g := range bytes.Split(buf, []byte("\n\n")) {
+		if i == 0 {
+			continue // the goroutine calling runtime.Stack
+		}
+		header, _, _ := strings.Cut(string(g), "\n")
+		id, _, _ := strings.Cut(strings.TrimPrefix(header, "goroutine "), " ")
+		stacks[id] = string(g)
+	}
+	return stacks
+}
+
+// ignored reports goroutines owned by the runtime or the test framework,
+// judged by the function on top of their stack.
+func ignored(stack string) bool {
+	lines := strings.SplitN(stack, "\n", 3)
+	if len(lines) < 2 {
+		return true
+	}
+	for _, fn := range []string{
+		"testing.(*T).Run(",
+		"testing.(*T).Parallel(",
+		"testing.runTests(",
+		"os/signal.signal_recv(",
+	} {
+		if strings.HasPrefix(lines[1], fn) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,204 @@
+package patterns
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// ErrPhaserTerminated is returned by a Phaser once it has terminated.
+var ErrPhaserTerminated = errors.New("phaser is terminated")
+
+// PhaserOption configures a Phaser.
+type PhaserOption func(*Phaser)
+
+// WithOnAdvance runs fn when a phase completes, with the completed phase and the
+// number of registered parties. Returning true terminates the phaser.
+// By default the phaser terminates when no parties are left.
+func WithOnAdvance(fn func(phase, parties int) bool) PhaserOption {
+	return func(p *Phaser) {
+		p.onAdvance = fn
+	}
+}
+
+// Phaser is a reusable barrier for multi-phase work whose number of
+// parties may change: parties can register and deregister between or during phases.
+type Phaser struct {
+	mu         sync.Mutex
+	phase      int
+	parties    int
+	arrived    int
+	terminated bool
+	advance    chan struct{} // closed when the current phase completes
+	onAdvance  func(phase, parties int) bool
+}
+
+func NewPhaser(parties int, opts ...PhaserOption) *Phaser {
+	p := &Phaser{
+		parties: parties,
+		advance: make(chan struct{}),
+		onAdvance: func(_, parties int) bool {
+			return parties == 0
+		},
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+// Register adds a party and returns the current phase.
+func (p *Phaser) Register() (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.terminated {
+		return p.phase, ErrPhaserTerminated
+	}
+	p.parties++
+	return p.phase, nil
+}
+
+// Arrive records the arrival of a party without waiting and returns the phase arrived at.
+func (p *Phaser) Arrive() (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.arriveLocked(false)
+}
+
+// ArriveAndDeregister records an arrival and removes the party for later phases.
+func (p *Phaser) ArriveAndDeregister() (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.arriveLocked(true)
+}
+
+// ArriveAndAwaitAdvance arrives and blocks until the other parties arrived too.
+// It returns the new phase. When ctx ends first, the arrival still counts,
+// so the other parties are not blocked by the one that gave up.
+func (p *Phaser) ArriveAndAwaitAdvance(ctx context.Context) (int, error) {
+	p.mu.Lock()
+	phase, err := p.arriveLocked(false)
+	p.mu.Unlock()
+	if err != nil {
+		return phase, err
+	}
+	return p.AwaitAdvance(ctx, phase)
+}
+
+// AwaitAdvance blocks until phase is over and returns the next phase.
+// It returns immediately if the phaser has already moved past phase.
+func (p *Phaser) AwaitAdvance(ctx context.Context, phase int) (int, error) {
+	tracer := otel.Tracer("phaser")
+	_, span := tracer.Start(ctx, "phaser_await_advance")
+	span.SetAttributes(attribute.Int("phaser.phase", phase))
+	defer span.End()
+
+	p.mu.Lock()
+	if p.phase != phase {
+		current, terminated := p.phase, p.terminated
+		p.mu.Unlock()
+		if terminated {
+			return current, ErrPhaserTerminated
+		}
+		return current, nil
+	}
+	advance := p.advance
+	p.mu.Unlock()
+
+	start := time.Now()
+	defer func() {
+		metrics().barrierWait.Record(ctx, sinceSeconds(start), patternAttr("phaser"))
+	}()
+
+	select {
+	case <-ctx.Done():
+		span.RecordError(ctx.Err())
+		return phase, ctx.Err()
+	case <-advance:
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.terminated {
+		return p.phase, ErrPhaserTerminated
+	}
+	return phase + 1, nil
+}
+
+// ForceTermination terminates the phaser and releases all waiters.
+func (p *Phaser) ForceTermination() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.terminated {
+		return
+	}
+	p.terminated = true
+	close(p.advance)
+}
+
+// Phase returns the current phase number.
+func (p *Phaser) Phase() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.phase
+}
+
+// Parties returns the number of registered parties.
+func (p *Phaser) Parties() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.parties
+}
+
+// Arrived returns the number of parties that arrived at the current phase.
+func (p *Phaser) Arrived() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.arrived
+}
+
+// IsTerminated reports whether the phaser has terminated.
+func (p *Phaser) IsTerminated() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.terminated
+}
+
+func (p *Phaser) arriveLocked(deregister bool) (int, error) {
+	phase := p.phase
+	if p.terminated {
+		return phase, ErrPhaserTerminated
+	}
+	if p.arrived >= p.parties {
+		return phase, errors.New("phaser: more arrivals than registered parties")
+	}
+
+	if deregister {
+		p.parties--
+	} else {
+		p.arrived++
+	}
+	if p.arrived == p.parties {
+		p.advanceLocked()
+	}
+	return phase, nil
+}
+
+// advanceLocked completes the current phase. Must be called with mu held.
+func (p *Phaser) advanceLocked() {
+	if p.onAdvance(p.phase, p.parties) {
+		p.terminated = true
+		close(p.advance)
+		return
+	}
+	p.phase++
+	p.arrived = 0
+	close(p.advance)
+	p.advance = make(chan struct{})
+}
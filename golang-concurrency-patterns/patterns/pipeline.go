@@ -78,3 +78,177 @@ func Map[T any, R any](ctx context.Context, in <-chan T, transform func(T) R) <-
 
 	return outCh
 }
+
+// Compose chains stages into a single stage, applied left to right.
+func Compose[T any](stages ...PipelineStage[T]) PipelineStage[T] {
+	return func(ctx context.Context, in <-chan T) <-chan T {
+		out := in
+		for _, stage := range stages {
+			out = stage(ctx, out)
+		}
+		return out
+	}
+}
+
+// FilterStage wraps Filter as a PipelineStage.
+func FilterStage[T any](predicate func(T) bool) PipelineStage[T] {
+	return func(ctx context.Context, in <-chan T) <-chan T {
+		return Filter(ctx, in, predicate)
+	}
+}
+
+// MapStage wraps a type-preserving Map as a PipelineStage.
+func MapStage[T any](transform func(T) T) PipelineStage[T] {
+	return func(ctx context.Context, in <-chan T) <-chan T {
+		return Map(ctx, in, transform)
+	}
+}
+
+// FlatMap emits every element of the slice returned for each item.
+func FlatMap[T any, R any](ctx context.Context, in <-chan T, transform func(T) []R) <-chan R {
+	outCh := make(chan R)
+	tracer := otel.Tracer("pipeline")
+
+	go func() {
+		defer close(outCh)
+		_, span := tracer.Start(ctx, "flat_map")
+		defer span.End()
+
+		for item := range in {
+			for _, mapped := range transform(item) {
+				select {
+				case <-ctx.Done():
+					return
+				case outCh <- mapped:
+				}
+			}
+		}
+	}()
+
+	return outCh
+}
+
+// Tee duplicates in into n channels. Every item is delivered to all of them,
+// so the slowest consumer sets the pace.
+func Tee[T any](ctx context.Context, in <-chan T, n int) []<-chan T {
+	outs := make([]chan T, n)
+	result := make([]<-chan T, n)
+	for i := range outs {
+		outs[i] = make(chan T)
+		result[i] = outs[i]
+	}
+	tracer := otel.Tracer("pipeline")
+
+	go func() {
+		defer func() {
+			for _, out := range outs {
+				close(out)
+			}
+		}()
+		_, span := tracer.Start(ctx, "tee")
+		defer span.End()
+
+		for item := range in {
+			for _, out := range outs {
+				select {
+				case <-ctx.Done():
+					return
+				case out <- item:
+				}
+			}
+		}
+	}()
+
+	return result
+}
+
+// Distinct drops items that were already seen.
+// It remembers every item, so use it on bounded streams.
+func Distinct[T comparable](ctx context.Context, in <-chan T) <-chan T {
+	return DistinctBy(ctx, in, func(item T) T { return item })
+}
+
+// DistinctBy drops items whose key was already seen.
+func DistinctBy[T any, K comparable](ctx context.Context, in <-chan T, key func(T) K) <-chan T {
+	outCh := make(chan T)
+	tracer := otel.Tracer("pipeline")
+
+	go func() {
+		defer close(outCh)
+		_, span := tracer.Start(ctx, "distinct")
+		defer span.End()
+
+		seen := make(map[K]struct{})
+		for item := range in {
+			k := key(item)
+			if _, ok := seen[k]; ok {
+				continue
+			}
+			seen[k] = struct{}{}
+			select {
+			case <-ctx.Done():
+				return
+			case outCh <- item:
+			}
+		}
+	}()
+
+	return outCh
+}
+
+// ParallelMap applies transform with up to concurrency goroutines.
+// Output order is arbitrary unless PreserveOrder() is passed.
+func ParallelMap[T any, R any](ctx context.Context, in <-chan T, concurrency int, transform func(T) R, opts ...StreamPoolOption) <-chan R {
+	task := func(_ context.Context, item T) (R, error) {
+		return transform(item), nil
+	}
+	results := WorkerPoolStream(ctx, in, task, concurrency, opts...)
+	return Map(ctx, results, func(r Result[R]) R { return r.Value })
+}
+
+// TryMap applies a fallible transform and reports failures as Result values
+// instead of stopping the pipeline. Index is the position of the item in in.
+func TryMap[T any, R any](ctx context.Context, in <-chan T, transform func(T) (R, error)) <-chan Result[R] {
+	outCh := make(chan Result[R])
+	tracer := otel.Tracer("pipeline")
+
+	go func() {
+		defer close(outCh)
+		_, span := tracer.Start(ctx, "try_map")
+		defer span.End()
+
+		idx := 0
+		for item := range in {
+			val, err := transform(item)
+			if err != nil {
+				span.RecordError(err)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case outCh <- Result[R]{Index: idx, Value: val, Err: err}:
+			}
+			idx++
+		}
+	}()
+
+	return outCh
+}
+
+// MapResult transforms successful results and passes failed ones through untouched.
+func MapResult[T any, R any](ctx context.Context, in <-chan Result[T], transform func(T) (R, error)) <-chan Result[R] {
+	return Map(ctx, in, func(r Result[T]) Result[R] {
+		if r.Err != nil {
+			return Result[R]{Index: r.Index, Err: r.Err}
+		}
+		val, err := transform(r.Value)
+		return Result[R]{Index: r.Index, Value: val, Err: err}
+	})
+}
+
+// FilterResult drops successful results that fail the predicate; failed results always pass.
+func FilterResult[T any](ctx context.Context, in <-chan Result[T], predicate func(T) bool) <-chan Result[T] {
+	return Filter(ctx, in, func(r Result[T]) bool {
+		return r.Err != nil || predicate(r.Value)
+	})
+}
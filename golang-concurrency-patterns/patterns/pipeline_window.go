@@ -0,0 +1,252 @@
+package patterns
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel"
+)
+
+// WindowOption configures the time-based stages: Batch, TumblingWindow,
+// SlidingWindow and Throttle.
+type WindowOption func(*windowConfig)
+
+type windowConfig struct {
+	clock Clock
+}
+
+// WithWindowClock sets the clock the stage measures time with.
+func WithWindowClock(clock Clock) WindowOption {
+	return func(c *windowConfig) {
+		c.clock = clock
+	}
+}
+
+func newWindowConfig(opts []WindowOption) windowConfig {
+	cfg := windowConfig{clock: RealClock()}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return cfg
+}
+
+// Batch groups items into slices of up to size items. A partial batch is
+// emitted once maxWait has passed since its first item, and when in closes.
+func Batch[T any](ctx context.Context, in <-chan T, size int, maxWait time.Duration, opts ...WindowOption) <-chan []T {
+	cfg := newWindowConfig(opts)
+	outCh := make(chan []T)
+	tracer := otel.Tracer("pipeline")
+
+	go func() {
+		defer close(outCh)
+		_, span := tracer.Start(ctx, "batch")
+		defer span.End()
+
+		var batch []T
+		var timer Timer // runs while a batch is pending
+		stopTimer := func() {
+			if timer != nil {
+				timer.Stop()
+				timer = nil
+			}
+		}
+		defer stopTimer()
+		timeout := func() <-chan time.Time {
+			if timer == nil {
+				return nil
+			}
+			return timer.C()
+		}
+
+		flush := func() bool {
+			if len(batch) == 0 {
+				return true
+			}
+			stopTimer()
+			select {
+			case <-ctx.Done():
+				return false
+			case outCh <- batch:
+			}
+			batch = nil
+			return true
+		}
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case item, ok := <-in:
+				if !ok {
+					flush()
+					return
+				}
+				batch = append(batch, item)
+				if len(batch) == 1 {
+					timer = cfg.clock.NewTimer(maxWait)
+				}
+				if len(batch) >= size && !flush() {
+					return
+				}
+			case <-timeout():
+				if !flush() {
+					return
+				}
+			}
+		}
+	}()
+
+	return outCh
+}
+
+// TumblingWindow groups items into consecutive, non-overlapping windows of
+// the given length. Empty windows are skipped.
+func TumblingWindow[T any](ctx context.Context, in <-chan T, size time.Duration, opts ...WindowOption) <-chan []T {
+	cfg := newWindowConfig(opts)
+	outCh := make(chan []T)
+	tracer := otel.Tracer("pipeline")
+
+	go func() {
+		defer close(outCh)
+		_, span := tracer.Start(ctx, "tumbling_window")
+		defer span.End()
+
+		tick := cfg.clock.NewTimer(size)
+		defer func() { tick.Stop() }()
+
+		var window []T
+		emit := func() bool {
+			if len(window) == 0 {
+				return true
+			}
+			select {
+			case <-ctx.Done():
+				return false
+			case outCh <- window:
+			}
+			window = nil
+			return true
+		}
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case item, ok := <-in:
+				if !ok {
+					emit()
+					return
+				}
+				window = append(window, item)
+			case <-tick.C():
+				tick = cfg.clock.NewTimer(size)
+				if !emit() {
+					return
+				}
+			}
+		}
+	}()
+
+	return outCh
+}
+
+type timestamped[T any] struct {
+	at   time.Time
+	item T
+}
+
+// SlidingWindow emits, every slide, the items received during the last size.
+// Windows overlap when slide < size. Empty windows are skipped.
+func SlidingWindow[T any](ctx context.Context, in <-chan T, size, slide time.Duration, opts ...WindowOption) <-chan []T {
+	cfg := newWindowConfig(opts)
+	outCh := make(chan []T)
+	tracer := otel.Tracer("pipeline")
+
+	go func() {
+		defer close(outCh)
+		_, span := tracer.Start(ctx, "sliding_window")
+		defer span.End()
+
+		tick := cfg.clock.NewTimer(slide)
+		defer func() { tick.Stop() }()
+
+		var buf []timestamped[T]
+		emit := func(now time.Time) bool {
+			// Drop items that fell out of the window
+			cutoff := now.Add(-size)
+			i := 0
+			for i < len(buf) && !buf[i].at.After(cutoff) {
+				i++
+			}
+			buf = buf[i:]
+			if len(buf) == 0 {
+				return true
+			}
+
+			window := make([]T, len(buf))
+			for j, ts := range buf {
+				window[j] = ts.item
+			}
+			select {
+			case <-ctx.Done():
+				return false
+			case outCh <- window:
+				return true
+			}
+		}
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case item, ok := <-in:
+				if !ok {
+					emit(cfg.clock.Now())
+					return
+				}
+				buf = append(buf, timestamped[T]{at: cfg.clock.Now(), item: item})
+			case now := <-tick.C():
+				tick = cfg.clock.NewTimer(slide)
+				if !emit(now) {
+					return
+				}
+			}
+		}
+	}()
+
+	return outCh
+}
+
+// Throttle passes items through at most once per interval, delaying (not dropping) the rest.
+func Throttle[T any](ctx context.Context, in <-chan T, interval time.Duration, opts ...WindowOption) <-chan T {
+	cfg := newWindowConfig(opts)
+	outCh := make(chan T)
+	tracer := otel.Tracer("pipeline")
+
+	go func() {
+		defer close(outCh)
+		_, span := tracer.Start(ctx, "throttle")
+		defer span.End()
+
+		var next time.Time
+		for item := range in {
+			if wait := next.Sub(cfg.clock.Now()); wait > 0 {
+				timer := cfg.clock.NewTimer(wait)
+				select {
+				case <-ctx.Done():
+					timer.Stop()
+					return
+				case <-timer.C():
+				}
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case outCh <- item:
+			}
+			next = cfg.clock.Now().Add(interval)
+		}
+	}()
+
+	return outCh
+}
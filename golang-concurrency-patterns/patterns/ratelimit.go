@@ -2,57 +2,308 @@ package patterns
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
 	"time"
 
 	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
 )
 
+// ErrRateLimitExceeded is returned by WaitN when n tokens can never be
+// obtained in time (n not positive or above burst, no refill, or ctx
+// deadline too close).
+var ErrRateLimitExceeded = errors.New("rate limit exceeded")
+
+// RateLimiterOption configures a RateLimiter.
+type RateLimiterOption func(*RateLimiter)
+
+// WithClock sets the time source of a RateLimiter.
+func WithClock(c Clock) RateLimiterOption {
+	return func(rl *RateLimiter) {
+		rl.clock = c
+	}
+}
+
 // RateLimiter controls the frequency of events.
+// It is a token bucket refilled lazily on every call, so it needs no background goroutine.
 type RateLimiter struct {
-	tickInterval time.Duration
-	ticker       *time.Ticker
-	tokens       chan struct{}
+	mu     sync.Mutex
+	clock  Clock
+	rate   float64 // tokens per second
+	burst  int
+	tokens float64
+	last   time.Time // last time tokens was updated
+	// lastEvent is the latest time a reservation acts, used to undo cancelled reservations
+	lastEvent time.Time
 }
 
-func NewRateLimiter(rps int, burst int) *RateLimiter {
-	interval := time.Second / time.Duration(rps)
+// NewRateLimiter creates a limiter allowing rate events per second with bursts of up to burst events.
+// The bucket starts full.
+func NewRateLimiter(rate float64, burst int, opts ...RateLimiterOption) *RateLimiter {
 	rl := &RateLimiter{
-		tickInterval: interval,
-		ticker:       time.NewTicker(interval),
-		tokens:       make(chan struct{}, burst),
+		clock:  RealClock(),
+		rate:   rate,
+		burst:  burst,
+		tokens: float64(burst),
 	}
+	for _, opt := range opts {
+		opt(rl)
+	}
+	rl.last = rl.clock.Now()
+	return rl
+}
+
+// Allow reports whether an event may happen now.
+func (rl *RateLimiter) Allow() bool {
+	return rl.AllowN(1)
+}
 
-	// Fill burst
-	for i := 0; i < burst; i++ {
-		rl.tokens <- struct{}{}
+// AllowN reports whether n events may happen now and consumes the tokens if so.
+// It is always false for n <= 0 or n above burst.
+func (rl *RateLimiter) AllowN(n int) bool {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	now := rl.clock.Now()
+	tokens := rl.advance(now)
+	if n <= 0 || n > rl.burst || tokens < float64(n) {
+		metrics().rateLimiterRejected.Add(context.Background(), 1, patternAttr("ratelimiter"))
+		return false
 	}
+	rl.tokens = tokens - float64(n)
+	rl.last = now
+	return true
+}
 
-	go func() {
-		for range rl.ticker.C {
-			select {
-			case rl.tokens <- struct{}{}:
-			default:
-				// Bucket full
-			}
-		}
-	}()
+// Reserve is shorthand for ReserveN(1).
+func (rl *RateLimiter) Reserve() *Reservation {
+	return rl.ReserveN(1)
+}
 
-	return rl
+// ReserveN books n tokens and tells how long the caller must wait before acting.
+// The returned Reservation is not OK when n can never be satisfied,
+// including n <= 0, which would otherwise add tokens to the bucket.
+func (rl *RateLimiter) ReserveN(n int) *Reservation {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	return rl.reserve(rl.clock.Now(), n, math.MaxInt64)
 }
 
+// Wait blocks until one event may happen.
 func (rl *RateLimiter) Wait(ctx context.Context) error {
+	return rl.WaitN(ctx, 1)
+}
+
+// WaitN blocks until n events may happen or ctx is done.
+// If ctx has a deadline that is too close, it fails immediately without consuming
+// tokens. The deadline is wall-clock time, so it is only compared with the wait
+// when the limiter runs on the real clock.
+func (rl *RateLimiter) WaitN(ctx context.Context, n int) error {
 	tracer := otel.Tracer("ratelimiter")
 	_, span := tracer.Start(ctx, "ratelimiter_wait")
+	span.SetAttributes(attribute.Int("tokens", n))
 	defer span.End()
 
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	maxWait := time.Duration(math.MaxInt64)
+	if deadline, ok := ctx.Deadline(); ok {
+		if _, isReal := rl.clock.(realClock); isReal {
+			maxWait = time.Until(deadline)
+		}
+	}
+	rl.mu.Lock()
+	now := rl.clock.Now()
+	r := rl.reserve(now, n, maxWait)
+	rl.mu.Unlock()
+
+	m := metrics()
+	attrs := patternAttr("ratelimiter")
+	if !r.ok {
+		m.rateLimiterRejected.Add(ctx, 1, attrs)
+		err := fmt.Errorf("%w: cannot take %d tokens in time (burst %d)", ErrRateLimitExceeded, n, r.burst)
+		span.RecordError(err)
+		return err
+	}
+
+	delay := r.DelayFrom(now)
+	if delay <= 0 {
+		m.rateLimiterWait.Record(ctx, 0, attrs)
+		return nil
+	}
+
+	t := rl.clock.NewTimer(delay)
+	defer t.Stop()
 	select {
 	case <-ctx.Done():
+		r.Cancel()
+		m.rateLimiterRejected.Add(ctx, 1, attrs)
 		return ctx.Err()
-	case <-rl.tokens:
+	case <-t.C():
+		m.rateLimiterWait.Record(ctx, delay.Seconds(), attrs)
 		return nil
 	}
 }
 
-func (rl *RateLimiter) Stop() {
-	rl.ticker.Stop()
+// SetRate changes the refill rate. Tokens accumulated so far are kept.
+func (rl *RateLimiter) SetRate(rate float64) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	now := rl.clock.Now()
+	rl.tokens = rl.advance(now)
+	rl.last = now
+	rl.rate = rate
+}
+
+// SetBurst changes the bucket capacity, dropping tokens above it.
+func (rl *RateLimiter) SetBurst(burst int) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	now := rl.clock.Now()
+	rl.tokens = math.Min(rl.advance(now), float64(burst))
+	rl.last = now
+	rl.burst = burst
+}
+
+// Rate returns the current refill rate in tokens per second.
+func (rl *RateLimiter) Rate() float64 {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	return rl.rate
+}
+
+// Burst returns the current bucket capacity.
+func (rl *RateLimiter) Burst() int {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	return rl.burst
+}
+
+// Tokens returns the number of tokens available now (negative while reservations are pending).
+func (rl *RateLimiter) Tokens() float64 {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	return rl.advance(rl.clock.Now())
+}
+
+// Stop is kept for compatibility; the limiter holds no resources anymore.
+func (rl *RateLimiter) Stop() {}
+
+// reserve must be called with mu held.
+func (rl *RateLimiter) reserve(now time.Time, n int, maxWait time.Duration) *Reservation {
+	if n <= 0 || n > rl.burst {
+		return &Reservation{ok: false, burst: rl.burst}
+	}
+
+	tokens := rl.advance(now) - float64(n)
+	var wait time.Duration
+	if tokens < 0 {
+		if rl.rate <= 0 {
+			return &Reservation{ok: false, burst: rl.burst}
+		}
+		wait = durationFromTokens(-tokens, rl.rate)
+	}
+	if wait > maxWait {
+		return &Reservation{ok: false, burst: rl.burst}
+	}
+
+	r := &Reservation{
+		ok:        true,
+		rl:        rl,
+		tokens:    n,
+		burst:     rl.burst,
+		timeToAct: now.Add(wait),
+	}
+	rl.tokens = tokens
+	rl.last = now
+	rl.lastEvent = r.timeToAct
+	return r
+}
+
+// advance returns the token count at now without mutating state.
+func (rl *RateLimiter) advance(now time.Time) float64 {
+	elapsed := now.Sub(rl.last)
+	if elapsed <= 0 || rl.rate <= 0 {
+		return rl.tokens
+	}
+	return math.Min(rl.tokens+elapsed.Seconds()*rl.rate, float64(rl.burst))
+}
+
+func durationFromTokens(tokens, rate float64) time.Duration {
+	return time.Duration(math.Ceil(tokens / rate * float64(time.Second)))
+}
+
+// Reservation holds tokens booked by ReserveN.
+type Reservation struct {
+	ok        bool
+	rl        *RateLimiter
+	tokens    int
+	burst     int
+	timeToAct time.Time
+	cancelled bool
+}
+
+// OK reports whether the reservation could be made.
+func (r *Reservation) OK() bool {
+	return r.ok
+}
+
+// Delay returns how long to wait before acting on the reservation.
+func (r *Reservation) Delay() time.Duration {
+	if !r.ok {
+		return time.Duration(math.MaxInt64)
+	}
+	return r.DelayFrom(r.rl.clock.Now())
+}
+
+// DelayFrom returns the wait relative to now.
+func (r *Reservation) DelayFrom(now time.Time) time.Duration {
+	if !r.ok {
+		return time.Duration(math.MaxInt64)
+	}
+	if d := r.timeToAct.Sub(now); d > 0 {
+		return d
+	}
+	return 0
+}
+
+// Cancel gives the tokens back as far as possible. Tokens that later
+// reservations already depend on are not restored. It is a no-op once
+// the reservation's time has passed.
+func (r *Reservation) Cancel() {
+	if !r.ok {
+		return
+	}
+	rl := r.rl
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	now := rl.clock.Now()
+	if r.cancelled || !r.timeToAct.After(now) {
+		return
+	}
+	r.cancelled = true
+
+	// Tokens reserved after us keep their place in line
+	restore := float64(r.tokens)
+	if rl.rate > 0 {
+		restore -= rl.lastEvent.Sub(r.timeToAct).Seconds() * rl.rate
+	}
+	if restore <= 0 {
+		return
+	}
+
+	tokens := rl.advance(now) + restore
+	rl.tokens = math.Min(tokens, float64(rl.burst))
+	rl.last = now
+	if r.timeToAct.Equal(rl.lastEvent) {
+		rl.lastEvent = now
+	}
 }
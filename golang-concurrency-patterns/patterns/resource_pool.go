@@ -0,0 +1,520 @@
+package patterns
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// ErrPoolClosed is returned when borrowing from a closed Pool.
+var ErrPoolClosed = errors.New("pool is closed")
+
+// PoolOption configures a Pool.
+type PoolOption[T any] func(*poolConfig[T])
+
+type poolConfig[T any] struct {
+	name           string
+	minSize        int
+	maxSize        int
+	idleTimeout    time.Duration
+	maxLifetime    time.Duration
+	healthInterval time.Duration
+	validate       func(context.Context, T) error
+	destroy        func(T)
+	clock          Clock
+}
+
+// WithPoolName names the pool in traces and metrics.
+func WithPoolName[T any](name string) PoolOption[T] {
+	return func(c *poolConfig[T]) {
+		c.name = name
+	}
+}
+
+// WithMinSize keeps at least n resources open. NewPool opens them before it
+// returns; resources lost later are replaced by the health check. A factory
+// error during warm-up is not fatal: the pool starts smaller and the health
+// check retries.
+func WithMinSize[T any](n int) PoolOption[T] {
+	return func(c *poolConfig[T]) {
+		c.minSize = n
+	}
+}
+
+// WithMaxSize caps the number of open resources. The default is 8.
+func WithMaxSize[T any](n int) PoolOption[T] {
+	return func(c *poolConfig[T]) {
+		if n > 0 {
+			c.maxSize = n
+		}
+	}
+}
+
+// WithIdleTimeout closes resources that have been idle for d, down to the minimum size.
+func WithIdleTimeout[T any](d time.Duration) PoolOption[T] {
+	return func(c *poolConfig[T]) {
+		c.idleTimeout = d
+	}
+}
+
+// WithMaxLifetime closes resources once they are older than d, even if they are healthy.
+func WithMaxLifetime[T any](d time.Duration) PoolOption[T] {
+	return func(c *poolConfig[T]) {
+		c.maxLifetime = d
+	}
+}
+
+// WithValidate checks a resource before it is lent out; one that fails is
+// closed and another is tried. It is also used by the background health check.
+func WithValidate[T any](fn func(context.Context, T) error) PoolOption[T] {
+	return func(c *poolConfig[T]) {
+		c.validate = fn
+	}
+}
+
+// WithDestroy releases the underlying resource when the pool closes it.
+func WithDestroy[T any](fn func(T)) PoolOption[T] {
+	return func(c *poolConfig[T]) {
+		c.destroy = fn
+	}
+}
+
+// WithHealthCheckInterval sets how often idle resources are validated and
+// expired ones evicted. The default is 30 seconds; 0 disables the background check.
+func WithHealthCheckInterval[T any](d time.Duration) PoolOption[T] {
+	return func(c *poolConfig[T]) {
+		c.healthInterval = d
+	}
+}
+
+// WithPoolClock sets the time source used for idle timeout and max lifetime.
+func WithPoolClock[T any](clock Clock) PoolOption[T] {
+	return func(c *poolConfig[T]) {
+		c.clock = clock
+	}
+}
+
+// PoolStats is a snapshot of a Pool.
+type PoolStats struct {
+	Open      int // idle + in use
+	Idle      int
+	InUse     int
+	Waiting   int // borrowers blocked in Acquire
+	Created   int64
+	Destroyed int64
+}
+
+type poolItem[T any] struct {
+	value    T
+	created  time.Time
+	lastUsed time.Time
+}
+
+// Pool lends out expensive resources (connections, clients, ...) created by a factory.
+type Pool[T any] struct {
+	factory func(context.Context) (T, error)
+	cfg     poolConfig[T]
+
+	mu        sync.Mutex
+	idle      []*poolItem[T] // most recently used last
+	open      int
+	inUse     int
+	waiting   int
+	created   int64
+	destroyed int64
+	closed    bool
+	changed   chan struct{} // closed and replaced whenever a resource or a slot frees up
+	drained   chan struct{} // closed once a closed pool has no open resources
+
+	stop         chan struct{}
+	maintainDone chan struct{}
+	registration metric.Registration
+}
+
+func NewPool[T any](factory func(context.Context) (T, error), opts ...PoolOption[T]) *Pool[T] {
+	cfg := poolConfig[T]{
+		name:           "pool",
+		maxSize:        8,
+		healthInterval: 30 * time.Second,
+		clock:          RealClock(),
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if cfg.minSize > cfg.maxSize {
+		cfg.minSize = cfg.maxSize
+	}
+
+	p := &Pool[T]{
+		factory:      factory,
+		cfg:          cfg,
+		changed:      make(chan struct{}),
+		drained:      make(chan struct{}),
+		stop:         make(chan struct{}),
+		maintainDone: make(chan struct{}),
+	}
+	p.registerMetrics()
+	p.fill(context.Background())
+	go p.maintain()
+	return p
+}
+
+// Pooled is a borrowed resource. It must be given back with Release or Destroy.
+type Pooled[T any] struct {
+	p    *Pool[T]
+	item *poolItem[T]
+	once sync.Once
+}
+
+// Value returns the resource.
+func (r *Pooled[T]) Value() T {
+	return r.item.value
+}
+
+// Release gives the resource back to the pool.
+func (r *Pooled[T]) Release() {
+	r.once.Do(func() { r.p.put(r.item, false) })
+}
+
+// Destroy closes the resource instead of reusing it, e.g. after an I/O error.
+func (r *Pooled[T]) Destroy() {
+	r.once.Do(func() { r.p.put(r.item, true) })
+}
+
+// Acquire borrows a resource: an idle one if there is one that is still valid,
+// a new one if the pool is below its maximum size, otherwise it waits until one
+// is given back or ctx ends.
+func (p *Pool[T]) Acquire(ctx context.Context) (*Pooled[T], error) {
+	tracer := otel.Tracer("pool")
+	ctx, span := tracer.Start(ctx, "pool_acquire")
+	span.SetAttributes(attribute.String("pool.name", p.cfg.name))
+	defer span.End()
+
+	start := time.Now()
+	defer func() {
+		metrics().resourceBorrowWait.Record(ctx, sinceSeconds(start),
+			metric.WithAttributes(attribute.String("pool.name", p.cfg.name)))
+	}()
+
+	for {
+		p.mu.Lock()
+		if p.closed {
+			p.mu.Unlock()
+			span.RecordError(ErrPoolClosed)
+			return nil, ErrPoolClosed
+		}
+
+		now := p.cfg.clock.Now()
+		var item *poolItem[T]
+		var expired []*poolItem[T]
+		for item == nil && len(p.idle) > 0 {
+			last := p.idle[len(p.idle)-1]
+			p.idle = p.idle[:len(p.idle)-1]
+			if p.expired(last, now) {
+				p.open--
+				expired = append(expired, last)
+				continue
+			}
+			item = last
+		}
+		if item != nil {
+			p.inUse++
+			p.mu.Unlock()
+			p.destroyAll(expired)
+
+			if p.cfg.validate != nil {
+				if err := p.cfg.validate(ctx, item.value); err != nil {
+					span.AddEvent("validation_failed")
+					p.put(item, true)
+					continue
+				}
+			}
+			return &Pooled[T]{p: p, item: item}, nil
+		}
+
+		if p.open < p.cfg.maxSize {
+			p.open++
+			p.inUse++
+			p.mu.Unlock()
+			p.destroyAll(expired)
+
+			item, err := p.create(ctx)
+			if err != nil {
+				p.mu.Lock()
+				p.open--
+				p.inUse--
+				p.notifyLocked()
+				p.mu.Unlock()
+				span.RecordError(err)
+				return nil, err
+			}
+			return &Pooled[T]{p: p, item: item}, nil
+		}
+
+		changed := p.changed
+		p.waiting++
+		p.mu.Unlock()
+		p.destroyAll(expired)
+		span.AddEvent("waiting")
+
+		select {
+		case <-ctx.Done():
+			p.mu.Lock()
+			p.waiting--
+			p.mu.Unlock()
+			span.RecordError(ctx.Err())
+			return nil, ctx.Err()
+		case <-changed:
+			p.mu.Lock()
+			p.waiting--
+			p.mu.Unlock()
+		}
+	}
+}
+
+// Stats returns a snapshot of the pool.
+func (p *Pool[T]) Stats() PoolStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return PoolStats{
+		Open:      p.open,
+		Idle:      len(p.idle),
+		InUse:     p.inUse,
+		Waiting:   p.waiting,
+		Created:   p.created,
+		Destroyed: p.destroyed,
+	}
+}
+
+// Close stops lending, closes idle resources and waits until every borrowed
+// resource has been given back (and closed) or ctx ends.
+func (p *Pool[T]) Close(ctx context.Context) error {
+	p.mu.Lock()
+	if !p.closed {
+		p.closed = true
+		close(p.stop)
+		idle := p.idle
+		p.idle = nil
+		p.open -= len(idle)
+		p.notifyLocked()
+		p.checkDrainedLocked()
+		p.mu.Unlock()
+
+		p.destroyAll(idle)
+		<-p.maintainDone
+		if p.registration != nil {
+			_ = p.registration.Unregister()
+		}
+	} else {
+		p.mu.Unlock()
+	}
+
+	select {
+	case <-p.drained:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (p *Pool[T]) create(ctx context.Context) (*poolItem[T], error) {
+	v, err := p.factory(ctx)
+	if err != nil {
+		return nil, err
+	}
+	now := p.cfg.clock.Now()
+	p.mu.Lock()
+	p.created++
+	p.mu.Unlock()
+	return &poolItem[T]{value: v, created: now, lastUsed: now}, nil
+}
+
+// put takes back a borrowed item.
+func (p *Pool[T]) put(item *poolItem[T], broken bool) {
+	p.mu.Lock()
+	p.inUse--
+	now := p.cfg.clock.Now()
+	if broken || p.closed || (p.cfg.maxLifetime > 0 && now.Sub(item.created) >= p.cfg.maxLifetime) {
+		p.open--
+		p.notifyLocked()
+		p.checkDrainedLocked()
+		p.mu.Unlock()
+		p.destroyAll([]*poolItem[T]{item})
+		return
+	}
+	item.lastUsed = now
+	p.idle = append(p.idle, item)
+	p.notifyLocked()
+	p.mu.Unlock()
+}
+
+// expired reports whether an idle item must be closed. Must be called with mu held.
+func (p *Pool[T]) expired(item *poolItem[T], now time.Time) bool {
+	if p.cfg.maxLifetime > 0 && now.Sub(item.created) >= p.cfg.maxLifetime {
+		return true
+	}
+	return p.cfg.idleTimeout > 0 && now.Sub(item.lastUsed) >= p.cfg.idleTimeout && p.open > p.cfg.minSize
+}
+
+func (p *Pool[T]) destroyAll(items []*poolItem[T]) {
+	if len(items) == 0 {
+		return
+	}
+	if p.cfg.destroy != nil {
+		for _, item := range items {
+			p.cfg.destroy(item.value)
+		}
+	}
+	p.mu.Lock()
+	p.destroyed += int64(len(items))
+	p.mu.Unlock()
+}
+
+// notifyLocked wakes every waiting borrower. Must be called with mu held.
+func (p *Pool[T]) notifyLocked() {
+	close(p.changed)
+	p.changed = make(chan struct{})
+}
+
+// checkDrainedLocked closes drained once a closed pool is empty. Must be called with mu held.
+func (p *Pool[T]) checkDrainedLocked() {
+	if p.closed && p.open == 0 {
+		select {
+		case <-p.drained:
+		default:
+			close(p.drained)
+		}
+	}
+}
+
+// fill opens resources until the pool has its minimum size.
+func (p *Pool[T]) fill(ctx context.Context) {
+	for {
+		p.mu.Lock()
+		if p.closed || p.open >= p.cfg.minSize {
+			p.mu.Unlock()
+			return
+		}
+		p.open++
+		p.mu.Unlock()
+
+		item, err := p.create(ctx)
+		p.mu.Lock()
+		if err != nil || p.closed {
+			p.open--
+			p.checkDrainedLocked()
+			p.mu.Unlock()
+			if err == nil {
+				p.destroyAll([]*poolItem[T]{item})
+			}
+			// Retry on the next health check
+			return
+		}
+		p.idle = append(p.idle, item)
+		p.notifyLocked()
+		p.mu.Unlock()
+	}
+}
+
+// maintain periodically runs the health check until the pool is closed.
+func (p *Pool[T]) maintain() {
+	defer close(p.maintainDone)
+	if p.cfg.healthInterval <= 0 {
+		<-p.stop
+		return
+	}
+	for {
+		timer := p.cfg.clock.NewTimer(p.cfg.healthInterval)
+		select {
+		case <-p.stop:
+			timer.Stop()
+			return
+		case <-timer.C():
+		}
+		p.healthCheck(context.Background())
+	}
+}
+
+// healthCheck evicts expired idle resources, validates the remaining ones and
+// tops the pool up to its minimum size.
+func (p *Pool[T]) healthCheck(ctx context.Context) {
+	tracer := otel.Tracer("pool")
+	ctx, span := tracer.Start(ctx, "pool_health_check")
+	span.SetAttributes(attribute.String("pool.name", p.cfg.name))
+	defer span.End()
+
+	p.mu.Lock()
+	now := p.cfg.clock.Now()
+	var expired, check []*poolItem[T]
+	for _, item := range p.idle {
+		if p.expired(item, now) {
+			p.open--
+			expired = append(expired, item)
+		} else {
+			check = append(check, item)
+		}
+	}
+	p.idle = nil
+	// Items under check count as borrowed so Close waits for them
+	p.inUse += len(check)
+	p.mu.Unlock()
+	p.destroyAll(expired)
+	span.SetAttributes(attribute.Int("pool.evicted", len(expired)))
+
+	for _, item := range check {
+		healthy := p.cfg.validate == nil || p.cfg.validate(ctx, item.value) == nil
+		if !healthy {
+			span.AddEvent("validation_failed")
+		}
+		p.mu.Lock()
+		if healthy {
+			// Keep the idle timestamp; put would refresh it
+			p.inUse--
+			if p.closed {
+				p.open--
+				p.checkDrainedLocked()
+				p.mu.Unlock()
+				p.destroyAll([]*poolItem[T]{item})
+				continue
+			}
+			p.idle = append(p.idle, item)
+			p.notifyLocked()
+			p.mu.Unlock()
+			continue
+		}
+		p.mu.Unlock()
+		p.put(item, true)
+	}
+
+	p.fill(ctx)
+}
+
+func (p *Pool[T]) registerMetrics() {
+	m := metrics().meter
+	attrs := metric.WithAttributes(attribute.String("pool.name", p.cfg.name))
+	open, _ := m.Int64ObservableGauge("patterns.resource_pool.open",
+		metric.WithDescription("Open resources, idle or in use"))
+	idle, _ := m.Int64ObservableGauge("patterns.resource_pool.idle",
+		metric.WithDescription("Idle resources"))
+	inUse, _ := m.Int64ObservableGauge("patterns.resource_pool.in_use",
+		metric.WithDescription("Borrowed resources"))
+	waiting, _ := m.Int64ObservableGauge("patterns.resource_pool.waiting",
+		metric.WithDescription("Borrowers waiting for a resource"))
+
+	reg, err := m.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		s := p.Stats()
+		o.ObserveInt64(open, int64(s.Open), attrs)
+		o.ObserveInt64(idle, int64(s.Idle), attrs)
+		o.ObserveInt64(inUse, int64(s.InUse), attrs)
+		o.ObserveInt64(waiting, int64(s.Waiting), attrs)
+		return nil
+	}, open, idle, inUse, waiting)
+	if err == nil {
+		p.registration = reg
+	}
+}
@@ -0,0 +1,540 @@
+package patterns
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// ErrSchedulerStopped is returned when scheduling on a stopped Scheduler.
+var ErrSchedulerStopped = errors.New("scheduler is stopped")
+
+// MisfirePolicy decides what happens to a run that starts later than
+// planned by more than the misfire threshold, e.g. because all workers were busy.
+type MisfirePolicy int
+
+const (
+	// MisfireRunOnce runs the late task once and skips the other missed runs.
+	MisfireRunOnce MisfirePolicy = iota
+	// MisfireSkip drops the late run and waits for the next planned one.
+	MisfireSkip
+	// MisfireRunAll runs every missed run, one after the other, to catch up.
+	MisfireRunAll
+)
+
+func (p MisfirePolicy) String() string {
+	switch p {
+	case MisfireRunOnce:
+		return "run_once"
+	case MisfireSkip:
+		return "skip"
+	case MisfireRunAll:
+		return "run_all"
+	default:
+		return "unknown"
+	}
+}
+
+// SchedulerOption configures a Scheduler.
+type SchedulerOption func(*Scheduler)
+
+// WithSchedulerWorkers sets how many tasks may run at the same time. The default is 4.
+func WithSchedulerWorkers(n int) SchedulerOption {
+	return func(s *Scheduler) {
+		if n > 0 {
+			s.workers = n
+		}
+	}
+}
+
+// WithSchedulerClock sets the time source of the timer queue.
+func WithSchedulerClock(clock Clock) SchedulerOption {
+	return func(s *Scheduler) {
+		s.clock = clock
+	}
+}
+
+// WithMisfirePolicy sets the default misfire policy of tasks.
+func WithMisfirePolicy(p MisfirePolicy) SchedulerOption {
+	return func(s *Scheduler) {
+		s.misfire = p
+	}
+}
+
+// WithMisfireThreshold sets how late a run may start before it counts as misfired. The default is one second.
+func WithMisfireThreshold(d time.Duration) SchedulerOption {
+	return func(s *Scheduler) {
+		s.threshold = d
+	}
+}
+
+// WithSchedulerErrorHandler is called with the name and error of every failed run.
+func WithSchedulerErrorHandler(fn func(name string, err error)) SchedulerOption {
+	return func(s *Scheduler) {
+		s.onError = fn
+	}
+}
+
+// TaskOption configures a scheduled task.
+type TaskOption func(*ScheduledTask)
+
+// WithPriority sets the task priority. When more tasks are due than workers
+// are free, higher priorities run first.
+func WithPriority(p int) TaskOption {
+	return func(t *ScheduledTask) {
+		t.priority = p
+	}
+}
+
+// WithTaskName names the task in traces and error reports.
+func WithTaskName(name string) TaskOption {
+	return func(t *ScheduledTask) {
+		t.name = name
+	}
+}
+
+// WithTaskMisfire overrides the scheduler's misfire policy for one task.
+func WithTaskMisfire(p MisfirePolicy) TaskOption {
+	return func(t *ScheduledTask) {
+		t.misfire = p
+	}
+}
+
+// schedule computes the run after one planned at prev that finished at finished.
+type schedule interface {
+	next(prev, finished time.Time) (time.Time, bool)
+}
+
+type onceSchedule struct{}
+
+func (onceSchedule) next(_, _ time.Time) (time.Time, bool) { return time.Time{}, false }
+
+type fixedRateSchedule struct{ period time.Duration }
+
+func (s fixedRateSchedule) next(prev, _ time.Time) (time.Time, bool) { return prev.Add(s.period), true }
+
+type fixedDelaySchedule struct{ delay time.Duration }
+
+func (s fixedDelaySchedule) next(_, finished time.Time) (time.Time, bool) {
+	return finished.Add(s.delay), true
+}
+
+type cronSchedule struct{ cron *CronSchedule }
+
+func (s cronSchedule) next(prev, _ time.Time) (time.Time, bool) {
+	t := s.cron.Next(prev)
+	return t, !t.IsZero()
+}
+
+// ScheduledTask is the handle of a task added to a Scheduler.
+type ScheduledTask struct {
+	s        *Scheduler
+	name     string
+	priority int
+	misfire  MisfirePolicy
+	fn       func(context.Context) error
+	sched    schedule
+
+	// Guarded by s.mu
+	due       time.Time
+	seq       uint64
+	index     int
+	queue     *taskQueue
+	running   bool
+	cancelled bool
+	finished  bool
+	runs      int
+	done      chan struct{}
+}
+
+// Cancel removes the task from the scheduler. A run in progress is not
+// interrupted but will not be followed by another. It reports whether the
+// task was still scheduled.
+func (t *ScheduledTask) Cancel() bool {
+	s := t.s
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if t.finished || t.cancelled {
+		return false
+	}
+	t.cancelled = true
+	if t.queue != nil {
+		heap.Remove(t.queue, t.index)
+	}
+	if !t.running {
+		s.finish(t)
+	}
+	s.wakeUp()
+	return true
+}
+
+// Done is closed once the task will not run anymore.
+func (t *ScheduledTask) Done() <-chan struct{} {
+	return t.done
+}
+
+// Runs returns how many times the task has run.
+func (t *ScheduledTask) Runs() int {
+	t.s.mu.Lock()
+	defer t.s.mu.Unlock()
+	return t.runs
+}
+
+// NextRun returns when the task is planned to run next.
+func (t *ScheduledTask) NextRun() (time.Time, bool) {
+	t.s.mu.Lock()
+	defer t.s.mu.Unlock()
+	if t.finished || t.queue == nil {
+		return time.Time{}, false
+	}
+	return t.due, true
+}
+
+// taskQueue is a heap of tasks with a configurable order.
+type taskQueue struct {
+	items []*ScheduledTask
+	less  func(a, b *ScheduledTask) bool
+}
+
+func (q *taskQueue) Len() int           { return len(q.items) }
+func (q *taskQueue) Less(i, j int) bool { return q.less(q.items[i], q.items[j]) }
+func (q *taskQueue) Swap(i, j int) {
+	q.items[i], q.items[j] = q.items[j], q.items[i]
+	q.items[i].index = i
+	q.items[j].index = j
+}
+
+func (q *taskQueue) Push(x any) {
+	t := x.(*ScheduledTask)
+	t.index = len(q.items)
+	t.queue = q
+	q.items = append(q.items, t)
+}
+
+func (q *taskQueue) Pop() any {
+	n := len(q.items)
+	t := q.items[n-1]
+	q.items[n-1] = nil
+	q.items = q.items[:n-1]
+	t.index = -1
+	t.queue = nil
+	return t
+}
+
+func (q *taskQueue) peek() *ScheduledTask {
+	return q.items[0]
+}
+
+// byDue orders the timer queue: earliest first, then higher priority, then FIFO.
+func byDue(a, b *ScheduledTask) bool {
+	if !a.due.Equal(b.due) {
+		return a.due.Before(b.due)
+	}
+	if a.priority != b.priority {
+		return a.priority > b.priority
+	}
+	return a.seq < b.seq
+}
+
+// byPriority orders due tasks waiting for a worker: higher priority first, then earliest.
+func byPriority(a, b *ScheduledTask) bool {
+	if a.priority != b.priority {
+		return a.priority > b.priority
+	}
+	if !a.due.Equal(b.due) {
+		return a.due.Before(b.due)
+	}
+	return a.seq < b.seq
+}
+
+// Scheduler runs delayed, repeating and cron tasks. Waiting tasks live in a
+// heap ordered by due time and a single goroutine sleeps until the earliest one,
+// so idle tasks cost no goroutines. Due tasks run on a bounded set of workers.
+type Scheduler struct {
+	clock     Clock
+	workers   int
+	misfire   MisfirePolicy
+	threshold time.Duration
+	onError   func(name string, err error)
+
+	ctx     context.Context
+	cancel  context.CancelFunc
+	wake    chan struct{}
+	stopped chan struct{}
+	wg      sync.WaitGroup
+
+	mu      sync.Mutex
+	timers  *taskQueue // waiting for their due time
+	ready   *taskQueue // due, waiting for a worker
+	active  int
+	seq     uint64
+	closing bool
+}
+
+func NewScheduler(opts ...SchedulerOption) *Scheduler {
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &Scheduler{
+		clock:     RealClock(),
+		workers:   4,
+		threshold: time.Second,
+		ctx:       ctx,
+		cancel:    cancel,
+		wake:      make(chan struct{}, 1),
+		stopped:   make(chan struct{}),
+		timers:    &taskQueue{less: byDue},
+		ready:     &taskQueue{less: byPriority},
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	go s.loop()
+	return s
+}
+
+// After runs fn once after delay.
+func (s *Scheduler) After(delay time.Duration, fn func(context.Context) error, opts ...TaskOption) (*ScheduledTask, error) {
+	return s.add(s.clock.Now().Add(delay), onceSchedule{}, fn, opts)
+}
+
+// At runs fn once at t.
+func (s *Scheduler) At(t time.Time, fn func(context.Context) error, opts ...TaskOption) (*ScheduledTask, error) {
+	return s.add(t, onceSchedule{}, fn, opts)
+}
+
+// FixedRate runs fn every period, measured between planned start times,
+// with the first run after initialDelay. Runs of one task never overlap.
+func (s *Scheduler) FixedRate(initialDelay, period time.Duration, fn func(context.Context) error, opts ...TaskOption) (*ScheduledTask, error) {
+	if period <= 0 {
+		return nil, errors.New("scheduler: period must be positive")
+	}
+	return s.add(s.clock.Now().Add(initialDelay), fixedRateSchedule{period: period}, fn, opts)
+}
+
+// FixedDelay runs fn repeatedly with delay between the end of one run and
+// the start of the next, with the first run after initialDelay.
+func (s *Scheduler) FixedDelay(initialDelay, delay time.Duration, fn func(context.Context) error, opts ...TaskOption) (*ScheduledTask, error) {
+	if delay <= 0 {
+		return nil, errors.New("scheduler: delay must be positive")
+	}
+	return s.add(s.clock.Now().Add(initialDelay), fixedDelaySchedule{delay: delay}, fn, opts)
+}
+
+// Cron runs fn at every activation of the cron expression (see ParseCron).
+func (s *Scheduler) Cron(expr string, fn func(context.Context) error, opts ...TaskOption) (*ScheduledTask, error) {
+	cron, err := ParseCron(expr)
+	if err != nil {
+		return nil, err
+	}
+	first := cron.Next(s.clock.Now())
+	if first.IsZero() {
+		return nil, errors.New("scheduler: cron expression never fires")
+	}
+	return s.add(first, cronSchedule{cron: cron}, fn, opts)
+}
+
+func (s *Scheduler) add(due time.Time, sched schedule, fn func(context.Context) error, opts []TaskOption) (*ScheduledTask, error) {
+	t := &ScheduledTask{
+		s:       s,
+		misfire: s.misfire,
+		fn:      fn,
+		sched:   sched,
+		due:     due,
+		index:   -1,
+		done:    make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(t)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closing {
+		return nil, ErrSchedulerStopped
+	}
+	s.push(t)
+	s.wakeUp()
+	return t, nil
+}
+
+// Pending returns the number of tasks waiting to run.
+func (s *Scheduler) Pending() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.timers.Len() + s.ready.Len()
+}
+
+// Stop stops the scheduler, cancels the context of running tasks and waits
+// for them to return. Tasks that have not run are dropped.
+func (s *Scheduler) Stop() {
+	s.mu.Lock()
+	if s.closing {
+		s.mu.Unlock()
+		<-s.stopped
+		return
+	}
+	s.closing = true
+	s.mu.Unlock()
+
+	s.cancel()
+	<-s.stopped
+	s.wg.Wait()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, q := range []*taskQueue{s.timers, s.ready} {
+		for q.Len() > 0 {
+			s.finish(heap.Pop(q).(*ScheduledTask))
+		}
+	}
+}
+
+func (s *Scheduler) loop() {
+	defer close(s.stopped)
+
+	for {
+		s.mu.Lock()
+		now := s.clock.Now()
+		for s.timers.Len() > 0 && !s.timers.peek().due.After(now) {
+			heap.Push(s.ready, heap.Pop(s.timers))
+		}
+		for s.ready.Len() > 0 && s.active < s.workers {
+			t := heap.Pop(s.ready).(*ScheduledTask)
+			if t.misfire == MisfireSkip && now.Sub(t.due) > s.threshold {
+				s.skip(t, now)
+				continue
+			}
+			t.running = true
+			s.active++
+			s.wg.Add(1)
+			go s.execute(t)
+		}
+
+		var timer Timer
+		var fire <-chan time.Time
+		if s.timers.Len() > 0 {
+			timer = s.clock.NewTimer(s.timers.peek().due.Sub(now))
+			fire = timer.C()
+		}
+		s.mu.Unlock()
+
+		select {
+		case <-s.ctx.Done():
+			if timer != nil {
+				timer.Stop()
+			}
+			return
+		case <-s.wake:
+		case <-fire:
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+	}
+}
+
+func (s *Scheduler) execute(t *ScheduledTask) {
+	defer s.wg.Done()
+
+	tracer := otel.Tracer("scheduler")
+	ctx, span := tracer.Start(s.ctx, "scheduler_task")
+	span.SetAttributes(
+		attribute.String("task.name", t.name),
+		attribute.Int("task.priority", t.priority),
+	)
+
+	var err error
+	observeTask(ctx, "scheduler", func() {
+		defer func() {
+			if r := recover(); r != nil {
+				err = &PanicError{Value: r, Stack: debug.Stack()}
+			}
+		}()
+		err = t.fn(ctx)
+	})
+	if err != nil {
+		span.RecordError(err)
+		if s.onError != nil {
+			s.onError(t.name, err)
+		}
+	}
+	span.End()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.active--
+	t.running = false
+	t.runs++
+	if t.cancelled || s.closing {
+		s.finish(t)
+		s.wakeUp()
+		return
+	}
+
+	now := s.clock.Now()
+	next, ok := t.sched.next(t.due, now)
+	if t.misfire != MisfireRunAll {
+		next, ok = s.catchUp(t, next, ok, now)
+	}
+	if !ok {
+		s.finish(t)
+	} else {
+		t.due = next
+		s.push(t)
+	}
+	s.wakeUp()
+}
+
+// skip drops a misfired run and plans the next one that is not late.
+// Must be called with mu held.
+func (s *Scheduler) skip(t *ScheduledTask, now time.Time) {
+	next, ok := t.sched.next(t.due, now)
+	next, ok = s.catchUp(t, next, ok, now)
+	if !ok {
+		s.finish(t)
+		return
+	}
+	t.due = next
+	s.push(t)
+}
+
+// catchUp jumps over the planned runs that have already passed when next is
+// misfired, so the task resumes at its first future run.
+func (s *Scheduler) catchUp(t *ScheduledTask, next time.Time, ok bool, now time.Time) (time.Time, bool) {
+	if !ok || now.Sub(next) <= s.threshold {
+		return next, ok
+	}
+	for ok && next.Before(now) {
+		next, ok = t.sched.next(next, now)
+	}
+	return next, ok
+}
+
+// push adds t to the timer queue. Must be called with mu held.
+func (s *Scheduler) push(t *ScheduledTask) {
+	s.seq++
+	t.seq = s.seq
+	heap.Push(s.timers, t)
+}
+
+// finish marks t as done. Must be called with mu held.
+func (s *Scheduler) finish(t *ScheduledTask) {
+	if t.finished {
+		return
+	}
+	t.finished = true
+	close(t.done)
+}
+
+func (s *Scheduler) wakeUp() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
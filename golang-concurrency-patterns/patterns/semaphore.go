@@ -0,0 +1,126 @@
+package patterns
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"sync"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+type semaphoreWaiter struct {
+	n     int64
+	ready chan struct{} // closed when the weight has been granted
+}
+
+// WeightedSemaphore bounds access to a resource by total weight rather than
+// by count, for work with non-uniform cost (e.g. memory or bytes in flight).
+// Waiters are served in FIFO order so large requests are not starved by small ones.
+type WeightedSemaphore struct {
+	size    int64
+	mu      sync.Mutex
+	cur     int64
+	waiters list.List
+}
+
+func NewWeightedSemaphore(size int64) *WeightedSemaphore {
+	return &WeightedSemaphore{size: size}
+}
+
+// Acquire blocks until n units are available or ctx ends.
+// A request larger than the semaphore fails right away.
+func (s *WeightedSemaphore) Acquire(ctx context.Context, n int64) error {
+	tracer := otel.Tracer("semaphore")
+	_, span := tracer.Start(ctx, "semaphore_acquire")
+	span.SetAttributes(attribute.Int64("semaphore.weight", n))
+	defer span.End()
+
+	s.mu.Lock()
+	if n > s.size {
+		s.mu.Unlock()
+		err := fmt.Errorf("semaphore: weight %d exceeds size %d", n, s.size)
+		span.RecordError(err)
+		return err
+	}
+	if s.size-s.cur >= n && s.waiters.Len() == 0 {
+		s.cur += n
+		s.mu.Unlock()
+		return nil
+	}
+
+	w := &semaphoreWaiter{n: n, ready: make(chan struct{})}
+	el := s.waiters.PushBack(w)
+	s.mu.Unlock()
+	span.AddEvent("waiting")
+
+	select {
+	case <-w.ready:
+		return nil
+	case <-ctx.Done():
+		s.mu.Lock()
+		select {
+		case <-w.ready:
+			// Granted at the same time; give it back
+			s.cur -= n
+			s.notifyLocked()
+		default:
+			isFront := s.waiters.Front() == el
+			s.waiters.Remove(el)
+			// The head leaving may let smaller waiters behind it proceed
+			if isFront {
+				s.notifyLocked()
+			}
+		}
+		s.mu.Unlock()
+		span.RecordError(ctx.Err())
+		return ctx.Err()
+	}
+}
+
+// TryAcquire takes n units if they are available right now.
+func (s *WeightedSemaphore) TryAcquire(n int64) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.size-s.cur >= n && s.waiters.Len() == 0 {
+		s.cur += n
+		return true
+	}
+	return false
+}
+
+// Release gives back n units.
+func (s *WeightedSemaphore) Release(n int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cur -= n
+	if s.cur < 0 {
+		panic("semaphore: released more than held")
+	}
+	s.notifyLocked()
+}
+
+// Available returns the number of free units.
+func (s *WeightedSemaphore) Available() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.size - s.cur
+}
+
+// notifyLocked grants waiters in order while there is room. Must be called with mu held.
+func (s *WeightedSemaphore) notifyLocked() {
+	for {
+		front := s.waiters.Front()
+		if front == nil {
+			return
+		}
+		w := front.Value.(*semaphoreWaiter)
+		if s.size-s.cur < w.n {
+			return
+		}
+		s.cur += w.n
+		s.waiters.Remove(front)
+		close(w.ready)
+	}
+}
@@ -0,0 +1,101 @@
+package patterns
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"sync"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// flightCall is one in-flight call of a SingleFlight.
+type flightCall[V any] struct {
+	done chan struct{}
+	val  V
+	err  error
+	dups int
+}
+
+// SingleFlight collapses concurrent calls for the same key into one:
+// the first caller runs the function, the others wait for its result.
+type SingleFlight[K comparable, V any] struct {
+	mu    sync.Mutex
+	calls map[K]*flightCall[V]
+}
+
+func NewSingleFlight[K comparable, V any]() *SingleFlight[K, V] {
+	return &SingleFlight[K, V]{calls: make(map[K]*flightCall[V])}
+}
+
+// Do runs fn for key unless a call for key is already in flight, in which case
+// it waits for that call. shared reports whether the result went to several callers.
+//
+// fn runs detached from the caller's cancellation, so one caller giving up does
+// not fail the others; each caller stops waiting when its own ctx ends.
+// A panic in fn is returned to every caller as a *PanicError.
+func (s *SingleFlight[K, V]) Do(ctx context.Context, key K, fn func(context.Context) (V, error)) (v V, shared bool, err error) {
+	tracer := otel.Tracer("singleflight")
+	ctx, span := tracer.Start(ctx, "singleflight_do")
+	span.SetAttributes(attribute.String("singleflight.key", fmt.Sprint(key)))
+	defer span.End()
+
+	s.mu.Lock()
+	c, ok := s.calls[key]
+	if ok {
+		c.dups++
+	} else {
+		c = &flightCall[V]{done: make(chan struct{})}
+		s.calls[key] = c
+		go s.run(context.WithoutCancel(ctx), key, c, fn)
+	}
+	s.mu.Unlock()
+	span.SetAttributes(attribute.Bool("singleflight.joined", ok))
+
+	select {
+	case <-ctx.Done():
+		span.RecordError(ctx.Err())
+		var zero V
+		return zero, ok, ctx.Err()
+	case <-c.done:
+	}
+
+	s.mu.Lock()
+	shared = c.dups > 0
+	s.mu.Unlock()
+	if c.err != nil {
+		span.RecordError(c.err)
+	}
+	return c.val, shared, c.err
+}
+
+func (s *SingleFlight[K, V]) run(ctx context.Context, key K, c *flightCall[V], fn func(context.Context) (V, error)) {
+	defer func() {
+		if r := recover(); r != nil {
+			c.err = &PanicError{Value: r, Stack: debug.Stack()}
+		}
+		s.mu.Lock()
+		if s.calls[key] == c {
+			delete(s.calls, key)
+		}
+		s.mu.Unlock()
+		close(c.done)
+	}()
+	c.val, c.err = fn(ctx)
+}
+
+// Forget makes the next call for key start a new flight instead of joining
+// the one in progress.
+func (s *SingleFlight[K, V]) Forget(key K) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.calls, key)
+}
+
+// InFlight returns the number of keys with a call in progress.
+func (s *SingleFlight[K, V]) InFlight() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.calls)
+}
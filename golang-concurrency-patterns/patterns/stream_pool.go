@@ -0,0 +1,279 @@
+package patterns
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const defaultReorderWindow = 1024
+
+// StreamPoolOption configures a StreamPool.
+type StreamPoolOption func(*streamPoolConfig)
+
+type streamPoolConfig struct {
+	preserveOrder bool
+	reorderWindow int
+	taskTimeout   time.Duration
+	clock         Clock
+}
+
+func newStreamPoolConfig(opts []StreamPoolOption) streamPoolConfig {
+	cfg := streamPoolConfig{reorderWindow: defaultReorderWindow, clock: RealClock()}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return cfg
+}
+
+// run calls workerFunc, bounded by the task timeout if one is set.
+func (c streamPoolConfig) run(ctx context.Context, workerFunc func(context.Context) error) error {
+	if c.taskTimeout <= 0 {
+		return workerFunc(ctx)
+	}
+	ctx, cancel := withClockTimeout(ctx, c.clock, c.taskTimeout)
+	defer cancel()
+	return workerFunc(ctx)
+}
+
+// PreserveOrder makes the pool emit results in input order.
+// Results that finish early are buffered until their predecessors are done.
+func PreserveOrder() StreamPoolOption {
+	return func(c *streamPoolConfig) {
+		c.preserveOrder = true
+	}
+}
+
+// WithReorderWindow bounds how many items may be in flight or buffered
+// while waiting for an earlier result in PreserveOrder mode.
+func WithReorderWindow(n int) StreamPoolOption {
+	return func(c *streamPoolConfig) {
+		if n > 0 {
+			c.reorderWindow = n
+		}
+	}
+}
+
+// WithTaskTimeout cancels each task's context after d. The task sees
+// context.DeadlineExceeded and should return.
+func WithTaskTimeout(d time.Duration) StreamPoolOption {
+	return func(c *streamPoolConfig) {
+		c.taskTimeout = d
+	}
+}
+
+// WithStreamClock sets the clock behind WithTaskTimeout.
+func WithStreamClock(clock Clock) StreamPoolOption {
+	return func(c *streamPoolConfig) {
+		c.clock = clock
+	}
+}
+
+// StreamPool is a WorkerPool that consumes an input channel, so it composes
+// with Generator, Map and Filter. Its concurrency can be changed while running.
+type StreamPool[T any, R any] struct {
+	workerFunc Task[T, R]
+	cfg        streamPoolConfig
+
+	mu      sync.Mutex
+	limit   int
+	active  int
+	changed chan struct{} // wakes the dispatcher when a slot may be free
+}
+
+func NewStreamPool[T any, R any](workerFunc Task[T, R], concurrency int, opts ...StreamPoolOption) *StreamPool[T, R] {
+	cfg := newStreamPoolConfig(opts)
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	return &StreamPool[T, R]{
+		workerFunc: workerFunc,
+		cfg:        cfg,
+		limit:      concurrency,
+		changed:    make(chan struct{}, 1),
+	}
+}
+
+// Resize changes the number of concurrently running tasks.
+// Shrinking does not interrupt running tasks; new ones start once the pool is below the limit.
+func (p *StreamPool[T, R]) Resize(concurrency int) {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	p.mu.Lock()
+	p.limit = concurrency
+	p.mu.Unlock()
+	p.wake()
+}
+
+// Concurrency returns the current concurrency limit.
+func (p *StreamPool[T, R]) Concurrency() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.limit
+}
+
+// Run processes every item of in until it is closed or ctx is cancelled.
+// The returned channel is closed once all started tasks have finished.
+func (p *StreamPool[T, R]) Run(ctx context.Context, in <-chan T) <-chan Result[R] {
+	out := make(chan Result[R])
+	tracer := otel.Tracer("worker-pool")
+
+	// In ordered mode workers report to the resequencer instead of out,
+	// and window bounds the number of unfinished-or-buffered items.
+	sink := out
+	var window chan struct{}
+	if p.cfg.preserveOrder {
+		sink = make(chan Result[R], p.cfg.reorderWindow)
+		window = make(chan struct{}, p.cfg.reorderWindow)
+		go p.resequence(ctx, sink, out, window)
+	}
+
+	go func() {
+		defer close(sink)
+
+		ctx, span := tracer.Start(ctx, "stream_pool_manager")
+		defer span.End()
+
+		var wg sync.WaitGroup
+		defer wg.Wait()
+
+		m := metrics()
+		attrs := patternAttr("stream_pool")
+
+		idx := 0
+		for {
+			var input T
+			var ok bool
+			select {
+			case <-ctx.Done():
+				return
+			case input, ok = <-in:
+			}
+			if !ok {
+				return
+			}
+
+			// The item is queued until it gets a worker
+			m.poolQueueDepth.Add(ctx, 1, attrs)
+			if err := p.admit(ctx, window); err != nil {
+				m.poolQueueDepth.Add(ctx, -1, attrs)
+				return
+			}
+			m.poolQueueDepth.Add(ctx, -1, attrs)
+
+			wg.Add(1)
+			go func(idx int, input T) {
+				defer wg.Done()
+				defer p.release()
+
+				_, wSpan := tracer.Start(ctx, fmt.Sprintf("worker_%d", idx))
+				wSpan.SetAttributes(attribute.Int("input.index", idx))
+				defer wSpan.End()
+
+				var val R
+				var err error
+				observeTask(ctx, "stream_pool", func() {
+					err = p.cfg.run(ctx, func(ctx context.Context) (err error) {
+						val, err = p.workerFunc(ctx, input)
+						return err
+					})
+				})
+				select {
+				case <-ctx.Done():
+				case sink <- Result[R]{Index: idx, Value: val, Err: err}:
+				}
+			}(idx, input)
+			idx++
+		}
+	}()
+
+	return out
+}
+
+// resequence emits results from in to out ordered by Index.
+func (p *StreamPool[T, R]) resequence(ctx context.Context, in <-chan Result[R], out chan<- Result[R], window <-chan struct{}) {
+	defer close(out)
+
+	pending := make(map[int]Result[R])
+	next := 0
+	for res := range in {
+		pending[res.Index] = res
+		for {
+			r, ok := pending[next]
+			if !ok {
+				break
+			}
+			select {
+			case <-ctx.Done():
+				// Drop buffered results but close out only after the workers finished
+				for range in {
+				}
+				return
+			case out <- r:
+			}
+			delete(pending, next)
+			<-window
+			next++
+		}
+	}
+}
+
+// admit waits for a reorder window slot (ordered mode) and a worker slot.
+func (p *StreamPool[T, R]) admit(ctx context.Context, window chan struct{}) error {
+	if window != nil {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case window <- struct{}{}:
+		}
+	}
+	return p.acquire(ctx)
+}
+
+func (p *StreamPool[T, R]) acquire(ctx context.Context) error {
+	for {
+		p.mu.Lock()
+		if p.active < p.limit {
+			p.active++
+			p.mu.Unlock()
+			return nil
+		}
+		p.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-p.changed:
+		}
+	}
+}
+
+func (p *StreamPool[T, R]) release() {
+	p.mu.Lock()
+	p.active--
+	p.mu.Unlock()
+	p.wake()
+}
+
+func (p *StreamPool[T, R]) wake() {
+	select {
+	case p.changed <- struct{}{}:
+	default:
+	}
+}
+
+// WorkerPoolStream is a shorthand for NewStreamPool(...).Run(ctx, in).
+func WorkerPoolStream[T any, R any](
+	ctx context.Context,
+	in <-chan T,
+	workerFunc Task[T, R],
+	concurrency int,
+	opts ...StreamPoolOption,
+) <-chan Result[R] {
+	return NewStreamPool(workerFunc, concurrency, opts...).Run(ctx, in)
+}
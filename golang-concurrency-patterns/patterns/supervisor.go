@@ -0,0 +1,390 @@
+package patterns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var (
+	// ErrTooManyRestarts is returned by Supervisor.Run when children restarted
+	// more often than the restart intensity allows.
+	ErrTooManyRestarts = errors.New("supervisor: too many restarts")
+	// ErrSupervisorRunning is returned when adding a child to a running Supervisor.
+	ErrSupervisorRunning = errors.New("supervisor is already running")
+)
+
+// RestartStrategy decides which children are restarted when one of them fails.
+type RestartStrategy int
+
+const (
+	// OneForOne restarts only the child that exited.
+	OneForOne RestartStrategy = iota
+	// OneForAll restarts every child when one exits.
+	OneForAll
+	// RestForOne restarts the child that exited and every child started after it.
+	RestForOne
+)
+
+func (s RestartStrategy) String() string {
+	switch s {
+	case OneForOne:
+		return "one_for_one"
+	case OneForAll:
+		return "one_for_all"
+	case RestForOne:
+		return "rest_for_one"
+	default:
+		return "unknown"
+	}
+}
+
+// RestartPolicy decides whether a child is restarted after it exits.
+type RestartPolicy int
+
+const (
+	// Permanent children are always restarted.
+	Permanent RestartPolicy = iota
+	// Transient children are restarted only when they fail (error or panic).
+	Transient
+	// Temporary children are never restarted.
+	Temporary
+)
+
+// ChildSpec describes a service run by a Supervisor.
+type ChildSpec struct {
+	Name    string
+	Run     func(ctx context.Context) error
+	Restart RestartPolicy
+	// ShutdownTimeout is how long the child may take to return after its
+	// context is cancelled. The default is 5 seconds.
+	ShutdownTimeout time.Duration
+}
+
+// SupervisorOption configures a Supervisor.
+type SupervisorOption func(*Supervisor)
+
+// WithStrategy sets the restart strategy. The default is OneForOne.
+func WithStrategy(s RestartStrategy) SupervisorOption {
+	return func(sv *Supervisor) {
+		sv.strategy = s
+	}
+}
+
+// WithRestartIntensity makes Run give up with ErrTooManyRestarts when there
+// are more than maxRestarts restarts within window. The default is 3 in 5 seconds.
+func WithRestartIntensity(maxRestarts int, window time.Duration) SupervisorOption {
+	return func(sv *Supervisor) {
+		sv.maxRestarts = maxRestarts
+		sv.window = window
+	}
+}
+
+// WithRestartBackoff delays restarts of a failing child exponentially, from
+// initial up to max. A child that ran for longer than max starts over at initial.
+func WithRestartBackoff(initial, max time.Duration) SupervisorOption {
+	return func(sv *Supervisor) {
+		sv.backoffInitial = initial
+		sv.backoffMax = max
+	}
+}
+
+// WithSupervisorClock sets the time source for backoff and restart intensity.
+func WithSupervisorClock(clock Clock) SupervisorOption {
+	return func(sv *Supervisor) {
+		sv.clock = clock
+	}
+}
+
+// childRun is one execution of a child.
+type childRun struct {
+	cancel  context.CancelFunc
+	done    chan struct{} // closed when the child returned
+	abandon chan struct{} // closed when the supervisor stops the child itself
+	started time.Time
+	err     error
+}
+
+type supervisedChild struct {
+	spec     ChildSpec
+	run      *childRun // nil when not running
+	failures int       // consecutive quick failures, for backoff
+}
+
+// Supervisor runs child services and restarts them when they fail or panic,
+// in the spirit of Erlang/OTP supervisors. A Supervisor can itself be the
+// child of another one (see AsChild), so exceeding the restart intensity
+// escalates the failure to the parent.
+type Supervisor struct {
+	name           string
+	strategy       RestartStrategy
+	maxRestarts    int
+	window         time.Duration
+	backoffInitial time.Duration
+	backoffMax     time.Duration
+	clock          Clock
+
+	mu       sync.Mutex
+	children []*supervisedChild
+	running  bool
+	restarts []time.Time
+	exits    chan *childRun
+}
+
+func NewSupervisor(name string, opts ...SupervisorOption) *Supervisor {
+	sv := &Supervisor{
+		name:           name,
+		maxRestarts:    3,
+		window:         5 * time.Second,
+		backoffInitial: 100 * time.Millisecond,
+		backoffMax:     10 * time.Second,
+		clock:          RealClock(),
+		exits:          make(chan *childRun),
+	}
+	for _, opt := range opts {
+		opt(sv)
+	}
+	return sv
+}
+
+// Add registers a child. Children start in the order they were added and
+// stop in reverse order.
+func (sv *Supervisor) Add(spec ChildSpec) error {
+	sv.mu.Lock()
+	defer sv.mu.Unlock()
+	if sv.running {
+		return ErrSupervisorRunning
+	}
+	if spec.ShutdownTimeout <= 0 {
+		spec.ShutdownTimeout = 5 * time.Second
+	}
+	sv.children = append(sv.children, &supervisedChild{spec: spec})
+	return nil
+}
+
+// AsChild returns a spec that runs this supervisor under another one.
+func (sv *Supervisor) AsChild() ChildSpec {
+	return ChildSpec{Name: sv.name, Run: sv.Run, Restart: Transient}
+}
+
+// Run starts the children and supervises them until ctx is cancelled, then
+// stops them in reverse start order and returns nil. It returns an error
+// wrapping ErrTooManyRestarts if the restart intensity is exceeded.
+func (sv *Supervisor) Run(ctx context.Context) error {
+	sv.mu.Lock()
+	if sv.running {
+		sv.mu.Unlock()
+		return ErrSupervisorRunning
+	}
+	sv.running = true
+	sv.restarts = nil
+	for _, c := range sv.children {
+		c.failures = 0
+	}
+	sv.mu.Unlock()
+	defer func() {
+		sv.mu.Lock()
+		sv.running = false
+		sv.mu.Unlock()
+	}()
+
+	tracer := otel.Tracer("supervisor")
+	ctx, span := tracer.Start(ctx, "supervisor")
+	span.SetAttributes(
+		attribute.String("supervisor.name", sv.name),
+		attribute.String("supervisor.strategy", sv.strategy.String()),
+	)
+	defer span.End()
+
+	// Children are cancelled one by one during shutdown, not all at once with ctx
+	childBase := context.WithoutCancel(ctx)
+	for i := range sv.children {
+		sv.start(childBase, i, 0)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			sv.stopFrom(0)
+			return nil
+		case run := <-sv.exits:
+			i := sv.indexOf(run)
+			if i < 0 {
+				continue
+			}
+			if err := sv.handleExit(childBase, span, i, run); err != nil {
+				span.RecordError(err)
+				sv.stopFrom(0)
+				return err
+			}
+		}
+	}
+}
+
+func (sv *Supervisor) indexOf(run *childRun) int {
+	for i, c := range sv.children {
+		if c.run == run {
+			return i
+		}
+	}
+	return -1
+}
+
+// handleExit applies the restart policy and strategy after child i exited.
+func (sv *Supervisor) handleExit(ctx context.Context, span trace.Span, i int, run *childRun) error {
+	c := sv.children[i]
+	c.run = nil
+
+	restart := c.spec.Restart == Permanent || (c.spec.Restart == Transient && run.err != nil)
+	if !restart {
+		return nil
+	}
+
+	now := sv.clock.Now()
+	if !sv.allowRestart(now) {
+		return fmt.Errorf("%w: %s: child %q: %v", ErrTooManyRestarts, sv.name, c.spec.Name, run.err)
+	}
+
+	// Quick failures back off exponentially; a child that ran long enough starts over
+	if now.Sub(run.started) > sv.backoffMax {
+		c.failures = 0
+	}
+	c.failures++
+	delay := sv.backoff(c.failures)
+
+	reason := "exit"
+	var pe *PanicError
+	switch {
+	case errors.As(run.err, &pe):
+		reason = fmt.Sprintf("panic: %v", pe.Value)
+	case run.err != nil:
+		reason = run.err.Error()
+	}
+	span.AddEvent("child_restart", trace.WithAttributes(
+		attribute.String("child.name", c.spec.Name),
+		attribute.String("child.reason", reason),
+		attribute.Int("child.attempt", c.failures),
+		attribute.Int64("child.delay_ms", delay.Milliseconds()),
+	))
+
+	switch sv.strategy {
+	case OneForAll:
+		sv.stopFrom(0)
+		for j := range sv.children {
+			if sv.children[j].spec.Restart != Temporary || j == i {
+				sv.start(ctx, j, delay)
+			}
+		}
+	case RestForOne:
+		sv.stopFrom(i + 1)
+		for j := i; j < len(sv.children); j++ {
+			if sv.children[j].spec.Restart != Temporary || j == i {
+				sv.start(ctx, j, delay)
+			}
+		}
+	default:
+		sv.start(ctx, i, delay)
+	}
+	return nil
+}
+
+// allowRestart records a restart and reports whether the intensity allows it.
+func (sv *Supervisor) allowRestart(now time.Time) bool {
+	kept := sv.restarts[:0]
+	for _, t := range sv.restarts {
+		if now.Sub(t) < sv.window {
+			kept = append(kept, t)
+		}
+	}
+	sv.restarts = append(kept, now)
+	return len(sv.restarts) <= sv.maxRestarts
+}
+
+func (sv *Supervisor) backoff(attempt int) time.Duration {
+	d := sv.backoffInitial
+	for n := 1; n < attempt && d < sv.backoffMax; n++ {
+		d *= 2
+	}
+	if d > sv.backoffMax {
+		d = sv.backoffMax
+	}
+	return d
+}
+
+// start runs child i after delay.
+func (sv *Supervisor) start(ctx context.Context, i int, delay time.Duration) {
+	c := sv.children[i]
+	childCtx, cancel := context.WithCancel(ctx)
+	run := &childRun{
+		cancel:  cancel,
+		done:    make(chan struct{}),
+		abandon: make(chan struct{}),
+	}
+	c.run = run
+
+	go func() {
+		if delay > 0 {
+			timer := sv.clock.NewTimer(delay)
+			select {
+			case <-childCtx.Done():
+				timer.Stop()
+				close(run.done)
+				return
+			case <-timer.C():
+			}
+		}
+		run.started = sv.clock.Now()
+		run.err = sv.runChild(childCtx, c.spec)
+		close(run.done)
+		select {
+		case sv.exits <- run:
+		case <-run.abandon:
+		}
+	}()
+}
+
+func (sv *Supervisor) runChild(ctx context.Context, spec ChildSpec) (err error) {
+	tracer := otel.Tracer("supervisor")
+	ctx, span := tracer.Start(ctx, "supervisor_child")
+	span.SetAttributes(attribute.String("child.name", spec.Name))
+	defer span.End()
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = &PanicError{Value: r, Stack: debug.Stack()}
+		}
+		if err != nil {
+			span.RecordError(err)
+		}
+	}()
+	return spec.Run(ctx)
+}
+
+// stopFrom stops children from index i on, last started first, giving each
+// its shutdown timeout before moving on.
+func (sv *Supervisor) stopFrom(i int) {
+	for j := len(sv.children) - 1; j >= i; j-- {
+		c := sv.children[j]
+		run := c.run
+		if run == nil {
+			continue
+		}
+		c.run = nil
+		close(run.abandon)
+		run.cancel()
+
+		timer := sv.clock.NewTimer(c.spec.ShutdownTimeout)
+		select {
+		case <-run.done:
+		case <-timer.C():
+		}
+		timer.Stop()
+	}
+}
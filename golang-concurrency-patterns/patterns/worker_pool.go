@@ -12,19 +12,24 @@ import (
 type Task[T any, R any] func(context.Context, T) (R, error)
 
 // Result holds the output of a task or an error.
+// Index is the position of the task's input in the input slice or stream.
 type Result[R any] struct {
+	Index int
 	Value R
 	Err   error
 }
 
 // WorkerPool implements a generic pool of workers processing inputs concurrently.
-// It uses a semaphore pattern to limit concurrency.
+// It uses a semaphore pattern to limit concurrency. Of the StreamPool options
+// only WithTaskTimeout and WithStreamClock apply; results come in completion order.
 func WorkerPool[T any, R any](
 	ctx context.Context,
 	tasks []T,
 	workerFunc Task[T, R],
 	concurrency int,
+	opts ...StreamPoolOption,
 ) <-chan Result[R] {
+	cfg := newStreamPoolConfig(opts)
 	results := make(chan Result[R], len(tasks))
 	var wg sync.WaitGroup
 
@@ -41,6 +46,13 @@ func WorkerPool[T any, R any](
 		ctx, span := tracer.Start(ctx, "worker_pool_manager")
 		defer span.End()
 
+		// Every task is queued until it gets a worker
+		m := metrics()
+		attrs := patternAttr("worker_pool")
+		m.poolQueueDepth.Add(ctx, int64(len(tasks)), attrs)
+		queued := len(tasks)
+		defer func() { m.poolQueueDepth.Add(ctx, -int64(queued), attrs) }()
+
 		for i, taskInput := range tasks {
 			// Check for cancellation early
 			select {
@@ -48,6 +60,8 @@ func WorkerPool[T any, R any](
 				return
 			case sem <- struct{}{}: // Acquire token
 			}
+			queued--
+			m.poolQueueDepth.Add(ctx, -1, attrs)
 
 			wg.Add(1)
 			go func(idx int, input T) {
@@ -58,8 +72,15 @@ func WorkerPool[T any, R any](
 				_, wSpan := tracer.Start(ctx, fmt.Sprintf("worker_%d", idx))
 				defer wSpan.End()
 
-				val, err := workerFunc(ctx, input)
-				results <- Result[R]{Value: val, Err: err}
+				var val R
+				var err error
+				observeTask(ctx, "worker_pool", func() {
+					err = cfg.run(ctx, func(ctx context.Context) (err error) {
+						val, err = workerFunc(ctx, input)
+						return err
+					})
+				})
+				results <- Result[R]{Index: idx, Value: val, Err: err}
 			}(i, taskInput)
 		}
 
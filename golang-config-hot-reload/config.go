@@ -0,0 +1,93 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// AppConfig maps the yaml structure. The validate tags are checked on every
+// load; a config that breaks them is never applied. Fields tagged
+// secret:"true" may be given encrypted and are redacted in any output.
+type AppConfig struct {
+	Server struct {
+		Port    int    `mapstructure:"port" json:"port" validate:"required,min=1,max=65535"`
+		Message string `mapstructure:"message" json:"message" validate:"required,max=256"`
+		// DrainTimeout bounds how long requests on the old port may take after a port change.
+		DrainTimeout time.Duration `mapstructure:"drain_timeout" json:"drain_timeout" validate:"min=0"`
+	} `mapstructure:"server" json:"server"`
+	Features struct {
+		Beta bool `mapstructure:"beta" json:"beta"`
+	} `mapstructure:"features" json:"features"`
+	Database struct {
+		User     string `mapstructure:"user" json:"user"`
+		Password string `mapstructure:"password" json:"password" secret:"true"`
+	} `mapstructure:"database" json:"database"`
+	Log struct {
+		Level string `mapstructure:"level" json:"level" validate:"oneof=debug info warn error"`
+	} `mapstructure:"log" json:"log"`
+}
+
+var validate = newValidator()
+
+// newValidator names fields by their config key, so errors read "server.port".
+func newValidator() *validator.Validate {
+	v := validator.New(validator.WithRequiredStructEnabled())
+	v.RegisterTagNameFunc(keyOf)
+	return v
+}
+
+// Validate checks the validate tags and reports every broken rule.
+func (c AppConfig) Validate() error {
+	err := validate.Struct(c)
+	var fieldErrs validator.ValidationErrors
+	if !errors.As(err, &fieldErrs) {
+		return err
+	}
+	msgs := make([]string, 0, len(fieldErrs))
+	for _, fe := range fieldErrs {
+		msgs = append(msgs, describe(fe))
+	}
+	return fmt.Errorf("invalid config: %s", strings.Join(msgs, "; "))
+}
+
+// describe turns a field error into "server.port must be <= 65535 (got 70000)".
+func describe(fe validator.FieldError) string {
+	path := strings.TrimPrefix(fe.Namespace(), "AppConfig.")
+	if secretKeys[path] {
+		return fmt.Sprintf("%s fails %s", path, fe.Tag())
+	}
+	switch fe.Tag() {
+	case "required":
+		return path + " is required"
+	case "min":
+		return fmt.Sprintf("%s must be >= %s (got %v)", path, fe.Param(), fe.Value())
+	case "max":
+		return fmt.Sprintf("%s must be <= %s (got %v)", path, fe.Param(), fe.Value())
+	case "oneof":
+		return fmt.Sprintf("%s must be one of [%s] (got %q)", path, fe.Param(), fe.Value())
+	default:
+		return fmt.Sprintf("%s fails %s=%s", path, fe.Tag(), fe.Param())
+	}
+}
+
+// parseLevel maps a validated log.level to a slog level.
+func parseLevel(level string) slog.Level {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(level)); err != nil {
+		return slog.LevelInfo
+	}
+	return l
+}
+
+// defaults are the values used when no other source sets a key.
+func defaults() defaultsSource {
+	return defaultsSource{
+		"log.level":            "info",
+		"server.drain_timeout": "10s",
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestAppConfig_Validate(t *testing.T) {
+	if err := testConfig(8080, "hi").Validate(); err != nil {
+		t.Fatalf("expected a valid config, got %v", err)
+	}
+
+	cfg := testConfig(70000, "")
+	cfg.Log.Level = "loud"
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("expected an invalid config")
+	}
+	for _, want := range []string{
+		"server.port must be <= 65535 (got 70000)",
+		"server.message is required",
+		`log.level must be one of [debug info warn error] (got "loud")`,
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected %q in %q", want, err)
+		}
+	}
+}
+
+func TestConfigManager_KeepsLastGoodConfig(t *testing.T) {
+	h, _ := NewHistory(10, "", &Keyring{})
+	cm := NewConfigManager(h)
+	if err := cm.Update("file", testConfig(8080, "good"), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	err := cm.Update("file", testConfig(0, "bad"), nil)
+	if err == nil {
+		t.Fatal("expected the invalid config to be rejected")
+	}
+	if got := cm.Get(); got.Server.Port != 8080 || got.Server.Message != "good" {
+		t.Errorf("expected the last good config to stay, got %+v", got.Server)
+	}
+	st := cm.Status()
+	if st.Applied || st.Error != err.Error() || st.Version != 1 || st.LastApplied.IsZero() {
+		t.Errorf("expected a rejected status on version 1, got %+v", st)
+	}
+
+	cm.Reject("file", errors.New("yaml: bad indentation"))
+	if st := cm.Status(); st.Error != "yaml: bad indentation" || st.Version != 1 {
+		t.Errorf("expected the parse error in the status, got %+v", st)
+	}
+}
+
+func TestAppConfig_JSONKeys(t *testing.T) {
+	data, _ := json.Marshal(testConfig(8080, "hi"))
+	if !strings.Contains(string(data), `"server":{"port":8080,"message":"hi"`) {
+		t.Errorf("expected the yaml key names, got %s", data)
+	}
+}
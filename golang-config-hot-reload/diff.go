@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Change is one key whose value differs between two configs.
+type Change struct {
+	Path string `json:"path"`
+	Old  any    `json:"old"`
+	New  any    `json:"new"`
+}
+
+func (c Change) String() string {
+	return fmt.Sprintf("%s: %v -> %v", c.Path, c.Old, c.New)
+}
+
+// Diff lists the leaf keys that differ between old and new, by path. Secret
+// values are redacted.
+func Diff(old, new AppConfig) []Change {
+	before, after := flatten(old), flatten(new)
+	var changes []Change
+	for path, o := range before {
+		n := after[path]
+		if reflect.DeepEqual(o, n) {
+			continue
+		}
+		if secretKeys[path] {
+			o, n = redactValue(o), redactValue(n)
+		}
+		changes = append(changes, Change{Path: path, Old: o, New: n})
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	return changes
+}
+
+func redactValue(v any) any {
+	if v == "" {
+		return v
+	}
+	return redacted
+}
+
+// flatten maps every leaf of cfg to its dotted yaml path.
+func flatten(cfg AppConfig) map[string]any {
+	leaves := make(map[string]any)
+	var walk func(prefix string, v reflect.Value)
+	walk = func(prefix string, v reflect.Value) {
+		if v.Kind() != reflect.Struct {
+			leaves[prefix] = v.Interface()
+			return
+		}
+		for i := 0; i < v.NumField(); i++ {
+			walk(joinPath(prefix, keyOf(v.Type().Field(i))), v.Field(i))
+		}
+	}
+	walk("", reflect.ValueOf(cfg))
+	return leaves
+}
+
+// lookup returns the value at a dotted path: a section struct or a leaf.
+func lookup(v reflect.Value, path string) (reflect.Value, bool) {
+	if path == "" {
+		return v, true
+	}
+	for _, key := range strings.Split(path, ".") {
+		if v.Kind() != reflect.Struct {
+			return reflect.Value{}, false
+		}
+		found := false
+		for i := 0; i < v.NumField(); i++ {
+			if keyOf(v.Type().Field(i)) == key {
+				v, found = v.Field(i), true
+				break
+			}
+		}
+		if !found {
+			return reflect.Value{}, false
+		}
+	}
+	return v, true
+}
+
+// keyOf is the config key of a field: its mapstructure tag, as in the yaml.
+func keyOf(f reflect.StructField) string {
+	if tag, _, _ := strings.Cut(f.Tag.Get("mapstructure"), ","); tag != "" {
+		return tag
+	}
+	return strings.ToLower(f.Name)
+}
+
+func joinPath(prefix, key string) string {
+	if prefix == "" {
+		return key
+	}
+	return prefix + "." + key
+}
+
+// touches reports whether a change at changed affects a subscription to path:
+// the key itself or a key inside the path's section.
+func touches(path, changed string) bool {
+	return path == "" || changed == path || strings.HasPrefix(changed, path+".")
+}
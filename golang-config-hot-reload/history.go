@@ -0,0 +1,243 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// ErrVersionNotFound is returned for a version that was never recorded or
+// has been dropped from the bounded history.
+var ErrVersionNotFound = errors.New("config version not found")
+
+// ErrSecretDropped is returned for a version read back from the history file
+// whose secrets were left out because there was no key to encrypt them.
+var ErrSecretDropped = errors.New("secret was not persisted")
+
+// Version is one applied config.
+type Version struct {
+	Number    int       `json:"version"`
+	AppliedAt time.Time `json:"applied_at"`
+	Hash      string    `json:"hash"`
+	Source    string    `json:"source"`
+	Config    AppConfig `json:"config"`
+	Origins   Origins   `json:"origins,omitempty"`
+}
+
+// History keeps the last applied configs, numbered from 1, and optionally
+// persists them to a JSON file so numbering and rollback survive restarts.
+// Secrets are written to the file encrypted with the keyring's primary key,
+// and re-encrypted when the primary changes. Without a key they are replaced
+// by a marker, and such versions can no longer be rolled back to after a
+// restart.
+type History struct {
+	max     int
+	path    string
+	keyring *Keyring
+
+	// hashKey keys the version hashes, so a published hash cannot be used
+	// to guess a secret. It is never served nor persisted.
+	hashKey []byte
+
+	mu       sync.Mutex
+	versions []Version // oldest first
+	next     int
+}
+
+// NewHistory keeps up to max versions. With a non-empty path the history is
+// loaded from it and rewritten after every change.
+func NewHistory(max int, path string, keyring *Keyring) (*History, error) {
+	if max < 1 {
+		max = 1
+	}
+	h := &History{max: max, path: path, keyring: keyring, next: 1, hashKey: make([]byte, 32)}
+	if _, err := rand.Read(h.hashKey); err != nil {
+		return nil, err
+	}
+	if path == "" {
+		return h, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return h, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read history: %w", err)
+	}
+	if err := json.Unmarshal(data, &h.versions); err != nil {
+		return nil, fmt.Errorf("decode history %s: %w", path, err)
+	}
+	if n := len(h.versions); n > 0 {
+		h.next = h.versions[n-1].Number + 1
+	}
+	// The hash key is new, so rehash the loaded versions for deduplication
+	for i, v := range h.versions {
+		if cfg, err := mapSecrets(v.Config, keyring.Decrypt); err == nil {
+			h.versions[i].Hash = h.hash(cfg)
+		}
+	}
+	h.trim()
+	return h, nil
+}
+
+// Record adds cfg as the newest version. A config identical to the newest
+// one, such as a file saved without changes, is not recorded again.
+func (h *History) Record(source string, cfg AppConfig, origins Origins) (Version, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	hash := h.hash(cfg)
+	if n := len(h.versions); n > 0 && h.versions[n-1].Hash == hash {
+		return h.versions[n-1], nil
+	}
+	v := Version{
+		Number:    h.next,
+		AppliedAt: time.Now(),
+		Hash:      hash,
+		Source:    source,
+		Config:    cfg,
+		Origins:   origins,
+	}
+	h.next++
+	h.versions = append(h.versions, v)
+	h.trim()
+	return v, h.save()
+}
+
+// List returns the kept versions, newest first, with secrets redacted.
+func (h *History) List() []Version {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	list := make([]Version, len(h.versions))
+	for i, v := range h.versions {
+		v.Config = Redact(v.Config)
+		list[len(list)-1-i] = v
+	}
+	return list
+}
+
+// Get returns version n with its secrets in plain text. It fails with
+// ErrSecretDropped if a secret of the version was not persisted.
+func (h *History) Get(n int) (Version, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, v := range h.versions {
+		if v.Number != n {
+			continue
+		}
+		// Versions read back from the file hold their secrets encrypted
+		cfg, err := mapSecrets(v.Config, func(value string) (string, error) {
+			if value == dropped {
+				return "", ErrSecretDropped
+			}
+			return h.keyring.Decrypt(value)
+		})
+		if err != nil {
+			return Version{}, fmt.Errorf("version %d: %w", n, err)
+		}
+		v.Config = cfg
+		return v, nil
+	}
+	return Version{}, fmt.Errorf("%w: %d", ErrVersionNotFound, n)
+}
+
+// Rekey re-encrypts the persisted secrets under the keyring's current primary
+// key and rewrites the file, so keys that are no longer primary can be dropped.
+// It is called whenever the keyring is reloaded.
+func (h *History) Rekey() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.save()
+}
+
+// rekey moves the secrets read back from the file to the primary key. A
+// version whose key is already gone is left as it is and cannot be rolled
+// back to. Must be called with mu held.
+func (h *History) rekey() {
+	for i, v := range h.versions {
+		cfg, err := mapSecrets(v.Config, h.keyring.Reseal)
+		if err != nil {
+			log.Printf("Cannot re-encrypt config version %d: %v", v.Number, err)
+			continue
+		}
+		h.versions[i].Config = cfg
+	}
+}
+
+func (h *History) trim() {
+	if extra := len(h.versions) - h.max; extra > 0 {
+		h.versions = append([]Version(nil), h.versions[extra:]...)
+	}
+}
+
+// save writes the history to a temporary file and renames it over the old
+// one, so a crash never leaves a half-written history.
+func (h *History) save() error {
+	if h.path == "" {
+		return nil
+	}
+	h.rekey()
+	sealed := make([]Version, len(h.versions))
+	for i, v := range h.versions {
+		cfg, err := mapSecrets(v.Config, h.seal)
+		if err != nil {
+			return fmt.Errorf("save history: %w", err)
+		}
+		v.Config = cfg
+		sealed[i] = v
+	}
+	data, err := json.MarshalIndent(sealed, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(h.path), ".history-*")
+	if err != nil {
+		return fmt.Errorf("save history: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("save history: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("save history: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), h.path); err != nil {
+		return fmt.Errorf("save history: %w", err)
+	}
+	return nil
+}
+
+// seal encrypts a secret for the file. Without a key it is marked as
+// dropped rather than written in plain text. Encrypted values have been
+// moved to the primary key by rekey already.
+func (h *History) seal(value string) (string, error) {
+	if value == "" || value == dropped || isEncrypted(value) {
+		return value, nil
+	}
+	sealed, err := h.keyring.Encrypt(value)
+	if errors.Is(err, ErrUnknownKey) {
+		return dropped, nil
+	}
+	return sealed, err
+}
+
+// hash is the HMAC-SHA-256 of the config's JSON form under the history's
+// hash key. It identifies a version within one run; after a restart the same
+// config hashes differently.
+func (h *History) hash(cfg AppConfig) string {
+	data, _ := json.Marshal(cfg)
+	mac := hmac.New(sha256.New, h.hashKey)
+	mac.Write(data)
+	return hex.EncodeToString(mac.Sum(nil))
+}
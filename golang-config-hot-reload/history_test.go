@@ -0,0 +1,81 @@
+package main
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func testConfig(port int, message string) AppConfig {
+	var cfg AppConfig
+	cfg.Server.Port = port
+	cfg.Server.Message = message
+	cfg.Log.Level = "info"
+	return cfg
+}
+
+func TestHistory_BoundedAndPersisted(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.json")
+	h, err := NewHistory(2, path, &Keyring{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, msg := range []string{"a", "b", "b", "c"} {
+		if _, err := h.Record("test", testConfig(8080+i, msg), nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	list := h.List()
+	if len(list) != 2 || list[0].Number != 4 || list[1].Number != 3 {
+		t.Fatalf("expected versions 4 and 3, got %+v", list)
+	}
+	if _, err := h.Get(1); !errors.Is(err, ErrVersionNotFound) {
+		t.Errorf("expected the oldest version to be dropped, got %v", err)
+	}
+
+	// A restart picks up the versions and goes on numbering
+	h, err = NewHistory(2, path, &Keyring{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, _ := h.Record("test", testConfig(9000, "d"), nil)
+	if v.Number != 5 {
+		t.Errorf("expected version 5 after reload, got %d", v.Number)
+	}
+	if _, err := h.Get(4); err != nil {
+		t.Errorf("expected version 4 to survive the restart: %v", err)
+	}
+
+	// Saving the same config again records nothing
+	if again, _ := h.Record("test", testConfig(9000, "d"), nil); again.Number != 5 {
+		t.Errorf("expected an unchanged config to keep version 5, got %d", again.Number)
+	}
+}
+
+func TestConfigManager_Rollback(t *testing.T) {
+	h, _ := NewHistory(10, "", &Keyring{})
+	cm := NewConfigManager(h)
+	cm.Update("file", testConfig(8080, "first"), nil)
+	cm.Update("file", testConfig(8080, "second"), nil)
+
+	var seen []string
+	Subscribe(cm, "server.message", func(_, new string) { seen = append(seen, new) })
+
+	if err := cm.Rollback(1); err != nil {
+		t.Fatal(err)
+	}
+	if got := cm.Get().Server.Message; got != "first" {
+		t.Errorf("expected version 1 back, got %q", got)
+	}
+	st := cm.Status()
+	if st.Version != 3 || st.Source != "rollback:1" {
+		t.Errorf("expected the rollback recorded as version 3, got %+v", st)
+	}
+	if len(seen) != 1 || seen[0] != "first" {
+		t.Errorf("expected subscribers to see the rollback, got %v", seen)
+	}
+	if err := cm.Rollback(42); !errors.Is(err, ErrVersionNotFound) {
+		t.Errorf("expected ErrVersionNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+
+	"github.com/spf13/viper"
+)
+
+// Origins maps each config key to the name of the source that supplied it.
+type Origins map[string]string
+
+// Layers merges sources in order of precedence: a key set by a later source
+// overrides the same key from an earlier one. Encrypted values are decrypted
+// with the keyring after merging.
+type Layers struct {
+	sources []Source
+	keyring *Keyring
+
+	mu     sync.Mutex
+	loaded []map[string]any // last good values of each source
+}
+
+func NewLayers(keyring *Keyring, sources ...Source) *Layers {
+	return &Layers{sources: sources, keyring: keyring, loaded: make([]map[string]any, len(sources))}
+}
+
+// knownKeys are the config keys of AppConfig; other keys are ignored.
+var knownKeys = flatten(AppConfig{})
+
+// Load loads every source and merges them.
+func (l *Layers) Load(ctx context.Context) (AppConfig, Origins, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for i := range l.sources {
+		if err := l.reload(ctx, i); err != nil {
+			return AppConfig{}, nil, err
+		}
+	}
+	return l.merge()
+}
+
+// Watch watches every source and calls apply with the merged config each time
+// one of them changes, or with the error if that source failed to load. Only
+// the changed source is reloaded; the others keep their last good values.
+// apply calls are serialized.
+func (l *Layers) Watch(ctx context.Context, apply func(source string, cfg AppConfig, origins Origins, err error)) {
+	for i, src := range l.sources {
+		go func() {
+			err := src.Watch(ctx, func() {
+				l.mu.Lock()
+				defer l.mu.Unlock()
+				if err := l.reload(ctx, i); err != nil {
+					apply(src.Name(), AppConfig{}, nil, err)
+					return
+				}
+				cfg, origins, err := l.merge()
+				apply(src.Name(), cfg, origins, err)
+			})
+			if err != nil {
+				log.Printf("Not watching %s: %v", src.Name(), err)
+			}
+		}()
+	}
+
+	// New keys may open values that failed to decrypt before
+	go func() {
+		err := l.keyring.Watch(ctx, func() {
+			l.mu.Lock()
+			defer l.mu.Unlock()
+			cfg, origins, err := l.merge()
+			apply("keyring", cfg, origins, err)
+		})
+		if err != nil {
+			log.Printf("Not watching the key file: %v", err)
+		}
+	}()
+}
+
+func (l *Layers) reload(ctx context.Context, i int) error {
+	values, err := l.sources[i].Load(ctx)
+	if err != nil {
+		return err
+	}
+	l.loaded[i] = values
+	return nil
+}
+
+// merge layers the loaded values and decodes them into an AppConfig.
+func (l *Layers) merge() (AppConfig, Origins, error) {
+	v := viper.New()
+	origins := make(Origins)
+	for i, values := range l.loaded {
+		keys := make([]string, 0, len(values))
+		for key := range values {
+			if _, ok := knownKeys[key]; ok {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			value := values[key]
+			if s, ok := value.(string); ok {
+				plain, err := l.keyring.Decrypt(s)
+				if err != nil {
+					return AppConfig{}, nil, fmt.Errorf("%s from %s: %w", key, l.sources[i].Name(), err)
+				}
+				value = plain
+			}
+			v.Set(key, value)
+			origins[key] = l.sources[i].Name()
+		}
+	}
+
+	var cfg AppConfig
+	if err := v.Unmarshal(&cfg); err != nil {
+		return AppConfig{}, nil, fmt.Errorf("decode config: %w", err)
+	}
+	return cfg, origins, nil
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// kvStub stands in for the remote key/value store. It serves doc with an
+// ETag and answers 304 when the client already has it.
+type kvStub struct {
+	mu          sync.Mutex
+	doc         string
+	version     int
+	notModified atomic.Int32
+}
+
+func (s *kvStub) set(doc string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.doc = doc
+	s.version++
+}
+
+func (s *kvStub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	doc, etag := s.doc, fmt.Sprintf(`"v%d"`, s.version)
+	s.mu.Unlock()
+	if r.Header.Get("If-None-Match") == etag {
+		s.notModified.Add(1)
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Content-Type", "application/json")
+	fmt.Fprint(w, doc)
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLayers_PrecedenceAndOrigins(t *testing.T) {
+	dir := t.TempDir()
+	base := filepath.Join(dir, "config.yaml")
+	writeFile(t, base, "server:\n  port: 8084\n  message: from file\nlog:\n  level: warn\n")
+	writeFile(t, filepath.Join(dir, "config.staging.yaml"), "server:\n  message: from staging\n")
+	t.Setenv("APP_FEATURES_BETA", "true")
+	t.Setenv("APP_LOG_LEVEL", "error")
+
+	stub := &kvStub{}
+	stub.set(`{"log": {"level": "debug"}, "unknown.key": 1}`)
+	remote := httptest.NewServer(stub)
+	defer remote.Close()
+
+	layers := NewLayers(&Keyring{}, configSources(base, "staging", remote.URL, time.Hour)...)
+	cfg, origins, err := layers.Load(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.Server.Port != 8084 || cfg.Server.Message != "from staging" || !cfg.Features.Beta ||
+		cfg.Log.Level != "debug" || cfg.Server.DrainTimeout != 10*time.Second {
+		t.Fatalf("unexpected merged config: %+v", cfg)
+	}
+	want := Origins{
+		"server.port":          "file:" + base,
+		"server.message":       "file:" + filepath.Join(dir, "config.staging.yaml"),
+		"server.drain_timeout": "defaults",
+		"features.beta":        "env",
+		"log.level":            "remote:" + remote.URL,
+	}
+	for key, src := range want {
+		if origins[key] != src {
+			t.Errorf("%s: expected source %q, got %q", key, src, origins[key])
+		}
+	}
+	if _, ok := origins["unknown.key"]; ok {
+		t.Error("expected unknown keys to be ignored")
+	}
+}
+
+func TestRemoteSource_PollsWithETag(t *testing.T) {
+	stub := &kvStub{}
+	stub.set(`{"server.message": "one"}`)
+	remote := httptest.NewServer(stub)
+	defer remote.Close()
+
+	src := newRemoteSource(remote.URL, 5*time.Millisecond)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if values, err := src.Load(ctx); err != nil || values["server.message"] != "one" {
+		t.Fatalf("got %v, %v", values, err)
+	}
+
+	changed := make(chan struct{}, 1)
+	go src.Watch(ctx, func() {
+		select {
+		case changed <- struct{}{}:
+		default:
+		}
+	})
+
+	// Unchanged polls are answered with 304 and do not report a change
+	deadline := time.Now().Add(time.Second)
+	for stub.notModified.Load() < 3 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	select {
+	case <-changed:
+		t.Fatal("expected no change while the document is the same")
+	default:
+	}
+
+	stub.set(`{"server": {"message": "two"}}`)
+	select {
+	case <-changed:
+	case <-time.After(time.Second):
+		t.Fatal("expected the new document to be noticed")
+	}
+	if values, _ := src.Load(ctx); values["server.message"] != "two" {
+		t.Errorf("expected the new value, got %v", values)
+	}
+}
+
+func TestLayers_WatchReloadsOnlyTheChangedSource(t *testing.T) {
+	dir := t.TempDir()
+	base := filepath.Join(dir, "config.yaml")
+	writeFile(t, base, "server:\n  port: 8084\n  message: first\n")
+
+	stub := &kvStub{}
+	stub.set(`{"features": {"beta": true}}`)
+	remote := httptest.NewServer(stub)
+	defer remote.Close()
+
+	layers := NewLayers(&Keyring{}, defaults(), fileSource{path: base}, newRemoteSource(remote.URL, time.Hour))
+	if _, _, err := layers.Load(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	type result struct {
+		source string
+		cfg    AppConfig
+		err    error
+	}
+	results := make(chan result, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	layers.Watch(ctx, func(source string, cfg AppConfig, _ Origins, err error) {
+		results <- result{source, cfg, err}
+	})
+	// Give fsnotify time to start watching
+	time.Sleep(50 * time.Millisecond)
+
+	writeFile(t, base, "server:\n  port: 8084\n  message: second\n")
+	var res result
+	for res.err != nil || res.cfg.Server.Message != "second" {
+		select {
+		case res = <-results:
+		case <-time.After(2 * time.Second):
+			t.Fatal("file change was not picked up")
+		}
+	}
+	if res.source != "file:"+base || !res.cfg.Features.Beta {
+		t.Errorf("expected the remote value to be kept, got %+v from %s", res.cfg, res.source)
+	}
+
+	// A half-written file is reported as an error
+	writeFile(t, base, "server:\n  port: [")
+	for res.err == nil {
+		select {
+		case res = <-results:
+		case <-time.After(2 * time.Second):
+			t.Fatal("broken file was not reported")
+		}
+	}
+}
@@ -1,93 +1,170 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
+	"log/slog"
 	"net/http"
-	"sync"
-
-	"github.com/fsnotify/fsnotify"
-	"github.com/spf13/viper"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 )
 
-// AppConfig maps the yaml structure
-type AppConfig struct {
-	Server struct {
-		Port    int    `mapstructure:"port"`
-		Message string `mapstructure:"message"`
-	} `mapstructure:"server"`
-	Features struct {
-		Beta bool `mapstructure:"beta"`
-	} `mapstructure:"features"`
-}
+func main() {
+	configFiles := flag.String("config", "config.yaml", "comma-separated YAML files, later ones win")
+	env := flag.String("env", os.Getenv("APP_ENV"), "environment; loads config.<env>.yaml on top of the files")
+	remoteURL := flag.String("remote", "", "HTTP key/value endpoint applied on top of everything else")
+	remoteInterval := flag.Duration("remote-interval", 10*time.Second, "how often the remote endpoint is polled")
+	historySize := flag.Int("history-size", 20, "number of applied config versions to keep")
+	historyFile := flag.String("history-file", "", "JSON file the version history is persisted to (optional)")
+	keyFile := flag.String("key-file", "", "file of id:base64 AES-256 keys for ENC[...] values, watched for rotation")
+	encrypt := flag.String("encrypt", "", "print the ENC[...] form of this value under the primary key and exit")
+	flag.Parse()
 
-// ConfigManager holds a thread-safe configuration
-type ConfigManager struct {
-	mu     sync.RWMutex
-	config AppConfig
-}
+	// Keys also come from CONFIG_KEYS so that no key has to sit next to the config
+	keyring, err := NewKeyring(os.Getenv("CONFIG_KEYS"), *keyFile)
+	if err != nil {
+		log.Fatalf("Error loading keys: %v", err)
+	}
+	if *encrypt != "" {
+		sealed, err := keyring.Encrypt(*encrypt)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println(sealed)
+		return
+	}
 
-func NewConfigManager() *ConfigManager {
-	return &ConfigManager{}
-}
+	if *historyFile != "" && !keyring.CanEncrypt() {
+		log.Printf("No encryption key: secrets are left out of %s and their versions cannot be rolled back to after a restart", *historyFile)
+	}
+	history, err := NewHistory(*historySize, *historyFile, keyring)
+	if err != nil {
+		log.Fatalf("Error loading config history: %v", err)
+	}
+	cm := NewConfigManager(history)
+	layers := NewLayers(keyring, configSources(*configFiles, *env, *remoteURL, *remoteInterval)...)
 
-func (cm *ConfigManager) Update(newConfig AppConfig) {
-	cm.mu.Lock()
-	defer cm.mu.Unlock()
-	cm.config = newConfig
-	log.Println("Configuration updated successfully")
-}
+	// Initial load: there is no last-known-good config to fall back on yet
+	initialConfig, origins, err := layers.Load(context.Background())
+	if err != nil {
+		log.Fatalf("Error loading config: %v", err)
+	}
+	if err := cm.Update("startup", initialConfig, origins); err != nil {
+		log.Fatal(err)
+	}
 
-func (cm *ConfigManager) Get() AppConfig {
-	cm.mu.RLock()
-	defer cm.mu.RUnlock()
-	return cm.config
-}
+	// The request logger re-tunes itself only when log.level changes
+	var logLevel slog.LevelVar
+	logLevel.Set(parseLevel(initialConfig.Log.Level))
+	reqLog := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: &logLevel}))
+	if _, err := Subscribe(cm, "log.level", func(old, new string) {
+		logLevel.Set(parseLevel(new))
+		log.Printf("Log level changed from %s to %s", old, new)
+	}); err != nil {
+		log.Fatal(err)
+	}
 
-func main() {
-	cm := NewConfigManager()
+	srv := newRebindingServer(http.DefaultServeMux)
 
-	// Viper setup
-	v := viper.New()
-	v.SetConfigName("config")
-	v.SetConfigType("yaml")
-	v.AddConfigPath(".")
+	// HTTP Handler
+	http.HandleFunc("/config", func(w http.ResponseWriter, r *http.Request) {
+		reqLog.Debug("serving config", "remote", r.RemoteAddr)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(struct {
+			Config  AppConfig `json:"config"`
+			Sources Origins   `json:"sources"`
+		}{Redact(cm.Get()), cm.Origins()})
+	})
 
-	if err := v.ReadInConfig(); err != nil {
-		log.Fatalf("Error reading config: %s", err)
-	}
+	// Result of the last load attempt and the port actually served; 200 even
+	// when the reload failed, since the last good config is still in use
+	http.HandleFunc("/config/status", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(struct {
+			ReloadStatus
+			Listener ListenerStatus `json:"listener"`
+		}{cm.Status(), srv.Status()})
+	})
 
-	// Initial load
-	var initialConfig AppConfig
-	if err := v.Unmarshal(&initialConfig); err != nil {
-		log.Fatalf("Unable to decode into struct: %v", err)
-	}
-	cm.Update(initialConfig)
+	// Applied versions, newest first; a rollback re-applies one as a new version
+	http.HandleFunc("GET /config/history", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(cm.History())
+	})
 
-	// Watch for changes
-	v.OnConfigChange(func(e fsnotify.Event) {
-		log.Printf("Config file changed: %s", e.Name)
-		var newConfig AppConfig
-		if err := v.Unmarshal(&newConfig); err != nil {
-			log.Printf("Error unmarshaling new config: %v", err)
+	http.HandleFunc("POST /config/rollback/{version}", func(w http.ResponseWriter, r *http.Request) {
+		n, err := strconv.Atoi(r.PathValue("version"))
+		if err != nil {
+			http.Error(w, "version must be a number", http.StatusBadRequest)
+			return
+		}
+		if err := cm.Rollback(n); err != nil {
+			status := http.StatusUnprocessableEntity
+			if errors.Is(err, ErrVersionNotFound) {
+				status = http.StatusNotFound
+			}
+			http.Error(w, err.Error(), status)
 			return
 		}
-		cm.Update(newConfig)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(cm.Status())
 	})
-	v.WatchConfig()
 
-	// HTTP Handler
-	http.HandleFunc("/config", func(w http.ResponseWriter, r *http.Request) {
-		cfg := cm.Get()
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(cfg)
+	if err := srv.Start(cm.Get().Server.Port); err != nil {
+		log.Fatalf("Error starting server: %v", err)
+	}
+	// A port change opens the new listener before draining the old one; if the
+	// new port cannot be bound the server stays where it is
+	if _, err := Subscribe(cm, "server.port", func(_, port int) {
+		srv.Rebind(port, cm.Get().Server.DrainTimeout)
+	}); err != nil {
+		log.Fatal(err)
+	}
+	// Watch every source; a change that does not load or validate is rejected
+	layers.Watch(context.Background(), func(source string, cfg AppConfig, origins Origins, err error) {
+		log.Printf("Config source changed: %s", source)
+		if source == "keyring" {
+			if err := history.Rekey(); err != nil {
+				log.Printf("Error re-encrypting config history: %v", err)
+			}
+		}
+		if err != nil {
+			cm.Reject(source, err)
+			return
+		}
+		cm.Update(source, cfg, origins)
 	})
 
-	port := cm.Get().Server.Port // Note: If port changes, restart is usually needed for net.Listen. 
-	// Hot reload is mostly useful for runtime flags/messages, not startup bindings.
-	
-	addr := fmt.Sprintf(":%d", port)
-	log.Printf("Server listening on %s (Try editing config.yaml)", addr)
-	http.ListenAndServe(addr, nil)
+	log.Printf("Server listening on :%d (Try editing config.yaml)", cm.Get().Server.Port)
+	srv.Wait()
+}
+
+// configSources lists the sources from lowest to highest precedence: defaults,
+// the files, the environment overlay file, APP_* variables, the remote store.
+func configSources(files, env, remoteURL string, remoteInterval time.Duration) []Source {
+	sources := []Source{defaults()}
+	var last string
+	for _, f := range strings.Split(files, ",") {
+		if f = strings.TrimSpace(f); f != "" {
+			sources = append(sources, fileSource{path: f})
+			last = f
+		}
+	}
+	if env != "" && last != "" {
+		ext := filepath.Ext(last)
+		overlay := strings.TrimSuffix(last, ext) + "." + env + ext
+		sources = append(sources, fileSource{path: overlay, optional: true})
+	}
+	sources = append(sources, newEnvSource("APP"))
+	if remoteURL != "" {
+		sources = append(sources, newRemoteSource(remoteURL, remoteInterval))
+	}
+	return sources
 }
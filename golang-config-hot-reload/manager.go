@@ -0,0 +1,164 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// ReloadStatus describes the last attempt to load the config.
+type ReloadStatus struct {
+	Attempted time.Time `json:"attempted"`
+	Source    string    `json:"source"`
+	Applied   bool      `json:"applied"`
+	// Version is the history number of the config in use.
+	Version int    `json:"version,omitempty"`
+	Error   string `json:"error,omitempty"`
+	// Changes lists what an applied reload changed.
+	Changes []Change `json:"changes,omitempty"`
+	// LastApplied is when the config in use was loaded.
+	LastApplied time.Time `json:"last_applied"`
+}
+
+// ConfigManager holds a thread-safe configuration. It only ever holds a
+// config that passed validation: the last-known-good one.
+type ConfigManager struct {
+	// updateMu serializes updates; their notifications are queued in the
+	// same order and delivered after it is released
+	updateMu sync.Mutex
+
+	mu      sync.RWMutex
+	config  AppConfig
+	origins Origins
+	status  ReloadStatus
+	loaded  bool
+	history *History
+
+	subsMu  sync.Mutex
+	subs    []subscription
+	nextSub int
+
+	queueMu     sync.Mutex
+	queue       []notification
+	dispatching bool
+}
+
+// NewConfigManager records every applied config in history.
+func NewConfigManager(history *History) *ConfigManager {
+	return &ConfigManager{history: history}
+}
+
+// Update validates newConfig and applies it along with where each value came
+// from, records it in the history, then notifies the subscribers of the keys
+// that changed. An invalid config is rejected and the current one is kept.
+//
+// Subscribers are notified without holding the update lock, so they may call
+// Update or Rollback themselves. Notifications are delivered one at a time in
+// update order; if another goroutine is delivering already, Update leaves its
+// notification to it and returns without waiting.
+func (cm *ConfigManager) Update(source string, newConfig AppConfig, origins Origins) error {
+	err := cm.apply(source, newConfig, origins)
+	cm.dispatch()
+	return err
+}
+
+// apply swaps in newConfig and queues the notification of its changes.
+func (cm *ConfigManager) apply(source string, newConfig AppConfig, origins Origins) error {
+	cm.updateMu.Lock()
+	defer cm.updateMu.Unlock()
+
+	if err := newConfig.Validate(); err != nil {
+		cm.Reject(source, err)
+		return err
+	}
+
+	cm.mu.Lock()
+	old, initial := cm.config, !cm.loaded
+	var changes []Change
+	if !initial {
+		changes = Diff(old, newConfig)
+	}
+	version, err := cm.history.Record(source, newConfig, origins)
+	if err != nil {
+		// The config is applied anyway; only persisting it failed
+		log.Printf("Recording config version %d: %v", version.Number, err)
+	}
+	now := time.Now()
+	cm.config = newConfig
+	cm.origins = origins
+	cm.loaded = true
+	cm.status = ReloadStatus{
+		Attempted:   now,
+		Source:      source,
+		Applied:     true,
+		Version:     version.Number,
+		LastApplied: now,
+		Changes:     changes,
+	}
+	cm.mu.Unlock()
+
+	if initial {
+		log.Println("Configuration loaded successfully")
+		return nil
+	}
+	log.Printf("Configuration updated successfully, %d key(s) changed", len(changes))
+	for _, c := range changes {
+		log.Printf("  %s", c)
+	}
+	cm.queueMu.Lock()
+	cm.queue = append(cm.queue, notification{old: old, new: newConfig, changes: changes})
+	cm.queueMu.Unlock()
+	return nil
+}
+
+// Reject records a failed load, such as a file that did not parse, without
+// touching the current config.
+func (cm *ConfigManager) Reject(source string, err error) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	cm.status = ReloadStatus{
+		Attempted:   time.Now(),
+		Source:      source,
+		Error:       err.Error(),
+		Version:     cm.status.Version,
+		LastApplied: cm.status.LastApplied,
+	}
+	log.Printf("Configuration rejected, keeping the last good one: %v", err)
+}
+
+// Rollback re-applies version n from the history. It goes through Update, so
+// it is validated, notifies subscribers and is recorded as a new version. The
+// next change of a source replaces it like any other config.
+func (cm *ConfigManager) Rollback(n int) error {
+	v, err := cm.history.Get(n)
+	if err != nil {
+		return err
+	}
+	return cm.Update(fmt.Sprintf("rollback:%d", n), v.Config, v.Origins)
+}
+
+// History lists the recorded versions, newest first.
+func (cm *ConfigManager) History() []Version {
+	return cm.history.List()
+}
+
+func (cm *ConfigManager) Get() AppConfig {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+	return cm.config
+}
+
+// Origins returns the source of each value of the current config.
+func (cm *ConfigManager) Origins() Origins {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+	return cm.origins
+}
+
+// Status returns the result of the last load attempt.
+func (cm *ConfigManager) Status() ReloadStatus {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+	return cm.status
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// Encrypted values look like ENC[<key id>:<base64 of nonce and ciphertext>],
+// sealed with AES-256-GCM under the named key.
+const (
+	encPrefix = "ENC["
+	encSuffix = "]"
+	redacted  = "[REDACTED]"
+	dropped   = "[DROPPED]" // stands in for a secret that could not be persisted
+)
+
+// ErrUnknownKey is returned for a value sealed with a key the keyring lacks.
+var ErrUnknownKey = errors.New("unknown encryption key")
+
+// Keyring holds the AES-256 keys for config secrets, by ID. Keys are read
+// from an environment variable value and an optional key file, one "id:base64"
+// per line or comma-separated; the first one is the primary, used to encrypt.
+// All keys decrypt, so a key can be rotated without downtime: add the new key
+// first, re-encrypt the values, then drop the old key. The key file is
+// watched, so none of these steps needs a restart, and the persisted history
+// is re-encrypted under the new primary as soon as it is added.
+type Keyring struct {
+	env  string
+	path string
+
+	mu      sync.RWMutex
+	keys    map[string]cipher.AEAD
+	primary string
+}
+
+func NewKeyring(env, path string) (*Keyring, error) {
+	k := &Keyring{env: env, path: path}
+	if err := k.reload(); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+func (k *Keyring) reload() error {
+	var entries []string
+	if k.path != "" {
+		f, err := os.Open(k.path)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("read key file: %w", err)
+		}
+		if err == nil {
+			sc := bufio.NewScanner(f)
+			for sc.Scan() {
+				entries = append(entries, sc.Text())
+			}
+			f.Close()
+			if err := sc.Err(); err != nil {
+				return fmt.Errorf("read key file: %w", err)
+			}
+		}
+	}
+	entries = append(entries, strings.Split(k.env, ",")...)
+
+	keys := make(map[string]cipher.AEAD)
+	var primary string
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" || strings.HasPrefix(entry, "#") {
+			continue
+		}
+		id, encoded, ok := strings.Cut(entry, ":")
+		if !ok {
+			return errors.New("malformed key entry: want id:base64")
+		}
+		aead, err := newAEAD(encoded)
+		if err != nil {
+			return fmt.Errorf("key %s: %w", id, err)
+		}
+		keys[id] = aead
+		if primary == "" {
+			primary = id
+		}
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.keys, k.primary = keys, primary
+	return nil
+}
+
+func newAEAD(encoded string) (cipher.AEAD, error) {
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+	if len(key) != 32 {
+		return nil, fmt.Errorf("want a 32-byte key, got %d bytes", len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Watch reloads the key file when it changes and then calls changed, so
+// values sealed with a newly added key can be decrypted.
+func (k *Keyring) Watch(ctx context.Context, changed func()) error {
+	if k.path == "" {
+		return nil
+	}
+	return fileSource{path: k.path}.Watch(ctx, func() {
+		if err := k.reload(); err != nil {
+			// Keep the previous keys; a half-written key file must not lock us out
+			log.Printf("Reloading keys: %v", err)
+			return
+		}
+		changed()
+	})
+}
+
+// CanEncrypt reports whether there is a primary key to encrypt with.
+func (k *Keyring) CanEncrypt() bool {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.primary != ""
+}
+
+// Encrypt seals plaintext with the primary key.
+func (k *Keyring) Encrypt(plaintext string) (string, error) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	if k.primary == "" {
+		return "", fmt.Errorf("%w: no keys configured", ErrUnknownKey)
+	}
+	aead := k.keys[k.primary]
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(plaintext), nil)
+	return encPrefix + k.primary + ":" + base64.StdEncoding.EncodeToString(sealed) + encSuffix, nil
+}
+
+// Decrypt opens an ENC[...] value; any other value is returned unchanged.
+func (k *Keyring) Decrypt(value string) (string, error) {
+	if !isEncrypted(value) {
+		return value, nil
+	}
+	id, encoded, ok := strings.Cut(strings.TrimSuffix(strings.TrimPrefix(value, encPrefix), encSuffix), ":")
+	if !ok {
+		return "", errors.New("malformed encrypted value")
+	}
+	k.mu.RLock()
+	aead, ok := k.keys[id]
+	k.mu.RUnlock()
+	if !ok {
+		return "", fmt.Errorf("%w %q", ErrUnknownKey, id)
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(sealed) < aead.NonceSize() {
+		return "", errors.New("malformed encrypted value")
+	}
+	plain, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], nil)
+	if err != nil {
+		return "", fmt.Errorf("decrypt with key %q: %w", id, err)
+	}
+	return string(plain), nil
+}
+
+// Reseal re-encrypts a value sealed with a key other than the primary under
+// the primary. Plain values, values already under the primary and values
+// when there is no primary are returned unchanged.
+func (k *Keyring) Reseal(value string) (string, error) {
+	if !isEncrypted(value) {
+		return value, nil
+	}
+	k.mu.RLock()
+	primary := k.primary
+	k.mu.RUnlock()
+	if primary == "" || strings.HasPrefix(value, encPrefix+primary+":") {
+		return value, nil
+	}
+	plain, err := k.Decrypt(value)
+	if err != nil {
+		return "", err
+	}
+	return k.Encrypt(plain)
+}
+
+func isEncrypted(value string) bool {
+	return strings.HasPrefix(value, encPrefix) && strings.HasSuffix(value, encSuffix)
+}
+
+// secretKeys are the config keys of the AppConfig fields tagged secret:"true".
+var secretKeys = func() map[string]bool {
+	keys := make(map[string]bool)
+	var walk func(prefix string, t reflect.Type)
+	walk = func(prefix string, t reflect.Type) {
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			path := joinPath(prefix, keyOf(f))
+			if f.Type.Kind() == reflect.Struct {
+				walk(path, f.Type)
+			} else if f.Tag.Get("secret") == "true" {
+				keys[path] = true
+			}
+		}
+	}
+	walk("", reflect.TypeOf(AppConfig{}))
+	return keys
+}()
+
+// mapSecrets replaces every secret field of cfg with fn of its value.
+func mapSecrets(cfg AppConfig, fn func(value string) (string, error)) (AppConfig, error) {
+	v := reflect.ValueOf(&cfg).Elem()
+	for path := range secretKeys {
+		field, _ := lookup(v, path)
+		out, err := fn(field.String())
+		if err != nil {
+			return AppConfig{}, fmt.Errorf("%s: %w", path, err)
+		}
+		field.SetString(out)
+	}
+	return cfg, nil
+}
+
+// Redact returns cfg with its secret fields masked, for output.
+func Redact(cfg AppConfig) AppConfig {
+	cfg, _ = mapSecrets(cfg, func(value string) (string, error) {
+		if value == "" || value == dropped {
+			return value, nil
+		}
+		return redacted, nil
+	})
+	return cfg
+}
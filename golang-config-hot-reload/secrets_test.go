@@ -0,0 +1,218 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newKey(t *testing.T, id string) string {
+	t.Helper()
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatal(err)
+	}
+	return id + ":" + base64.StdEncoding.EncodeToString(key)
+}
+
+func TestKeyring_EncryptDecryptAndRotate(t *testing.T) {
+	old, next := newKey(t, "k1"), newKey(t, "k2")
+	k, err := NewKeyring(old, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealedOld, _ := k.Encrypt("s3cret")
+	if !strings.HasPrefix(sealedOld, "ENC[k1:") || strings.Contains(sealedOld, "s3cret") {
+		t.Fatalf("unexpected sealed form %q", sealedOld)
+	}
+	if plain, err := k.Decrypt(sealedOld); err != nil || plain != "s3cret" {
+		t.Fatalf("got %q, %v", plain, err)
+	}
+	if plain, _ := k.Decrypt("not encrypted"); plain != "not encrypted" {
+		t.Errorf("expected plain values to pass through, got %q", plain)
+	}
+
+	// With the new key first, new values use it and old ones still open
+	k, _ = NewKeyring(next+","+old, "")
+	sealedNew, _ := k.Encrypt("s3cret")
+	if !strings.HasPrefix(sealedNew, "ENC[k2:") {
+		t.Errorf("expected the primary key to be k2, got %q", sealedNew)
+	}
+	if plain, err := k.Decrypt(sealedOld); err != nil || plain != "s3cret" {
+		t.Errorf("expected the old key to keep working: %q, %v", plain, err)
+	}
+
+	// Once the old key is gone its values no longer open
+	k, _ = NewKeyring(next, "")
+	if _, err := k.Decrypt(sealedOld); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("expected ErrUnknownKey, got %v", err)
+	}
+	tampered := sealedNew[:len(sealedNew)-3] + "AA]"
+	if _, err := k.Decrypt(tampered); err == nil {
+		t.Error("expected a tampered value to fail")
+	}
+}
+
+func TestSecrets_RedactedInOutput(t *testing.T) {
+	old, cfg := testConfig(8080, "hi"), testConfig(8080, "hi")
+	old.Database.Password = "first"
+	cfg.Database.Password = "second"
+
+	if got := Redact(cfg).Database.Password; got != redacted {
+		t.Errorf("expected the password to be redacted, got %q", got)
+	}
+	changes := Diff(old, cfg)
+	if len(changes) != 1 || changes[0].Old != redacted || changes[0].New != redacted {
+		t.Errorf("expected a redacted change, got %v", changes)
+	}
+
+	// The persisted history holds the secret encrypted and gives it back on Get
+	k, _ := NewKeyring(newKey(t, "k1"), "")
+	path := filepath.Join(t.TempDir(), "history.json")
+	h, _ := NewHistory(5, path, k)
+	h.Record("test", cfg, nil)
+	data, _ := os.ReadFile(path)
+	if strings.Contains(string(data), "second") || !strings.Contains(string(data), "ENC[k1:") {
+		t.Fatalf("expected the secret to be stored encrypted:\n%s", data)
+	}
+	if h.List()[0].Config.Database.Password != redacted {
+		t.Error("expected List to redact secrets")
+	}
+	// The published hash is keyed, so it cannot confirm a guessed password
+	plain, _ := json.Marshal(cfg)
+	if sum := sha256.Sum256(plain); h.List()[0].Hash == hex.EncodeToString(sum[:]) {
+		t.Error("expected the version hash to be keyed")
+	}
+	h, _ = NewHistory(5, path, k)
+	if v, err := h.Get(1); err != nil || v.Config.Database.Password != "second" {
+		t.Errorf("expected the decrypted secret back, got %+v, %v", v.Config.Database, err)
+	}
+}
+
+func TestHistory_SecretDroppedWithoutKey(t *testing.T) {
+	cfg := testConfig(8080, "hi")
+	cfg.Database.Password = "pw"
+	path := filepath.Join(t.TempDir(), "history.json")
+	h, _ := NewHistory(5, path, &Keyring{})
+	h.Record("test", cfg, nil)
+	data, _ := os.ReadFile(path)
+	if strings.Contains(string(data), `"pw"`) || !strings.Contains(string(data), dropped) {
+		t.Fatalf("expected the secret to be marked as dropped:\n%s", data)
+	}
+
+	// After a restart the version cannot be restored with an empty password
+	h, _ = NewHistory(5, path, &Keyring{})
+	cm := NewConfigManager(h)
+	if err := cm.Rollback(1); !errors.Is(err, ErrSecretDropped) {
+		t.Fatalf("expected ErrSecretDropped, got %v", err)
+	}
+	if got := h.List()[0].Config.Database.Password; got != dropped {
+		t.Errorf("expected List to show the secret as dropped, got %q", got)
+	}
+}
+
+func TestHistory_RekeyedOnRotation(t *testing.T) {
+	dir := t.TempDir()
+	keyFile, path := filepath.Join(dir, "keys"), filepath.Join(dir, "history.json")
+	old, next := newKey(t, "k1"), newKey(t, "k2")
+	writeFile(t, keyFile, old+"\n")
+	keyring, _ := NewKeyring("", keyFile)
+	cfg := testConfig(8080, "hi")
+	cfg.Database.Password = "pw"
+	h, _ := NewHistory(5, path, keyring)
+	h.Record("test", cfg, nil)
+
+	// After a restart the versions hold k1 ciphertexts; adding k2 as the primary re-encrypts them
+	h, _ = NewHistory(5, path, keyring)
+	writeFile(t, keyFile, next+"\n"+old+"\n")
+	if err := keyring.reload(); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Rekey(); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(path)
+	if strings.Contains(string(data), "ENC[k1:") || !strings.Contains(string(data), "ENC[k2:") {
+		t.Fatalf("expected the history to be re-encrypted with k2:\n%s", data)
+	}
+
+	// Dropping k1 loses nothing, in memory or after another restart
+	writeFile(t, keyFile, next+"\n")
+	if err := keyring.reload(); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := h.Get(1); err != nil || v.Config.Database.Password != "pw" {
+		t.Errorf("expected the secret back after dropping k1, got %q, %v", v.Config.Database.Password, err)
+	}
+	h, _ = NewHistory(5, path, keyring)
+	if err := NewConfigManager(h).Rollback(1); err != nil {
+		t.Errorf("expected rollback to work after the rotation: %v", err)
+	}
+}
+
+func TestLayers_KeyFileRotationReloads(t *testing.T) {
+	dir := t.TempDir()
+	keyFile := filepath.Join(dir, "keys")
+	old, next := newKey(t, "k1"), newKey(t, "k2")
+	writeFile(t, keyFile, old+"\n")
+	keyring, err := NewKeyring("", keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sealed, _ := keyring.Encrypt("pw1")
+	base := filepath.Join(dir, "config.yaml")
+	writeFile(t, base, "server:\n  port: 8084\n  message: hi\ndatabase:\n  password: "+sealed+"\n")
+	layers := NewLayers(keyring, defaults(), fileSource{path: base})
+	cfg, _, err := layers.Load(context.Background())
+	if err != nil || cfg.Database.Password != "pw1" {
+		t.Fatalf("got %q, %v", cfg.Database.Password, err)
+	}
+
+	type result struct {
+		cfg AppConfig
+		err error
+	}
+	results := make(chan result, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	layers.Watch(ctx, func(_ string, cfg AppConfig, _ Origins, err error) {
+		results <- result{cfg, err}
+	})
+	time.Sleep(50 * time.Millisecond)
+
+	// A value sealed with a key the app does not have yet is rejected...
+	nextRing, _ := NewKeyring(next, "")
+	sealed, _ = nextRing.Encrypt("pw2")
+	writeFile(t, base, "server:\n  port: 8084\n  message: hi\ndatabase:\n  password: "+sealed+"\n")
+	var res result
+	for res.err == nil {
+		select {
+		case res = <-results:
+		case <-time.After(2 * time.Second):
+			t.Fatal("expected the unknown key to be reported")
+		}
+	}
+	if !errors.Is(res.err, ErrUnknownKey) || strings.Contains(res.err.Error(), "pw2") {
+		t.Fatalf("unexpected error: %v", res.err)
+	}
+
+	// ...and applied once the key file gains the key
+	writeFile(t, keyFile, next+"\n"+old+"\n")
+	for res.err != nil || res.cfg.Database.Password != "pw2" {
+		select {
+		case res = <-results:
+		case <-time.After(2 * time.Second):
+			t.Fatalf("expected the new key to open the value, last result %v", res.err)
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ListenerStatus is the port being served and the error of the last rebind.
+type ListenerStatus struct {
+	Port  int    `json:"port"`
+	Error string `json:"error,omitempty"`
+}
+
+// rebindingServer is an http.Server whose listening port can change while it
+// runs. Connections are tracked per listener so the old one can be drained
+// without touching the new one.
+type rebindingServer struct {
+	srv *http.Server
+
+	mu      sync.Mutex
+	ln      net.Listener
+	port    int
+	lastErr error
+	conns   map[net.Conn]*trackedConn
+	serving sync.WaitGroup
+}
+
+type trackedConn struct {
+	ln    net.Listener
+	state http.ConnState
+}
+
+func newRebindingServer(handler http.Handler) *rebindingServer {
+	s := &rebindingServer{conns: make(map[net.Conn]*trackedConn)}
+	s.srv = &http.Server{Handler: handler, ConnState: s.trackState}
+	return s
+}
+
+// Start binds port and serves on it until Close.
+func (s *rebindingServer) Start(port int) error {
+	ln, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ln, s.port = ln, port
+	s.serve(ln)
+	return nil
+}
+
+// Rebind moves the server to port. The new listener is opened first, so if
+// binding fails the server stays on the old port and the error is kept for
+// Status. Otherwise the old listener stops accepting and its connections get
+// drainTimeout to finish their requests.
+func (s *rebindingServer) Rebind(port int, drainTimeout time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if port == s.port {
+		// Back on the port in use, a failed rebind to another one is moot
+		s.lastErr = nil
+		return nil
+	}
+
+	ln, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+	if err != nil {
+		s.lastErr = fmt.Errorf("rebind to :%d: %w", port, err)
+		log.Printf("Keeping :%d: %v", s.port, s.lastErr)
+		return s.lastErr
+	}
+	old, oldPort := s.ln, s.port
+	s.ln, s.port, s.lastErr = ln, port, nil
+	s.serve(ln)
+	log.Printf("Server listening on :%d, draining :%d", port, oldPort)
+
+	old.Close()
+	go s.drain(old, drainTimeout)
+	return nil
+}
+
+// Status reports the port in use and the last rebind error.
+func (s *rebindingServer) Status() ListenerStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st := ListenerStatus{Port: s.port}
+	if s.lastErr != nil {
+		st.Error = s.lastErr.Error()
+	}
+	return st
+}
+
+// Wait blocks until every listener has stopped serving.
+func (s *rebindingServer) Wait() {
+	s.serving.Wait()
+}
+
+func (s *rebindingServer) serve(ln net.Listener) {
+	s.serving.Add(1)
+	go func() {
+		defer s.serving.Done()
+		err := s.srv.Serve(&trackingListener{Listener: ln, s: s})
+		if err != nil && !errors.Is(err, http.ErrServerClosed) && !errors.Is(err, net.ErrClosed) {
+			log.Printf("Serving on %s stopped: %v", ln.Addr(), err)
+		}
+	}()
+}
+
+// drain closes the connections accepted by ln as soon as they are idle, and
+// all of them once timeout has passed.
+func (s *rebindingServer) drain(ln net.Listener, timeout time.Duration) {
+	deadline := time.Now().Add(timeout)
+	ticker := time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		force := time.Now().After(deadline)
+		s.mu.Lock()
+		open := 0
+		for c, tc := range s.conns {
+			if tc.ln != ln {
+				continue
+			}
+			if force || tc.state == http.StateIdle {
+				c.Close()
+				delete(s.conns, c)
+				continue
+			}
+			open++
+		}
+		s.mu.Unlock()
+
+		if open == 0 {
+			log.Printf("Drained %s", ln.Addr())
+			return
+		}
+		if force {
+			return
+		}
+		<-ticker.C
+	}
+}
+
+func (s *rebindingServer) trackState(c net.Conn, state http.ConnState) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tc, ok := s.conns[c]
+	if !ok {
+		return
+	}
+	switch state {
+	case http.StateClosed, http.StateHijacked:
+		delete(s.conns, c)
+	default:
+		tc.state = state
+	}
+}
+
+// trackingListener records which listener accepted each connection.
+type trackingListener struct {
+	net.Listener
+	s *rebindingServer
+}
+
+func (l *trackingListener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	l.s.mu.Lock()
+	l.s.conns[c] = &trackedConn{ln: l.Listener, state: http.StateNew}
+	l.s.mu.Unlock()
+	return c, nil
+}
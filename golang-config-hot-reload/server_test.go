@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// freePort returns a port nothing listens on right now.
+func freePort(t *testing.T) int {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	return ln.Addr().(*net.TCPAddr).Port
+}
+
+func get(port int, path string) (string, error) {
+	resp, err := http.Get(fmt.Sprintf("http://127.0.0.1:%d%s", port, path))
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	return string(body), err
+}
+
+func TestRebindingServer_Rebind(t *testing.T) {
+	entered, release := make(chan struct{}), make(chan struct{})
+	s := newRebindingServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			close(entered)
+			<-release
+		}
+		io.WriteString(w, "ok")
+	}))
+	oldPort := freePort(t)
+	if err := s.Start(oldPort); err != nil {
+		t.Fatal(err)
+	}
+	defer s.srv.Close()
+
+	// A request in flight on the old port
+	slow := make(chan string)
+	go func() {
+		body, err := get(oldPort, "/slow")
+		if err != nil {
+			body = err.Error()
+		}
+		slow <- body
+	}()
+	<-entered
+
+	// A taken port keeps the server where it is and reports why
+	taken, err := net.Listen("tcp", ":0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer taken.Close()
+	if err := s.Rebind(taken.Addr().(*net.TCPAddr).Port, time.Second); err == nil {
+		t.Fatal("expected rebinding to a taken port to fail")
+	}
+	if st := s.Status(); st.Port != oldPort || !strings.Contains(st.Error, "rebind to") {
+		t.Errorf("expected to stay on :%d with the error, got %+v", oldPort, st)
+	}
+	if err := s.Rebind(oldPort, time.Second); err != nil || s.Status().Error != "" {
+		t.Errorf("expected the error cleared on the current port, got %v, %+v", err, s.Status())
+	}
+
+	newPort := freePort(t)
+	if err := s.Rebind(newPort, 5*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if body, err := get(newPort, "/"); err != nil || body != "ok" {
+		t.Errorf("expected the new port to serve, got %q, %v", body, err)
+	}
+	if _, err := get(oldPort, "/"); err == nil {
+		t.Error("expected the old port to stop accepting")
+	}
+	if st := s.Status(); st.Port != newPort || st.Error != "" {
+		t.Errorf("expected a clean status on :%d, got %+v", newPort, st)
+	}
+
+	// The in-flight request still completes
+	close(release)
+	if body := <-slow; body != "ok" {
+		t.Errorf("expected the in-flight request to finish, got %q", body)
+	}
+}
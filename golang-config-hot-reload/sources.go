@@ -0,0 +1,241 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"maps"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/viper"
+)
+
+// Source supplies config values as a flat map of dotted keys, such as
+// "server.port". Sources are layered by a Layers; later ones win.
+type Source interface {
+	Name() string
+	Load(ctx context.Context) (map[string]any, error)
+	// Watch calls changed whenever the source may have new values, until ctx
+	// ends. Sources that cannot change return at once.
+	Watch(ctx context.Context, changed func()) error
+}
+
+// defaultsSource holds the built-in values.
+type defaultsSource map[string]any
+
+func (defaultsSource) Name() string {
+	return "defaults"
+}
+
+func (d defaultsSource) Load(context.Context) (map[string]any, error) {
+	return maps.Clone(d), nil
+}
+
+func (defaultsSource) Watch(context.Context, func()) error {
+	return nil
+}
+
+// fileSource is a YAML file, watched with fsnotify. An optional file may be
+// missing, and may appear or disappear while watched.
+type fileSource struct {
+	path     string
+	optional bool
+}
+
+func (f fileSource) Name() string {
+	return "file:" + f.path
+}
+
+func (f fileSource) Load(context.Context) (map[string]any, error) {
+	if _, err := os.Stat(f.path); errors.Is(err, fs.ErrNotExist) && f.optional {
+		return map[string]any{}, nil
+	}
+	v := viper.New()
+	v.SetConfigFile(f.path)
+	v.SetConfigType("yaml")
+	if err := v.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("read %s: %w", f.path, err)
+	}
+	return flattenMap(v.AllSettings()), nil
+}
+
+// Watch watches the directory rather than the file so that editors that
+// replace the file, and optional files created later, are seen.
+func (f fileSource) Watch(ctx context.Context, changed func()) error {
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	if err := w.Add(filepath.Dir(f.path)); err != nil {
+		return err
+	}
+
+	target := filepath.Clean(f.path)
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case e, ok := <-w.Events:
+			if !ok {
+				return nil
+			}
+			if filepath.Clean(e.Name) == target && !e.Has(fsnotify.Chmod) {
+				changed()
+			}
+		case err, ok := <-w.Errors:
+			if !ok {
+				return nil
+			}
+			log.Printf("Watching %s: %v", f.path, err)
+		}
+	}
+}
+
+// envSource reads PREFIX_SECTION_KEY variables for the known config keys,
+// e.g. APP_SERVER_PORT for server.port. The environment of a running process
+// does not change, so it is not watched.
+type envSource struct {
+	prefix string
+	keys   []string
+}
+
+func newEnvSource(prefix string) envSource {
+	var keys []string
+	for key := range flatten(AppConfig{}) {
+		keys = append(keys, key)
+	}
+	return envSource{prefix: prefix, keys: keys}
+}
+
+func (e envSource) Name() string {
+	return "env"
+}
+
+func (e envSource) Load(context.Context) (map[string]any, error) {
+	values := make(map[string]any)
+	for _, key := range e.keys {
+		if v, ok := os.LookupEnv(e.variable(key)); ok {
+			values[key] = v
+		}
+	}
+	return values, nil
+}
+
+func (e envSource) Watch(context.Context, func()) error {
+	return nil
+}
+
+func (e envSource) variable(key string) string {
+	return e.prefix + "_" + strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+}
+
+// remoteSource is an HTTP key/value endpoint returning a JSON object, nested
+// or with dotted keys. It is polled with If-None-Match, so an unchanged
+// document costs a 304.
+type remoteSource struct {
+	url      string
+	interval time.Duration
+	client   *http.Client
+
+	mu     sync.Mutex
+	etag   string
+	values map[string]any
+}
+
+func newRemoteSource(url string, interval time.Duration) *remoteSource {
+	return &remoteSource{url: url, interval: interval, client: &http.Client{Timeout: 5 * time.Second}}
+}
+
+func (r *remoteSource) Name() string {
+	return "remote:" + r.url
+}
+
+func (r *remoteSource) Load(ctx context.Context) (map[string]any, error) {
+	if _, err := r.fetch(ctx); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return maps.Clone(r.values), nil
+}
+
+// Watch polls every interval. A failed poll is reported too, so the error
+// shows up in the reload status.
+func (r *remoteSource) Watch(ctx context.Context, changed func()) error {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+		if ok, err := r.fetch(ctx); ok || err != nil {
+			changed()
+		}
+	}
+}
+
+// fetch refreshes the cached values and reports whether they changed.
+func (r *remoteSource) fetch(ctx context.Context) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.url, nil)
+	if err != nil {
+		return false, err
+	}
+	r.mu.Lock()
+	if r.etag != "" {
+		req.Header.Set("If-None-Match", r.etag)
+	}
+	r.mu.Unlock()
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("fetch %s: %w", r.url, err)
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusNotModified:
+		return false, nil
+	case http.StatusOK:
+	default:
+		return false, fmt.Errorf("fetch %s: %s", r.url, resp.Status)
+	}
+
+	var doc map[string]any
+	if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		return false, fmt.Errorf("decode %s: %w", r.url, err)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.etag = resp.Header.Get("ETag")
+	r.values = flattenMap(doc)
+	return true, nil
+}
+
+// flattenMap turns nested maps into dotted, lower-case keys.
+func flattenMap(nested map[string]any) map[string]any {
+	flat := make(map[string]any)
+	var walk func(prefix string, m map[string]any)
+	walk = func(prefix string, m map[string]any) {
+		for k, v := range m {
+			key := joinPath(prefix, strings.ToLower(k))
+			if sub, ok := v.(map[string]any); ok {
+				walk(key, sub)
+				continue
+			}
+			flat[key] = v
+		}
+	}
+	walk("", nested)
+	return flat
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"reflect"
+	"runtime/debug"
+)
+
+// subscription is a typed callback for one config path.
+type subscription struct {
+	id     int
+	path   string
+	notify func(old, new AppConfig)
+}
+
+// Subscribe calls fn with the old and new value at path (a section such as
+// "server" or a key such as "server.port") after every reload that changes
+// something under it. Subscribers run in subscription order, one reload after
+// the other (see ConfigManager.Update); a panic in one is logged and does not
+// stop the others. T must match the type at path. The returned func
+// unsubscribes.
+func Subscribe[T any](cm *ConfigManager, path string, fn func(old, new T)) (func(), error) {
+	field, ok := lookup(reflect.ValueOf(AppConfig{}), path)
+	if !ok {
+		return nil, fmt.Errorf("subscribe: unknown config path %q", path)
+	}
+	if want := reflect.TypeOf((*T)(nil)).Elem(); field.Type() != want {
+		return nil, fmt.Errorf("subscribe: %q is a %s, not a %s", path, field.Type(), want)
+	}
+
+	at := func(cfg AppConfig) T {
+		v, _ := lookup(reflect.ValueOf(cfg), path)
+		return v.Interface().(T)
+	}
+	return cm.subscribe(path, func(old, new AppConfig) {
+		fn(at(old), at(new))
+	}), nil
+}
+
+func (cm *ConfigManager) subscribe(path string, notify func(old, new AppConfig)) func() {
+	cm.subsMu.Lock()
+	defer cm.subsMu.Unlock()
+	cm.nextSub++
+	id := cm.nextSub
+	cm.subs = append(cm.subs, subscription{id: id, path: path, notify: notify})
+
+	return func() {
+		cm.subsMu.Lock()
+		defer cm.subsMu.Unlock()
+		for i, s := range cm.subs {
+			if s.id == id {
+				cm.subs = append(cm.subs[:i:i], cm.subs[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// notification is an applied reload waiting to be published.
+type notification struct {
+	old, new AppConfig
+	changes  []Change
+}
+
+// dispatch publishes the queued notifications in order, unless another
+// goroutine is already doing so.
+func (cm *ConfigManager) dispatch() {
+	cm.queueMu.Lock()
+	if cm.dispatching {
+		cm.queueMu.Unlock()
+		return
+	}
+	cm.dispatching = true
+	for len(cm.queue) > 0 {
+		n := cm.queue[0]
+		cm.queue = cm.queue[1:]
+		cm.queueMu.Unlock()
+		cm.publish(n.old, n.new, n.changes)
+		cm.queueMu.Lock()
+	}
+	cm.dispatching = false
+	cm.queueMu.Unlock()
+}
+
+// publish notifies the subscribers whose path is touched by changes.
+func (cm *ConfigManager) publish(old, new AppConfig, changes []Change) {
+	cm.subsMu.Lock()
+	subs := append([]subscription(nil), cm.subs...)
+	cm.subsMu.Unlock()
+
+	for _, s := range subs {
+		for _, c := range changes {
+			if touches(s.path, c.Path) {
+				s.call(old, new)
+				break
+			}
+		}
+	}
+}
+
+func (s subscription) call(old, new AppConfig) {
+	defer func() {
+		if p := recover(); p != nil {
+			log.Printf("Config subscriber for %q panicked: %v\n%s", s.path, p, debug.Stack())
+		}
+	}()
+	s.notify(old, new)
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDiff(t *testing.T) {
+	old, cfg := testConfig(8080, "hi"), testConfig(9090, "hi")
+	cfg.Features.Beta = true
+	changes := Diff(old, cfg)
+	if got := fmt.Sprint(changes); got != "[features.beta: false -> true server.port: 8080 -> 9090]" {
+		t.Errorf("unexpected changes %s", got)
+	}
+	if changes := Diff(cfg, cfg); len(changes) != 0 {
+		t.Errorf("expected no changes, got %v", changes)
+	}
+}
+
+func TestSubscribe_OrderAndPanics(t *testing.T) {
+	h, _ := NewHistory(10, "", &Keyring{})
+	cm := NewConfigManager(h)
+	cm.Update("file", testConfig(8080, "hi"), nil)
+
+	var calls []string
+	Subscribe(cm, "", func(_, new AppConfig) {
+		calls = append(calls, fmt.Sprintf("all:%d", new.Server.Port))
+	})
+	Subscribe(cm, "server.port", func(int, int) { panic("boom") })
+	unsubscribe, _ := Subscribe(cm, "server.port", func(old, new int) {
+		calls = append(calls, fmt.Sprintf("port:%d->%d", old, new))
+	})
+	Subscribe(cm, "log.level", func(string, string) { calls = append(calls, "log") })
+
+	cm.Update("file", testConfig(9090, "hi"), nil)
+	if got := strings.Join(calls, ","); got != "all:9090,port:8080->9090" {
+		t.Errorf("expected in-order calls past the panic and only for changed keys, got %s", got)
+	}
+
+	calls = nil
+	unsubscribe()
+	cm.Update("file", testConfig(9091, "hi"), nil)
+	if got := strings.Join(calls, ","); got != "all:9091" {
+		t.Errorf("expected the unsubscribed callback to stay quiet, got %s", got)
+	}
+}
+
+func TestSubscribe_TypeChecks(t *testing.T) {
+	cm := NewConfigManager(nil)
+	if _, err := Subscribe(cm, "server.port", func(string, string) {}); err == nil ||
+		!strings.Contains(err.Error(), `"server.port" is a int, not a string`) {
+		t.Errorf("expected a type mismatch error, got %v", err)
+	}
+	if _, err := Subscribe(cm, "server.nope", func(int, int) {}); err == nil {
+		t.Error("expected an unknown path error")
+	}
+}
+
+func TestSubscribe_SubscriberMayUpdate(t *testing.T) {
+	h, _ := NewHistory(10, "", &Keyring{})
+	cm := NewConfigManager(h)
+	cm.Update("file", testConfig(8080, "hi"), nil)
+
+	// Roll back any message other than "hi" from inside a subscriber
+	var seen []string
+	Subscribe(cm, "server.message", func(_, new string) {
+		seen = append(seen, new)
+		if new != "hi" {
+			if err := cm.Rollback(1); err != nil {
+				t.Error(err)
+			}
+		}
+	})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		cm.Update("file", testConfig(8080, "oops"), nil)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("update from a subscriber deadlocked")
+	}
+	if got := strings.Join(seen, ","); got != "oops,hi" {
+		t.Errorf("expected both notifications in order, got %s", got)
+	}
+	if got := cm.Get().Server.Message; got != "hi" {
+		t.Errorf("expected the rollback to win, got %q", got)
+	}
+}